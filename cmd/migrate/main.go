@@ -0,0 +1,128 @@
+// 数据库迁移命令行工具
+//
+// 用法：
+//
+//	migrate up          执行所有未执行的迁移
+//	migrate down N      回滚最近的 N 个迁移
+//	migrate status      查看各迁移的执行状态
+//	migrate force V     不执行 SQL，直接把版本记录设置为 V（手动修复后使用）
+//
+// 数据库连接配置与 server 相同，通过 DB_* 环境变量注入
+// Kubernetes 中作为 initContainer 运行，在新版本 Pod 启动前完成迁移
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/migrate"
+	"github.com/yourname/saas-shortener/migrations"
+)
+
+const usage = `用法: migrate <command> [arg]
+
+命令:
+  up          执行所有未执行的迁移
+  down N      回滚最近的 N 个迁移
+  status      查看各迁移的执行状态
+  force V     不执行 SQL，直接把版本记录设置为 V
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	if err := run(logger, os.Args[1], os.Args[2:]); err != nil {
+		logger.Error("迁移失败", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func run(logger *zap.Logger, command string, args []string) error {
+	cfg := config.Load()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := sql.Open("pgx", cfg.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("打开数据库连接失败: %w", err)
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("迁移完成", zap.Int("applied", n))
+
+	case "down":
+		n, err := intArg(args)
+		if err != nil {
+			return err
+		}
+		done, err := m.Down(ctx, int(n))
+		if err != nil {
+			return err
+		}
+		logger.Info("回滚完成", zap.Int("reverted", done))
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	case "force":
+		version, err := intArg(args)
+		if err != nil {
+			return err
+		}
+		return m.Force(ctx, version)
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("未知命令: %s", command)
+	}
+	return nil
+}
+
+// intArg 解析命令的整数参数
+func intArg(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("需要一个整数参数")
+	}
+	n, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的整数参数 %q", args[0])
+	}
+	return n, nil
+}
@@ -5,7 +5,7 @@
 // 2. 12-Factor App: 配置通过环境变量注入
 // 3. 容器化（Docker）: 多阶段构建，最小化镜像体积
 // 4. 编排（Kubernetes）: Deployment、Service、Ingress、HPA
-// 5. 可观测性：Prometheus 指标 + 结构化日志 + 健康检查
+// 5. 可观测性：Prometheus 指标 + 结构化日志 + 链路追踪 + 健康检查
 // 6. 优雅关闭（Graceful Shutdown）: 收到信号后等待请求处理完毕再退出
 // 7. API 版本管理: /api/v1/ 路径前缀
 // 8. 限流（Rate Limiting）: 基于 Redis 的分布式限流
@@ -13,6 +13,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -27,13 +28,22 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/geoip"
 	"github.com/yourname/saas-shortener/internal/handler"
 	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/migrate"
 	"github.com/yourname/saas-shortener/internal/repository"
 	"github.com/yourname/saas-shortener/internal/service"
+	"github.com/yourname/saas-shortener/internal/tracing"
+	"github.com/yourname/saas-shortener/migrations"
 )
 
+// skipMigrations 跳过启动时的数据库迁移（也可通过 DB_SKIP_MIGRATIONS=true 设置）
+var skipMigrations = flag.Bool("skip-migrations", false, "启动时跳过数据库迁移")
+
 func main() {
+	flag.Parse()
+
 	// ==================== 1. 初始化日志 ====================
 	// 生产环境使用 JSON 格式，便于 ELK/Loki 采集
 	logger := initLogger()
@@ -49,6 +59,13 @@ func main() {
 		zap.String("redis_addr", cfg.Redis.Addr),
 	)
 
+	// 初始化链路追踪（需在数据库、Redis 之前，以便安装 GORM / Redis 钩子）
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal("链路追踪初始化失败", zap.Error(err))
+	}
+	logger.Info("链路追踪初始化完成", zap.String("exporter", cfg.Tracing.Exporter))
+
 	// ==================== 3. 初始化数据库连接 ====================
 	db, err := initDatabase(cfg)
 	if err != nil {
@@ -65,14 +82,52 @@ func main() {
 
 	// ==================== 5. 初始化各层组件 ====================
 	repo := repository.New(db, rdb, logger)
-	svc := service.New(repo, logger)
-	h := handler.New(svc, logger)
 
-	// 自动迁移数据库
-	if err := repo.AutoMigrate(); err != nil {
-		logger.Fatal("数据库迁移失败", zap.Error(err))
+	// 点击事件异步写入管道（有界队列 + worker 池）
+	clicks := service.NewClickPipeline(repo, cfg.Clicks, logger)
+	clicks.Start()
+
+	// 短码规则：配置不合法时直接退出，避免运行中才发现
+	codes, err := service.NewCodePolicy(cfg.ShortCode)
+	if err != nil {
+		logger.Fatal("短码配置错误", zap.Error(err))
+	}
+
+	// IP 地理位置数据库（可选），用于按国家的条件跳转和点击事件的地理位置
+	// 文件被替换（如定期更新的 GeoLite2）后自动重新加载
+	var geo service.GeoResolver
+	if cfg.GeoIP.DatabasePath != "" {
+		reader, err := geoip.Open(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			logger.Fatal("GeoIP 数据库加载失败", zap.String("path", cfg.GeoIP.DatabasePath), zap.Error(err))
+		}
+		defer reader.Close()
+		if cfg.GeoIP.ReloadInterval > 0 {
+			reader.Watch(cfg.GeoIP.ReloadInterval, logger)
+		}
+		geo = reader
+		logger.Info("GeoIP 数据库加载成功", zap.String("path", cfg.GeoIP.DatabasePath))
+	} else {
+		logger.Info("未配置 GEOIP_DATABASE，点击事件不记录地理位置")
+	}
+
+	svc := service.New(repo, clicks, codes, geo, nil, cfg, logger)
+	h := handler.New(svc, cfg, logger)
+
+	// 数据库迁移（版本化 SQL，多副本同时启动时由 advisory lock 串行化）
+	// Kubernetes 中由 initContainer 执行 cmd/migrate，server 以 -skip-migrations 启动
+	if *skipMigrations || cfg.Database.SkipMigrations {
+		logger.Info("已跳过数据库迁移")
+	} else {
+		if err := runMigrations(db, logger); err != nil {
+			logger.Fatal("数据库迁移失败", zap.Error(err))
+		}
+		logger.Info("数据库迁移完成")
 	}
-	logger.Info("数据库迁移完成")
+
+	// Webhook 投递 worker（轮询投递队列表，依赖迁移创建的表）
+	webhooks := service.NewWebhookDispatcher(repo, nil, cfg.Webhooks, logger)
+	webhooks.Start()
 
 	// ==================== 6. 配置 HTTP 服务 ====================
 	gin.SetMode(gin.ReleaseMode)
@@ -80,9 +135,10 @@ func main() {
 
 	// 注册全局中间件
 	router.Use(
-		gin.Recovery(),                           // Panic 恢复
-		middleware.StructuredLogging(logger),      // 结构化日志
-		middleware.PrometheusMetrics(),            // Prometheus 指标
+		gin.Recovery(),                       // Panic 恢复
+		middleware.Tracing(),                 // 链路追踪（需在日志之前，日志中记录 trace_id）
+		middleware.StructuredLogging(logger), // 结构化日志
+		middleware.PrometheusMetrics(),       // Prometheus 指标
 	)
 
 	// 注册路由
@@ -95,6 +151,8 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	// 实时点击流是长连接，Shutdown 不会等它们自己结束，关闭开始时通知它们退出
+	server.RegisterOnShutdown(svc.CloseClickStreams)
 
 	// 在 goroutine 中启动服务
 	go func() {
@@ -110,8 +168,9 @@ func main() {
 	// 我们在收到 SIGTERM 后：
 	// 1. 停止接收新请求
 	// 2. 等待正在处理的请求完成
-	// 3. 关闭数据库和 Redis 连接
-	// 4. 退出
+	// 3. 写入队列中剩余的点击事件，等待进行中的 Webhook 投递
+	// 4. 关闭数据库和 Redis 连接
+	// 5. 退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
@@ -126,6 +185,21 @@ func main() {
 		logger.Error("HTTP 服务关闭异常", zap.Error(err))
 	}
 
+	// 写入队列中剩余的点击事件（必须在 HTTP 服务关闭之后、数据库关闭之前）
+	if err := clicks.Shutdown(ctx); err != nil {
+		logger.Error("点击事件写入未完成", zap.Error(err))
+	}
+
+	// 等待正在进行的 Webhook 投递完成（未完成的在租约到期后重新投递）
+	if err := webhooks.Shutdown(ctx); err != nil {
+		logger.Error("Webhook 投递未完成", zap.Error(err))
+	}
+
+	// 导出缓冲中的 span
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("链路追踪关闭异常", zap.Error(err))
+	}
+
 	// 关闭 Redis
 	if err := rdb.Close(); err != nil {
 		logger.Error("Redis 连接关闭异常", zap.Error(err))
@@ -162,7 +236,10 @@ func initLogger() *zap.Logger {
 
 // initDatabase 初始化数据库连接
 func initDatabase(cfg *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{
+		// 将唯一约束冲突等驱动错误转换为 gorm.ErrDuplicatedKey（即 repository.ErrDuplicate）
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库连接失败: %w", err)
 	}
@@ -179,15 +256,36 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(10)    // 最大空闲连接数
 	sqlDB.SetConnMaxLifetime(0)  // 连接最大存活时间（0 = 不限制）
 
+	// 为每条 SQL 创建 span
+	if err := db.Use(tracing.NewGormPlugin()); err != nil {
+		return nil, fmt.Errorf("注册链路追踪插件失败: %w", err)
+	}
+
 	return db, nil
 }
 
+// runMigrations 执行未完成的数据库迁移
+func runMigrations(db *gorm.DB, logger *zap.Logger) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("获取底层 DB 失败: %w", err)
+	}
+	m, err := migrate.New(sqlDB, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background())
+	return err
+}
+
 // initRedis 初始化 Redis 连接
 func initRedis(cfg *config.Config) *redis.Client {
-	return redis.NewClient(&redis.Options{
+	rdb := redis.NewClient(&redis.Options{
 		Addr:     cfg.Redis.Addr,
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.DB,
 		PoolSize: 20, // 连接池大小
 	})
+	rdb.AddHook(tracing.RedisHook()) // 为每个 Redis 命令创建 span
+	return rdb
 }
@@ -6,6 +6,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,6 +24,42 @@ type Config struct {
 
 	// SaaS 多租户配置
 	Tenant TenantConfig
+
+	// 短码生成与校验规则
+	ShortCode ShortCodeConfig
+
+	// 批量创建与导入配置
+	Batch BatchConfig
+
+	// 数据导出配置
+	Export ExportConfig
+
+	// 密码保护短链接配置
+	LinkPassword LinkPasswordConfig
+
+	// IP 地理位置数据库配置
+	GeoIP GeoIPConfig
+
+	// 二维码配置
+	QR QRConfig
+
+	// 自定义域名配置
+	Domains DomainConfig
+
+	// Webhook 事件推送配置
+	Webhooks WebhookConfig
+
+	// 点击事件异步写入配置
+	Clicks ClickConfig
+
+	// 实时点击流（SSE）配置
+	Stream StreamConfig
+
+	// 链路追踪配置（OpenTelemetry）
+	Tracing TracingConfig
+
+	// 平台管理接口配置
+	Admin AdminConfig
 }
 
 type ServerConfig struct {
@@ -30,6 +67,7 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration // 读取超时
 	WriteTimeout    time.Duration // 写入超时
 	ShutdownTimeout time.Duration // 优雅关闭超时
+	PublicBaseURL   string        // 短链接对外访问的地址，如 https://s.example.com；为空时使用请求的 Host
 }
 
 type DatabaseConfig struct {
@@ -39,6 +77,10 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// SkipMigrations 启动时跳过数据库迁移
+	// Kubernetes 中由 initContainer（cmd/migrate）负责迁移，server 无需再执行
+	SkipMigrations bool
 }
 
 // DSN 返回 PostgreSQL 连接字符串
@@ -62,6 +104,109 @@ type TenantConfig struct {
 	MaxURLsPerTenant int // 每个租户最大 URL 数量（免费套餐）
 }
 
+// ShortCodeConfig 短码规则
+// 短码存储在 varchar(10) 列中，长度上限不能超过 10
+type ShortCodeConfig struct {
+	Length     int      // 随机生成的短码长度
+	Charset    string   // 随机生成短码使用的字符集
+	MinLength  int      // 自定义短码最短长度
+	MaxLength  int      // 自定义短码最长长度
+	Pattern    string   // 自定义短码必须匹配的正则
+	Reserved   []string // 额外的保留字（路由前缀会自动保留）
+	MaxRetries int      // 随机短码冲突时的最大重试次数
+}
+
+// BatchConfig 批量创建与导入配置
+type BatchConfig struct {
+	MaxItems       int   // POST /urls/batch 单次最多创建的条数
+	ChunkSize      int   // 每个数据库事务写入的条数
+	ImportMaxBytes int64 // 导入文件的最大字节数
+}
+
+// ExportConfig 数据导出配置
+type ExportConfig struct {
+	BatchSize    int           // 每次从数据库游标读取的行数
+	WriteTimeout time.Duration // 导出响应的写入超时（覆盖 Server.WriteTimeout，大租户导出可能持续数分钟）
+}
+
+// LinkPasswordConfig 密码保护短链接配置
+// 验证通过后下发按短码签名的 Cookie，有效期内再次访问无需输入密码
+type LinkPasswordConfig struct {
+	CookieSecret  string        // Cookie 签名密钥（敏感信息，通过 Secret 注入），多副本必须一致；为空时启动时随机生成
+	CookieTTL     time.Duration // Cookie 有效期
+	MaxAttempts   int           // 同一 IP 对同一短码在窗口内允许输错的次数
+	AttemptWindow time.Duration // 输错次数的统计窗口，从第一次输错开始计时
+}
+
+// GeoIPConfig IP 地理位置数据库配置
+type GeoIPConfig struct {
+	DatabasePath   string        // MaxMind GeoLite2/GeoIP2 Country 或 City 数据库（.mmdb）路径，为空时不做地理位置查询
+	ReloadInterval time.Duration // 检查数据库文件是否被替换的间隔，文件变化后自动重新加载；0 表示不检查
+}
+
+// QRConfig 二维码生成配置
+type QRConfig struct {
+	DefaultSize int           // 默认图片尺寸（像素）
+	MaxSize     int           // 最大图片尺寸（像素）
+	CacheTTL    time.Duration // 生成结果在 Redis 中的缓存时间
+}
+
+// DomainConfig 租户自定义域名配置
+type DomainConfig struct {
+	MaxPerTenant  int           // 每个租户最多添加的域名数
+	VerifyTimeout time.Duration // 单次所有权验证（DNS 查询 / HTTP 请求）的超时时间
+}
+
+// WebhookConfig Webhook 事件推送配置
+// 事件先写入投递队列表，由后台 worker 轮询投递，失败按 RetryBase * 2^(n-1) 退避（不超过 RetryMax）
+type WebhookConfig struct {
+	MaxPerTenant        int           // 每个租户最多配置的 Webhook 数
+	Timeout             time.Duration // 单次投递的 HTTP 超时时间
+	MaxAttempts         int           // 最多投递次数，用完后进入 dead 状态
+	RetryBase           time.Duration // 第一次重试的等待时间
+	RetryMax            time.Duration // 重试等待时间上限
+	PollInterval        time.Duration // worker 轮询投递队列的间隔
+	BatchSize           int           // 每次轮询最多领取的投递数
+	Workers             int           // 并发投递数
+	Retention           time.Duration // 已投递 / 已放弃的记录保留时间
+	QuotaWarningPercent int           // URL 用量达到配额的该百分比时发送 quota.warning，0 表示不发送
+}
+
+// ClickConfig 点击事件异步写入管道配置
+// 重定向只把点击事件放入内存队列，由固定数量的 worker 批量写入数据库
+type ClickConfig struct {
+	QueueSize      int           // 内存队列容量
+	Workers        int           // 写入 worker 数量
+	BatchSize      int           // 每批最多写入的事件数
+	FlushInterval  time.Duration // 未攒满一批时的最长等待时间
+	OverflowPolicy string        // 队列满时的策略：drop（直接丢弃）/ block（等待 EnqueueTimeout 后丢弃）
+	EnqueueTimeout time.Duration // block 策略下的最长等待时间
+}
+
+// StreamConfig 实时点击流配置
+// 点击写入数据库后发布到租户的 Redis Pub/Sub 频道，同时追加到一个有长度上限的 Redis Stream，供断线重连时回放
+type StreamConfig struct {
+	MaxConnectionsPerTenant int           // 每个租户（在每个副本上）同时打开的连接数上限
+	Heartbeat               time.Duration // 心跳注释的发送间隔，防止代理因连接空闲而断开
+	WriteTimeout            time.Duration // 单次写入的超时时间，客户端长时间不读取时断开连接
+	BacklogSize             int           // 每个租户保留的回放事件数（近似值）
+	BacklogTTL              time.Duration // 租户没有新点击时回放数据的保留时间
+}
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	Exporter     string  // 导出方式：none（默认）/ otlp / stdout
+	OTLPEndpoint string  // OTLP/HTTP Collector 地址，如 localhost:4318
+	OTLPInsecure bool    // 是否使用 HTTP 明文连接 Collector（集群内通常为 true）
+	ServiceName  string  // 上报的服务名
+	SampleRatio  float64 // 新链路的采样比例，0~1
+}
+
+// AdminConfig 平台管理接口配置
+type AdminConfig struct {
+	Token string // 管理接口令牌（敏感信息，通过 Secret 注入），为空时关闭管理接口
+}
+
 // Load 从环境变量加载配置
 // 云原生原则：配置与代码分离，通过环境变量或挂载卷注入
 func Load() *Config {
@@ -71,6 +216,7 @@ func Load() *Config {
 			ReadTimeout:     getDurationEnv("SERVER_READ_TIMEOUT", 10*time.Second),
 			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
 			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			PublicBaseURL:   getEnv("PUBLIC_BASE_URL", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -79,6 +225,8 @@ func Load() *Config {
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			DBName:   getEnv("DB_NAME", "saas_shortener"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			SkipMigrations: getBoolEnv("DB_SKIP_MIGRATIONS", false),
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
@@ -89,6 +237,80 @@ func Load() *Config {
 			DefaultRateLimit: getIntEnv("TENANT_DEFAULT_RATE_LIMIT", 100),
 			MaxURLsPerTenant: getIntEnv("TENANT_MAX_URLS", 1000),
 		},
+		ShortCode: ShortCodeConfig{
+			Length:     getIntEnv("SHORT_CODE_LENGTH", 6),
+			Charset:    getEnv("SHORT_CODE_CHARSET", "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"),
+			MinLength:  getIntEnv("CUSTOM_CODE_MIN_LENGTH", 3),
+			MaxLength:  getIntEnv("CUSTOM_CODE_MAX_LENGTH", 10),
+			Pattern:    getEnv("CUSTOM_CODE_PATTERN", `^[A-Za-z0-9][A-Za-z0-9_-]*$`),
+			Reserved:   getListEnv("SHORT_CODE_RESERVED", nil),
+			MaxRetries: getIntEnv("SHORT_CODE_MAX_RETRIES", 5),
+		},
+		Batch: BatchConfig{
+			MaxItems:       getIntEnv("BATCH_MAX_ITEMS", 1000),
+			ChunkSize:      getIntEnv("BATCH_CHUNK_SIZE", 500),
+			ImportMaxBytes: int64(getIntEnv("IMPORT_MAX_BYTES", 32<<20)),
+		},
+		Export: ExportConfig{
+			BatchSize:    getIntEnv("EXPORT_BATCH_SIZE", 1000),
+			WriteTimeout: getDurationEnv("EXPORT_WRITE_TIMEOUT", time.Hour),
+		},
+		LinkPassword: LinkPasswordConfig{
+			CookieSecret:  getEnv("LINK_COOKIE_SECRET", ""),
+			CookieTTL:     getDurationEnv("LINK_COOKIE_TTL", time.Hour),
+			MaxAttempts:   getIntEnv("LINK_PASSWORD_MAX_ATTEMPTS", 5),
+			AttemptWindow: getDurationEnv("LINK_PASSWORD_ATTEMPT_WINDOW", 15*time.Minute),
+		},
+		GeoIP: GeoIPConfig{
+			DatabasePath:   getEnv("GEOIP_DATABASE", ""),
+			ReloadInterval: getDurationEnv("GEOIP_RELOAD_INTERVAL", time.Minute),
+		},
+		QR: QRConfig{
+			DefaultSize: getIntEnv("QR_DEFAULT_SIZE", 256),
+			MaxSize:     getIntEnv("QR_MAX_SIZE", 2048),
+			CacheTTL:    getDurationEnv("QR_CACHE_TTL", 24*time.Hour),
+		},
+		Domains: DomainConfig{
+			MaxPerTenant:  getIntEnv("DOMAIN_MAX_PER_TENANT", 10),
+			VerifyTimeout: getDurationEnv("DOMAIN_VERIFY_TIMEOUT", 10*time.Second),
+		},
+		Webhooks: WebhookConfig{
+			MaxPerTenant:        getIntEnv("WEBHOOK_MAX_PER_TENANT", 10),
+			Timeout:             getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:         getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryBase:           getDurationEnv("WEBHOOK_RETRY_BASE", 30*time.Second),
+			RetryMax:            getDurationEnv("WEBHOOK_RETRY_MAX", 6*time.Hour),
+			PollInterval:        getDurationEnv("WEBHOOK_POLL_INTERVAL", time.Second),
+			BatchSize:           getIntEnv("WEBHOOK_BATCH_SIZE", 100),
+			Workers:             getIntEnv("WEBHOOK_WORKERS", 8),
+			Retention:           getDurationEnv("WEBHOOK_RETENTION", 7*24*time.Hour),
+			QuotaWarningPercent: getIntEnv("WEBHOOK_QUOTA_WARNING_PERCENT", 80),
+		},
+		Clicks: ClickConfig{
+			QueueSize:      getIntEnv("CLICK_QUEUE_SIZE", 10000),
+			Workers:        getIntEnv("CLICK_WORKERS", 4),
+			BatchSize:      getIntEnv("CLICK_BATCH_SIZE", 500),
+			FlushInterval:  getDurationEnv("CLICK_FLUSH_INTERVAL", time.Second),
+			OverflowPolicy: getEnv("CLICK_OVERFLOW_POLICY", "drop"),
+			EnqueueTimeout: getDurationEnv("CLICK_ENQUEUE_TIMEOUT", 50*time.Millisecond),
+		},
+		Stream: StreamConfig{
+			MaxConnectionsPerTenant: getIntEnv("STREAM_MAX_CONNECTIONS_PER_TENANT", 5),
+			Heartbeat:               getDurationEnv("STREAM_HEARTBEAT", 15*time.Second),
+			WriteTimeout:            getDurationEnv("STREAM_WRITE_TIMEOUT", 30*time.Second),
+			BacklogSize:             getIntEnv("STREAM_BACKLOG_SIZE", 1000),
+			BacklogTTL:              getDurationEnv("STREAM_BACKLOG_TTL", 10*time.Minute),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: getBoolEnv("TRACING_OTLP_INSECURE", true),
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "saas-shortener"),
+			SampleRatio:  getFloatEnv("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Admin: AdminConfig{
+			Token: getEnv("ADMIN_API_TOKEN", ""),
+		},
 	}
 }
 
@@ -101,6 +323,21 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getListEnv 读取逗号分隔的列表，忽略空白项
+func getListEnv(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getIntEnv(key string, defaultValue int) int {
 	if value, exists := os.LookupEnv(key); exists {
 		if intVal, err := strconv.Atoi(value); err == nil {
@@ -110,6 +347,24 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value, exists := os.LookupEnv(key); exists {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,137 @@
+// Package geoip 基于本地 MaxMind 数据库（.mmdb）的 IP 地理位置查询
+// 数据库文件在启动时加载到内存，查询不访问网络，适合放在重定向热路径上；
+// Watch 定期检查文件，替换文件后自动重新加载，不需要重启服务
+package geoip
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+	"go.uber.org/zap"
+)
+
+// 地区、城市名称的最大长度（对应 click_events.region / city 列）
+const maxNameLength = 100
+
+// Location IP 的地理位置，查询不到的字段为空字符串
+type Location struct {
+	Country string // ISO 3166-1 alpha-2 代码（大写）
+	Region  string // 省/州（英文名），Country 数据库没有
+	City    string // 城市（英文名），Country 数据库没有
+}
+
+// Reader 地理位置查询，并发安全
+type Reader struct {
+	path string
+	db   atomic.Pointer[geoip2.Reader]
+
+	// 上次加载时的文件状态，只在 Reload 中访问
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Open 加载 GeoLite2/GeoIP2 Country 或 City 数据库
+func Open(path string) (*Reader, error) {
+	r := &Reader{path: path, stop: make(chan struct{})}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 文件有变化（修改时间或大小不同）时重新加载，返回是否加载了新文件
+// 加载失败时继续使用原来的数据库
+func (r *Reader) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+	if r.db.Load() != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+	// 记录本次看到的文件状态，文件没有再次变化时不重复尝试加载同一个损坏的文件
+	r.modTime, r.size = info.ModTime(), info.Size()
+
+	// 读入内存而不是 mmap：替换后旧数据库可能仍在被查询，交给 GC 回收即可，不需要关闭
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, err
+	}
+	db, err := geoip2.FromBytes(data)
+	if err != nil {
+		return false, err
+	}
+	r.db.Store(db)
+	return true, nil
+}
+
+// Watch 每隔 interval 检查一次数据库文件，变化后重新加载，直到 Close
+func (r *Reader) Watch(interval time.Duration, logger *zap.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+
+			reloaded, err := r.Reload()
+			if err != nil {
+				logger.Warn("GeoIP 数据库重新加载失败，继续使用原来的数据库", zap.String("path", r.path), zap.Error(err))
+				continue
+			}
+			if reloaded {
+				logger.Info("GeoIP 数据库已重新加载", zap.String("path", r.path))
+			}
+		}
+	}()
+}
+
+// Lookup 查询 IP 的地理位置，无法解析或查询不到时返回空的 Location
+func (r *Reader) Lookup(ip string) Location {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}
+	}
+	// City 数据库包含 Country 数据库的全部字段，Country 数据库也可以按 City 查询（没有地区和城市）
+	record, err := r.db.Load().City(parsed)
+	if err != nil {
+		return Location{}
+	}
+
+	loc := Location{
+		Country: strings.ToUpper(record.Country.IsoCode),
+		City:    truncate(record.City.Names["en"]),
+	}
+	if len(record.Subdivisions) > 0 {
+		loc.Region = truncate(record.Subdivisions[0].Names["en"])
+	}
+	return loc
+}
+
+// Close 停止 Watch
+func (r *Reader) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return nil
+}
+
+func truncate(s string) string {
+	if len(s) <= maxNameLength {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxNameLength], "")
+}
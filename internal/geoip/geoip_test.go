@@ -0,0 +1,105 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/geoip/geoiptest"
+)
+
+func writeDatabase(t *testing.T, path string, records []geoiptest.Record) {
+	t.Helper()
+	if err := geoiptest.Write(path, records); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeDatabase(t, path, []geoiptest.Record{
+		{Network: "1.2.3.0/24", Country: "US", Region: "California", City: "San Francisco"},
+		{Network: "5.6.0.0/16", Country: "de"},
+	})
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{"1.2.3.4", Location{Country: "US", Region: "California", City: "San Francisco"}},
+		{"5.6.7.8", Location{Country: "DE"}},
+		{"9.9.9.9", Location{}},
+		{"2001:db8::1", Location{}}, // IPv4 数据库不支持 IPv6
+		{"not-an-ip", Location{}},
+	}
+	for _, tt := range tests {
+		if got := r.Lookup(tt.ip); got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeDatabase(t, path, []geoiptest.Record{{Network: "1.2.3.0/24", Country: "US"}})
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatalf("文件未变化: reloaded = %v, err = %v", reloaded, err)
+	}
+
+	// 替换文件后重新加载（修改时间精度可能不够，显式设置）
+	next := time.Now().Add(time.Minute)
+	writeDatabase(t, path, []geoiptest.Record{{Network: "1.2.3.0/24", Country: "FR", City: "Paris"}})
+	if err := os.Chtimes(path, next, next); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("文件已替换: reloaded = %v, err = %v", reloaded, err)
+	}
+	if got := r.Lookup("1.2.3.4"); got.Country != "FR" || got.City != "Paris" {
+		t.Fatalf("reloaded = %+v", got)
+	}
+
+	// 新文件损坏时继续使用原来的数据库
+	if err := os.WriteFile(path, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("损坏的文件应返回错误")
+	}
+	if got := r.Lookup("1.2.3.4"); got.Country != "FR" {
+		t.Fatalf("after broken reload = %+v", got)
+	}
+
+	// Watch 定期检查
+	r.Watch(10*time.Millisecond, zap.NewNop())
+	later := next.Add(time.Minute)
+	writeDatabase(t, path, []geoiptest.Record{{Network: "1.2.3.0/24", Country: "JP"}})
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Lookup("1.2.3.4").Country != "JP" {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch 没有重新加载数据库")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,208 @@
+// Package geoiptest 生成测试用的 MaxMind 数据库（.mmdb）
+// 只实现 City 数据库中用到的字段（国家、省/州、城市），只支持 IPv4 网段
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+// Record 一个网段的地理位置
+type Record struct {
+	Network string // CIDR，如 "1.2.3.0/24"，网段之间不能重叠
+	Country string // ISO 3166-1 alpha-2 代码
+	Region  string // 省/州（英文名）
+	City    string // 城市（英文名）
+}
+
+// Write 把记录写成 GeoLite2-City 格式的数据库文件
+func Write(path string, records []Record) error {
+	data, err := Build(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Build 生成 GeoLite2-City 格式的数据库内容
+// 格式见 https://maxmind.github.io/MaxMind-DB/：24 位记录的二叉搜索树 + 16 字节分隔 + 数据区 + 元数据
+func Build(records []Record) ([]byte, error) {
+	root := &node{}
+	var data bytes.Buffer
+	for _, record := range records {
+		_, network, err := net.ParseCIDR(record.Network)
+		if err != nil {
+			return nil, err
+		}
+		ip := network.IP.To4()
+		if ip == nil {
+			return nil, fmt.Errorf("只支持 IPv4 网段: %s", record.Network)
+		}
+		ones, _ := network.Mask.Size()
+		if ones == 0 {
+			return nil, fmt.Errorf("网段过大: %s", record.Network)
+		}
+
+		offset := data.Len()
+		writeValue(&data, locationValue(&record))
+
+		n := root
+		for i := 0; i < ones-1; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{}
+			}
+			n = n.children[bit]
+		}
+		bit := ip[(ones-1)/8] >> (7 - (ones-1)%8) & 1
+		n.data[bit] = offset + 1 // 0 表示没有数据
+	}
+
+	// 按广度优先给节点编号，根节点为 0
+	nodes := []*node{root}
+	for i := 0; i < len(nodes); i++ {
+		nodes[i].id = i
+		for _, child := range nodes[i].children {
+			if child != nil {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			record := nodeCount // 空记录
+			switch {
+			case n.children[bit] != nil:
+				record = n.children[bit].id
+			case n.data[bit] > 0:
+				record = nodeCount + 16 + n.data[bit] - 1
+			}
+			out.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	writeValue(&out, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               "GeoLite2-City",
+		"description":                 map[string]any{"en": "saas-shortener test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+	return out.Bytes(), nil
+}
+
+type node struct {
+	id       int
+	children [2]*node
+	data     [2]int // 数据区偏移 + 1
+}
+
+func locationValue(r *Record) map[string]any {
+	value := map[string]any{
+		"country": map[string]any{
+			"iso_code": r.Country,
+			"names":    map[string]any{"en": r.Country},
+		},
+	}
+	if r.Region != "" {
+		value["subdivisions"] = []any{map[string]any{"names": map[string]any{"en": r.Region}}}
+	}
+	if r.City != "" {
+		value["city"] = map[string]any{"names": map[string]any{"en": r.City}}
+	}
+	return value
+}
+
+// MaxMind DB 数据类型
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+// writeValue 按 MaxMind DB 数据区格式编码（不使用指针）
+func writeValue(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case string:
+		writeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case uint16:
+		writeUint(buf, typeUint16, uint64(v))
+	case uint32:
+		writeUint(buf, typeUint32, uint64(v))
+	case uint64:
+		writeUint(buf, typeUint64, v)
+	case []any:
+		writeControl(buf, typeArray, len(v))
+		for _, item := range v {
+			writeValue(buf, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeControl(buf, typeMap, len(v))
+		for _, key := range keys {
+			writeValue(buf, key)
+			writeValue(buf, v[key])
+		}
+	default:
+		panic(fmt.Sprintf("geoiptest: 不支持的类型 %T", value))
+	}
+}
+
+func writeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	n := 8
+	for n > 0 && b[8-n] == 0 {
+		n--
+	}
+	writeControl(buf, typ, n)
+	buf.Write(b[8-n:])
+}
+
+// writeControl 写入控制字节：高 3 位为类型（扩展类型写 0，并在下一个字节写入 类型-7），低 5 位为长度
+func writeControl(buf *bytes.Buffer, typ, size int) {
+	var sizeBits byte
+	var extra []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits, extra = 29, []byte{byte(size - 29)}
+	case size < 65821:
+		n := size - 285
+		sizeBits, extra = 30, []byte{byte(n >> 8), byte(n)}
+	default:
+		n := size - 65821
+		sizeBits, extra = 31, []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+
+	if typ <= 7 {
+		buf.WriteByte(byte(typ)<<5 | sizeBits)
+	} else {
+		buf.WriteByte(sizeBits)
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(extra)
+}
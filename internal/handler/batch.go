@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 批量创建与导入 ====================
+
+// BatchCreateShortURLs 批量创建短链接
+// POST /api/v1/urls/batch
+// 单条失败不影响其他记录，响应中按请求顺序逐条返回结果；配额不足时整批拒绝
+func (h *Handler) BatchCreateShortURLs(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var req model.BatchCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.CreateShortURLs(c.Request.Context(), tenant.ID, req.Items)
+	if err != nil {
+		h.respondBatchError(c, err, "批量创建失败")
+		return
+	}
+
+	middleware.RecordURLsCreated(tenant.ID.String(), tenant.Plan, resp.Created)
+	c.JSON(http.StatusOK, resp)
+}
+
+// ImportShortURLs 从上传的文件导入短链接
+// POST /api/v1/urls/import?format=csv|jsonl
+// 请求体即文件内容，边读边写入；未指定 format 时按 Content-Type 判断
+func (h *Handler) ImportShortURLs(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, h.cfg.Batch.ImportMaxBytes)
+	var src service.ImportSource
+	switch importFormat(c) {
+	case "csv":
+		src = service.NewCSVImportSource(body)
+	case "jsonl":
+		src = service.NewJSONLinesImportSource(body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "不支持的文件格式",
+			"message": "format 必须是 csv 或 jsonl，或使用 Content-Type text/csv / application/x-ndjson",
+		})
+		return
+	}
+
+	resp, err := h.svc.ImportShortURLs(c.Request.Context(), tenant.ID, src)
+	if resp != nil {
+		middleware.RecordURLsCreated(tenant.ID.String(), tenant.Plan, resp.Created)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			// 已导入的记录不回滚，返回已处理部分的结果
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "文件过大",
+				"message": err.Error(),
+				"result":  resp,
+			})
+			return
+		}
+		if resp != nil {
+			h.logger.Warn("导入中断", zap.String("tenant_id", tenant.ID.String()), zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "导入中断",
+				"message": err.Error(),
+				"result":  resp,
+			})
+			return
+		}
+		h.respondBatchError(c, err, "导入失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// importFormat 根据 format 参数或 Content-Type 判断导入文件格式
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return "jsonl"
+	}
+	return ""
+}
+
+// respondBatchError 将批量创建/导入的整体错误映射为 HTTP 响应
+func (h *Handler) respondBatchError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "配额不足",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrBatchTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 自定义域名 ====================
+
+// CreateDomain 添加自定义域名，响应中包含所有权验证说明
+// POST /api/v1/domains
+func (h *Handler) CreateDomain(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var req model.CreateDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.CreateDomain(c.Request.Context(), tenant.ID, &req)
+	if err != nil {
+		h.respondDomainError(c, err, "添加域名失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListDomains 查询自定义域名列表
+// GET /api/v1/domains
+func (h *Handler) ListDomains(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	domains, err := h.svc.ListDomains(c.Request.Context(), tenant.ID)
+	if err != nil {
+		h.respondDomainError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": domains,
+	})
+}
+
+// VerifyDomain 验证域名所有权
+// POST /api/v1/domains/:id/verify（可选请求体 {"method": "dns" | "http"}）
+func (h *Handler) VerifyDomain(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的域名 ID")
+	if !ok {
+		return
+	}
+	var req model.VerifyDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.VerifyDomain(c.Request.Context(), tenant.ID, id, req.Method)
+	if err != nil {
+		h.respondDomainError(c, err, "验证失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteDomain 删除自定义域名（域名下仍有短链接时返回 409）
+// DELETE /api/v1/domains/:id
+func (h *Handler) DeleteDomain(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的域名 ID")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteDomain(c.Request.Context(), tenant.ID, id); err != nil {
+		h.respondDomainError(c, err, "删除失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondDomainError 将自定义域名接口的业务错误映射为 HTTP 响应
+func (h *Handler) respondDomainError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrDomainNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "域名不存在",
+		})
+	case errors.Is(err, service.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrDomainLimit):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "配额不足",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrDomainExists), errors.Is(err, service.ErrDomainTaken),
+		errors.Is(err, service.ErrDomainInUse):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   msg,
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrVerificationFailed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   msg,
+			"message": err.Error(),
+		})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+	}
+}
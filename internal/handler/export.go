@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 数据导出 ====================
+
+// ExportShortURLs 导出租户的全部短链接
+// GET /api/v1/export/urls?format=csv|jsonl&gzip=true
+func (h *Handler) ExportShortURLs(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	opts, ok := parseExportOptions(c)
+	if !ok {
+		return
+	}
+
+	export, err := h.svc.ExportShortURLs(c.Request.Context(), tenant.ID, opts)
+	if err != nil {
+		h.logger.Error("导出失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+	h.streamExport(c, export)
+}
+
+// ExportClicks 导出租户的点击事件
+// GET /api/v1/export/clicks?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&format=csv|jsonl&gzip=true
+func (h *Handler) ExportClicks(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	opts, ok := parseExportOptions(c)
+	if !ok {
+		return
+	}
+	from, ok := parseTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseTimeQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	export, err := h.svc.ExportClicks(c.Request.Context(), tenant.ID, from, to, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTimeRange) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.logger.Error("导出失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+	h.streamExport(c, export)
+}
+
+// streamExport 写出响应头后流式输出导出数据
+// 导出可能持续数分钟，单独放宽本次响应的写入超时；响应头发出后无法再返回错误状态码，
+// 中途失败时直接断开连接，避免客户端把截断的数据当成完整文件
+func (h *Handler) streamExport(c *gin.Context, export *service.Export) {
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetWriteDeadline(time.Now().Add(h.cfg.Export.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.Warn("设置导出写入超时失败", zap.Error(err))
+	}
+
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename}))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	if err := export.WriteTo(c.Request.Context(), c.Writer); err != nil {
+		h.logger.Error("导出中断", zap.String("file", export.Filename), zap.Error(err))
+		if conn, _, err := rc.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// parseExportOptions 解析 format 和 gzip 参数，失败时已写入 400 响应
+func parseExportOptions(c *gin.Context) (service.ExportOptions, bool) {
+	format, err := service.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return service.ExportOptions{}, false
+	}
+
+	var gzip bool
+	if v := c.Query("gzip"); v != "" {
+		if gzip, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": "gzip 必须是 true 或 false",
+			})
+			return service.ExportOptions{}, false
+		}
+	}
+	return service.ExportOptions{Format: format, Gzip: gzip}, true
+}
@@ -4,13 +4,18 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/yourname/saas-shortener/internal/config"
 	"github.com/yourname/saas-shortener/internal/middleware"
 	"github.com/yourname/saas-shortener/internal/model"
 	"github.com/yourname/saas-shortener/internal/service"
@@ -19,13 +24,15 @@ import (
 // Handler HTTP 处理器
 type Handler struct {
 	svc    *service.Service
+	cfg    *config.Config
 	logger *zap.Logger
 }
 
 // New 创建 Handler 实例
-func New(svc *service.Service, logger *zap.Logger) *Handler {
+func New(svc *service.Service, cfg *config.Config, logger *zap.Logger) *Handler {
 	return &Handler{
 		svc:    svc,
+		cfg:    cfg,
 		logger: logger,
 	}
 }
@@ -53,23 +60,83 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 
 	// 短链接重定向（这是访问量最大的端点）
 	r.GET("/:code", h.Redirect)
+	r.GET("/:code/*rest", h.Redirect)  // 路径后缀转发（短链接开启 forward_path 时）
+	r.POST("/:code", h.UnlockShortURL) // 提交密码保护短链接的访问密码；其他链接按重定向处理（307 / 308 保留表单）
+	r.POST("/:code/*rest", h.UnlockShortURL)
 
 	// 租户注册（创建新租户获取 API Key）
 	r.POST("/api/v1/tenants", h.CreateTenant)
 
+	// ==================== 平台管理 API（管理令牌认证）====================
+	admin := r.Group("/api/v1/admin", middleware.AdminAuth(h.cfg.Admin.Token, h.logger))
+	{
+		admin.GET("/plans", h.ListPlans)           // 查询套餐目录
+		admin.POST("/plans", h.CreatePlan)         // 创建套餐
+		admin.GET("/plans/:name", h.GetPlan)       // 查询单个套餐
+		admin.PUT("/plans/:name", h.UpdatePlan)    // 修改套餐（同步该套餐下的租户配额）
+		admin.DELETE("/plans/:name", h.DeletePlan) // 删除套餐
+	}
+
 	// ==================== 需要认证的 API ====================
 	// 使用中间件链：认证 → 限流 → 处理请求
 	api := r.Group("/api/v1")
 	api.Use(
-		middleware.TenantAuth(h.svc, h.logger),   // 第1步：认证租户
-		middleware.RateLimit(h.svc, h.logger),     // 第2步：检查限流
+		middleware.TenantAuth(h.svc, h.logger), // 第1步：认证租户
+		middleware.RateLimit(h.svc, h.logger),  // 第2步：检查限流
 	)
 	{
+		// 每个路由声明所需的 API Key 权限范围（第3步：权限检查）
+		read := middleware.RequireScope(model.ScopeURLsRead)
+		write := middleware.RequireScope(model.ScopeURLsWrite)
+		stats := middleware.RequireScope(model.ScopeStatsRead)
+		tenantAdmin := middleware.RequireScope(model.ScopeAdmin)
+
 		// 短链接 CRUD
-		api.POST("/urls", h.CreateShortURL)       // 创建短链接
-		api.GET("/urls", h.ListShortURLs)          // 查询短链接列表
-		api.GET("/stats", h.GetStats)              // 获取统计信息
+		api.POST("/urls", write, h.CreateShortURL)              // 创建短链接
+		api.POST("/urls/batch", write, h.BatchCreateShortURLs)  // 批量创建短链接
+		api.POST("/urls/import", write, h.ImportShortURLs)      // 从 CSV / JSON Lines 文件导入短链接
+		api.GET("/urls", read, h.ListShortURLs)                 // 查询短链接列表
+		api.GET("/urls/:id", read, h.GetShortURL)               // 查询单个短链接
+		api.PATCH("/urls/:id", write, h.UpdateShortURL)         // 修改短链接（目标地址、启用/停用）
+		api.DELETE("/urls/:id", write, h.DeleteShortURL)        // 删除短链接
+		api.GET("/urls/:id/clicks", stats, h.GetClickAnalytics) // 单个短链接的点击分析
+		api.GET("/urls/:id/qr", read, h.GetQRCode)              // 单个短链接的二维码（PNG / SVG）
+		api.GET("/stats", stats, h.GetStats)                    // 获取统计信息
+
+		// 实时点击流（Server-Sent Events）
+		api.GET("/stream/clicks", stats, h.StreamClicks) // 推送租户的实时点击，可按 url_id 过滤
+
+		// 数据导出（流式输出 CSV / JSON Lines，可选 gzip）
+		api.GET("/export/urls", read, h.ExportShortURLs) // 导出全部短链接
+		api.GET("/export/clicks", stats, h.ExportClicks) // 导出时间范围内的点击事件
+
+		// API Key 管理（需要 admin 权限）
+		api.POST("/keys", tenantAdmin, h.CreateAPIKey)            // 创建 Key
+		api.GET("/keys", tenantAdmin, h.ListAPIKeys)              // 查询 Key 列表
+		api.POST("/keys/:id/rotate", tenantAdmin, h.RotateAPIKey) // 轮换 Key
+		api.DELETE("/keys/:id", tenantAdmin, h.RevokeAPIKey)      // 吊销 Key
+
+		// 自定义域名（添加、验证、删除需要 admin 权限）
+		api.POST("/domains", tenantAdmin, h.CreateDomain)            // 添加域名
+		api.GET("/domains", read, h.ListDomains)                     // 查询域名列表
+		api.POST("/domains/:id/verify", tenantAdmin, h.VerifyDomain) // 验证所有权（DNS TXT / HTTP 文件）
+		api.DELETE("/domains/:id", tenantAdmin, h.DeleteDomain)      // 删除域名
+
+		// Webhook 事件推送（需要 admin 权限）
+		api.POST("/webhooks", tenantAdmin, h.CreateWebhook)                                          // 添加 Webhook
+		api.GET("/webhooks", tenantAdmin, h.ListWebhooks)                                            // 查询 Webhook 列表
+		api.PATCH("/webhooks/:id", tenantAdmin, h.UpdateWebhook)                                     // 修改地址、订阅事件、启用/停用
+		api.DELETE("/webhooks/:id", tenantAdmin, h.DeleteWebhook)                                    // 删除 Webhook
+		api.GET("/webhooks/:id/deliveries", tenantAdmin, h.ListWebhookDeliveries)                    // 查询投递记录
+		api.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", tenantAdmin, h.RedeliverWebhook) // 重新投递
+
+		// 套餐
+		api.GET("/plans", h.ListPlans)                            // 查询可选套餐
+		api.POST("/tenant/plan", tenantAdmin, h.ChangeTenantPlan) // 升级/降级套餐（需要 admin 权限）
 	}
+
+	// 所有已注册路由的第一段路径都不能作为短码，否则对应的短链接会被路由遮蔽
+	h.svc.ReserveCodes(routePrefixes(r.Routes())...)
 }
 
 // ==================== 健康检查处理器 ====================
@@ -118,6 +185,13 @@ func (h *Handler) CreateTenant(c *gin.Context) {
 
 	resp, err := h.svc.CreateTenant(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrPlanNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "创建失败",
 			"message": err.Error(),
@@ -128,6 +202,103 @@ func (h *Handler) CreateTenant(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+// ==================== API Key 处理器 ====================
+
+// CreateAPIKey 创建 API Key
+// POST /api/v1/keys
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var req model.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.CreateAPIKey(c.Request.Context(), tenant.ID, &req)
+	if err != nil {
+		h.respondAPIKeyError(c, err, "创建失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListAPIKeys 查询 API Key 列表
+// GET /api/v1/keys
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	keys, err := h.svc.ListAPIKeys(c.Request.Context(), tenant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keys,
+	})
+}
+
+// RotateAPIKey 轮换 API Key
+// POST /api/v1/keys/:id/rotate
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 API Key ID")
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.RotateAPIKey(c.Request.Context(), tenant.ID, id)
+	if err != nil {
+		h.respondAPIKeyError(c, err, "轮换失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeAPIKey 吊销 API Key
+// DELETE /api/v1/keys/:id
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 API Key ID")
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.RevokeAPIKey(c.Request.Context(), tenant.ID, id)
+	if err != nil {
+		h.respondAPIKeyError(c, err, "吊销失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 // ==================== 短链接处理器 ====================
 
 // CreateShortURL 创建短链接
@@ -157,6 +328,36 @@ func (h *Handler) CreateShortURL(c *gin.Context) {
 			})
 			return
 		}
+		var taken *service.CodeTakenError
+		if errors.As(err, &taken) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "短码已被占用",
+				"message":    err.Error(),
+				"suggestion": taken.Suggestion,
+			})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCode) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, service.ErrCustomCodeDenied) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "套餐不支持",
+				"message": err.Error(),
+			})
+			return
+		}
+		if service.IsInvalidInput(err) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "创建失败",
 			"message": err.Error(),
@@ -171,7 +372,11 @@ func (h *Handler) CreateShortURL(c *gin.Context) {
 }
 
 // ListShortURLs 查询短链接列表
-// GET /api/v1/urls?page=1&page_size=20
+// GET /api/v1/urls?page_size=20&cursor=<next_cursor>&include_total=false
+// 筛选：q（短码/原始 URL/标题子串）、tag、folder、active、expired、created_after、created_before
+// 排序：sort=created_at|clicks|code，order=asc|desc
+// 点击数：clicks 默认不含机器人点击，include_bots=true 时包含（排序始终按不含机器人的点击数）
+// 分页：响应中的 next_cursor / prev_cursor 原样传回 cursor 参数翻页，为 null 表示没有更多数据
 func (h *Handler) ListShortURLs(c *gin.Context) {
 	tenant := middleware.GetTenantFromContext(c)
 	if tenant == nil {
@@ -179,27 +384,175 @@ func (h *Handler) ListShortURLs(c *gin.Context) {
 		return
 	}
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	// OFFSET 分页已移除，静默忽略 page 会让客户端反复拿到第一页
+	if page := c.Query("page"); page != "" && page != "1" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": "page 参数已不再支持，请使用响应中的 next_cursor 作为 cursor 参数翻页",
+		})
+		return
+	}
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	includeTotal, ok := parseBoolQuery(c, "include_total")
+	if !ok {
+		return
+	}
+
+	filter, ok := parseURLListFilter(c)
+	if !ok {
+		return
+	}
 
-	urls, total, err := h.svc.ListShortURLs(c.Request.Context(), tenant.ID, page, pageSize)
+	req := &model.PageRequest{
+		Cursor:       c.Query("cursor"),
+		PageSize:     pageSize,
+		IncludeTotal: includeTotal == nil || *includeTotal,
+	}
+	resp, err := h.svc.ListShortURLs(c.Request.Context(), tenant.ID, filter, req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "查询失败",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":      urls,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetShortURL 查询单个短链接
+// GET /api/v1/urls/:id?include_bots=true
+func (h *Handler) GetShortURL(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseURLID(c)
+	if !ok {
+		return
+	}
+
+	includeBots, ok := parseIncludeBots(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.GetShortURL(c.Request.Context(), tenant.ID, id, includeBots)
+	if err != nil {
+		h.respondURLError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateShortURL 修改短链接
+// PATCH /api/v1/urls/:id
+func (h *Handler) UpdateShortURL(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseURLID(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateShortURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.UpdateShortURL(c.Request.Context(), tenant.ID, id, &req)
+	if err != nil {
+		h.respondURLError(c, err, "更新失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteShortURL 删除短链接
+// DELETE /api/v1/urls/:id
+func (h *Handler) DeleteShortURL(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseURLID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteShortURL(c.Request.Context(), tenant.ID, id); err != nil {
+		h.respondURLError(c, err, "删除失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetClickAnalytics 查询单个短链接的点击分析
+// GET /api/v1/urls/:id/clicks?from=2024-01-01T00:00:00Z&to=2024-01-08T00:00:00Z&interval=day&include_bots=false
+func (h *Handler) GetClickAnalytics(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseURLID(c)
+	if !ok {
+		return
+	}
+
+	from, ok := parseTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseTimeQuery(c, "to")
+	if !ok {
+		return
+	}
+	includeBots, ok := parseIncludeBots(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.svc.GetClickAnalytics(c.Request.Context(), tenant.ID, id, from, to, c.Query("interval"), includeBots)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTimeRange) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.respondURLError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetStats 获取统计信息
-// GET /api/v1/stats
+// GET /api/v1/stats?include_bots=true
 func (h *Handler) GetStats(c *gin.Context) {
 	tenant := middleware.GetTenantFromContext(c)
 	if tenant == nil {
@@ -207,7 +560,12 @@ func (h *Handler) GetStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := h.svc.GetStats(c.Request.Context(), tenant.ID)
+	includeBots, ok := parseIncludeBots(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.svc.GetStats(c.Request.Context(), tenant.ID, includeBots)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "查询失败",
@@ -218,37 +576,249 @@ func (h *Handler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// A/B 分流 Cookie，Path 限定为 /<code>，记录访问者分配到的目标 ID
+const (
+	linkVariantCookie    = "link_variant"
+	linkVariantCookieTTL = 30 * 24 * time.Hour
+)
+
 // Redirect 短链接重定向
-// GET /:code
+// GET /:code、GET /:code/*rest
+// 状态码由短链接的 redirect_status 决定（默认 302）
 func (h *Handler) Redirect(c *gin.Context) {
 	code := c.Param("code")
 
-	// 排除基础设施路径
-	if code == "healthz" || code == "readyz" || code == "metrics" || code == "api" {
-		c.Next()
+	// 保留字（路由前缀等）不可能是短码
+	if h.svc.IsReservedCode(code) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "链接不存在",
+			"code":  "URL_NOT_FOUND",
+		})
 		return
 	}
 
-	originalURL, err := h.svc.Redirect(
-		c.Request.Context(),
-		code,
-		c.ClientIP(),
-		c.Request.UserAgent(),
-		c.Request.Referer(),
-	)
+	accessToken, _ := c.Cookie(linkAccessCookie)
+	variantID, _ := c.Cookie(linkVariantCookie)
+	result, err := h.svc.Redirect(c.Request.Context(), &service.RedirectRequest{
+		Host:           c.Request.Host,
+		Code:           code,
+		IP:             c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		Referer:        c.Request.Referer(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+		Query:          c.Request.URL.Query(),
+		Suffix:         redirectSuffix(c),
+		AccessToken:    accessToken,
+		VariantID:      variantID,
+	})
 	if err != nil {
-		if err == service.ErrURLNotFound || err == service.ErrURLExpired {
+		// 不同原因使用不同的状态码和错误码，方便调用方区分
+		// 410 Gone 表示链接曾经存在但已永久失效；尚未生效的链接对外仍按 404 处理
+		switch {
+		case errors.Is(err, service.ErrURLNotFound):
 			c.JSON(http.StatusNotFound, gin.H{
-				"error": "链接不存在或已过期",
+				"error": "链接不存在",
+				"code":  "URL_NOT_FOUND",
+			})
+		case errors.Is(err, service.ErrURLNotYetActive):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "链接尚未生效",
+				"code":  "URL_NOT_YET_ACTIVE",
+			})
+		case errors.Is(err, service.ErrURLExpired):
+			c.JSON(http.StatusGone, gin.H{
+				"error": "链接已过期",
+				"code":  "URL_EXPIRED",
+			})
+		case errors.Is(err, service.ErrClickLimitReached):
+			c.JSON(http.StatusGone, gin.H{
+				"error": "链接点击次数已达上限",
+				"code":  "URL_CLICK_LIMIT_REACHED",
+			})
+		case errors.Is(err, service.ErrPasswordRequired):
+			// 需要密码的链接返回输入页而不是 302
+			renderPasswordForm(c, http.StatusOK, "")
+		default:
+			h.logger.Error("重定向失败", zap.String("code", code), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "服务器错误",
 			})
-			return
 		}
+		return
+	}
+
+	// A/B 分流：记录分配到的目标，同一访问者之后继续访问该目标
+	if result.VariantID != "" && result.VariantID != variantID {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     linkVariantCookie,
+			Value:    result.VariantID,
+			Path:     "/" + code,
+			MaxAge:   int(linkVariantCookieTTL.Seconds()),
+			Secure:   isHTTPS(c),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	c.Redirect(result.Status, result.URL)
+}
+
+// redirectSuffix 返回 /:code/*rest 中的路径后缀，只有末尾斜杠（/:code/）时视为没有后缀
+func redirectSuffix(c *gin.Context) string {
+	rest := c.Param("rest")
+	if rest == "/" {
+		return ""
+	}
+	return rest
+}
+
+// ==================== 辅助函数 ====================
+
+// parseURLID 解析路径参数中的短链接 ID，格式错误时直接返回 400
+func parseURLID(c *gin.Context) (uuid.UUID, bool) {
+	return parseIDParam(c, "无效的短链接 ID")
+}
+
+// parseIDParam 解析路径参数 :id 为 UUID，格式错误时直接返回 400
+func parseIDParam(c *gin.Context, message string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": message,
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseURLListFilter 解析列表筛选参数，失败时已写入 400 响应
+func parseURLListFilter(c *gin.Context) (*model.URLListFilter, bool) {
+	filter := &model.URLListFilter{
+		Query:  c.Query("q"),
+		Tag:    c.Query("tag"),
+		Folder: c.Query("folder"),
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+	}
+
+	var ok bool
+	if filter.Active, ok = parseBoolQuery(c, "active"); !ok {
+		return nil, false
+	}
+	if filter.Expired, ok = parseBoolQuery(c, "expired"); !ok {
+		return nil, false
+	}
+	if filter.CreatedAfter, ok = parseTimeQuery(c, "created_after"); !ok {
+		return nil, false
+	}
+	if filter.CreatedBefore, ok = parseTimeQuery(c, "created_before"); !ok {
+		return nil, false
+	}
+	if filter.IncludeBots, ok = parseIncludeBots(c); !ok {
+		return nil, false
+	}
+	return filter, true
+}
+
+// parseBoolQuery 解析可选的布尔查询参数，未传时返回 nil
+func parseBoolQuery(c *gin.Context, name string) (*bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": name + " 必须是 true 或 false",
+		})
+		return nil, false
+	}
+	return &b, true
+}
+
+// parseIncludeBots 解析 include_bots 参数，默认 false（点击数不含机器人）
+func parseIncludeBots(c *gin.Context) (bool, bool) {
+	includeBots, ok := parseBoolQuery(c, "include_bots")
+	return includeBots != nil && *includeBots, ok
+}
+
+// parseTimeQuery 解析 RFC 3339 格式的时间查询参数，未提供时返回 nil，格式错误时直接返回 400
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": name + " 必须是 RFC 3339 格式，如 2024-01-01T00:00:00Z",
+		})
+		return nil, false
+	}
+	return &t, true
+}
+
+// routePrefixes 返回路由路径的第一段（去重，忽略参数和通配段）
+func routePrefixes(routes gin.RoutesInfo) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, route := range routes {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route.Path, "/"), "/")
+		if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") || seen[segment] {
+			continue
+		}
+		seen[segment] = true
+		prefixes = append(prefixes, segment)
+	}
+	return prefixes
+}
+
+// respondAPIKeyError 将 API Key 管理接口的业务错误映射为 HTTP 响应
+func (h *Handler) respondAPIKeyError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "API Key 不存在",
+		})
+	case errors.Is(err, service.ErrInvalidScope), errors.Is(err, service.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrLastAdminKey):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   msg,
+			"message": err.Error(),
+		})
+	default:
+		h.logger.Error(msg, zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "服务器错误",
+			"error": msg,
 		})
-		return
 	}
+}
 
-	// 302 临时重定向（也可以用 301 永久重定向，但 302 更灵活）
-	c.Redirect(http.StatusFound, originalURL)
+// respondURLError 将短链接管理接口的业务错误映射为 HTTP 响应
+// 其他租户的短链接同样返回 404，不暴露其是否存在
+func (h *Handler) respondURLError(c *gin.Context, err error, msg string) {
+	if errors.Is(err, service.ErrURLNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "短链接不存在",
+		})
+		return
+	}
+	if service.IsInvalidInput(err) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.logger.Error(msg, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": msg,
+	})
 }
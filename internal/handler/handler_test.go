@@ -0,0 +1,1029 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+const testAdminToken = "test-admin-token"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer 基于内存存储的完整路由，用于端到端的 HTTP 测试
+type testServer struct {
+	router *gin.Engine
+	svc    *service.Service
+	txt    staticTXT // 自定义域名验证使用的 DNS TXT 记录
+}
+
+// staticTXT 固定结果的 DNS TXT 查询
+type staticTXT map[string][]string
+
+func (r staticTXT) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r[name], nil
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	store := repository.NewMemoryStore()
+	clicks := service.NewClickPipeline(store, config.ClickConfig{Workers: 1}, zap.NewNop())
+	clicks.Start()
+	t.Cleanup(func() { clicks.Shutdown(context.Background()) })
+
+	cfg := config.Load()
+	cfg.Admin.Token = testAdminToken
+	codes, err := service.NewCodePolicy(cfg.ShortCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := staticTXT{}
+	svc := service.New(store, clicks, codes, nil, service.NewDomainVerifier(txt, nil), cfg, zap.NewNop())
+	router := gin.New()
+	New(svc, cfg, zap.NewNop()).RegisterRoutes(router)
+
+	return &testServer{router: router, svc: svc, txt: txt}
+}
+
+// do 发送请求并返回响应；body 非 nil 时编码为 JSON
+func (s *testServer) do(t *testing.T, method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.doWithHeaders(t, method, path, map[string]string{"X-API-Key": apiKey}, body)
+}
+
+// doAdmin 使用平台管理令牌发送请求
+func (s *testServer) doAdmin(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.doWithHeaders(t, method, path, map[string]string{"X-Admin-Token": testAdminToken}, body)
+}
+
+func (s *testServer) doWithHeaders(t *testing.T, method, path string, headers map[string]string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// createTenant 通过 API 注册租户，返回 admin Key
+func (s *testServer) createTenant(t *testing.T) string {
+	t.Helper()
+
+	w := s.do(t, http.MethodPost, "/api/v1/tenants", "", gin.H{"name": "acme"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create tenant status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp model.CreateTenantResponse
+	decode(t, w, &resp)
+	return resp.APIKey
+}
+
+// createURL 通过 API 创建短链接
+func (s *testServer) createURL(t *testing.T, apiKey string, body gin.H) model.ShortURLResponse {
+	t.Helper()
+
+	w := s.do(t, http.MethodPost, "/api/v1/urls", apiKey, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create url status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp model.ShortURLResponse
+	decode(t, w, &resp)
+	return resp
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		t.Run(path, func(t *testing.T) {
+			if w := s.do(t, http.MethodGet, path, "", nil); w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthAndScopes(t *testing.T) {
+	s := newTestServer(t)
+	adminKey := s.createTenant(t)
+
+	w := s.do(t, http.MethodPost, "/api/v1/keys", adminKey, gin.H{"name": "ci", "scopes": []string{model.ScopeURLsRead}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key status = %d, body = %s", w.Code, w.Body)
+	}
+	var readKey model.CreateAPIKeyResponse
+	decode(t, w, &readKey)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		apiKey string
+		body   interface{}
+		want   int
+	}{
+		{"缺少 Key", http.MethodGet, "/api/v1/urls", "", nil, http.StatusUnauthorized},
+		{"无效 Key", http.MethodGet, "/api/v1/urls", "bogus", nil, http.StatusUnauthorized},
+		{"只读 Key 可以查询", http.MethodGet, "/api/v1/urls", readKey.Key, nil, http.StatusOK},
+		{"只读 Key 不能创建", http.MethodPost, "/api/v1/urls", readKey.Key, gin.H{"url": "https://example.com"}, http.StatusForbidden},
+		{"只读 Key 不能查统计", http.MethodGet, "/api/v1/stats", readKey.Key, nil, http.StatusForbidden},
+		{"只读 Key 不能管理 Key", http.MethodGet, "/api/v1/keys", readKey.Key, nil, http.StatusForbidden},
+		{"admin Key 拥有所有权限", http.MethodGet, "/api/v1/stats", adminKey, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(t, tt.method, tt.path, tt.apiKey, tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	t.Run("吊销后立即失效", func(t *testing.T) {
+		if w := s.do(t, http.MethodDelete, "/api/v1/keys/"+readKey.ID.String(), adminKey, nil); w.Code != http.StatusOK {
+			t.Fatalf("revoke status = %d, body = %s", w.Code, w.Body)
+		}
+		if w := s.do(t, http.MethodGet, "/api/v1/urls", readKey.Key, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", w.Code)
+		}
+	})
+}
+
+func TestCreateShortURLValidation(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	tests := []struct {
+		name string
+		body gin.H
+		want int
+	}{
+		{"正常创建", gin.H{"url": "https://example.com"}, http.StatusCreated},
+		{"缺少 url", gin.H{}, http.StatusBadRequest},
+		{"url 格式错误", gin.H{"url": "not a url"}, http.StatusBadRequest},
+		{"max_clicks 为负数", gin.H{"url": "https://example.com", "max_clicks": -1}, http.StatusBadRequest},
+		{"有效期冲突", gin.H{"url": "https://example.com", "expires_in": "1h", "expires_at": time.Now().Add(time.Hour)}, http.StatusBadRequest},
+		{"自定义短码", gin.H{"url": "https://example.com", "custom_code": "launch"}, http.StatusCreated},
+		{"自定义短码已被占用", gin.H{"url": "https://example.com", "custom_code": "launch"}, http.StatusConflict},
+		{"自定义短码与路由冲突", gin.H{"url": "https://example.com", "custom_code": "readyz"}, http.StatusBadRequest},
+		{"自定义短码超长", gin.H{"url": "https://example.com", "custom_code": "a-very-long-code"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(t, http.MethodPost, "/api/v1/urls", apiKey, tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	active := s.createURL(t, apiKey, gin.H{"url": "https://example.com/landing"})
+	scheduled := s.createURL(t, apiKey, gin.H{"url": "https://example.com", "not_before": time.Now().Add(time.Hour)})
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantLocation string
+		wantCode     string
+	}{
+		{"正常重定向", "/" + active.Code, http.StatusFound, "https://example.com/landing", ""},
+		{"不存在", "/missing", http.StatusNotFound, "", "URL_NOT_FOUND"},
+		{"尚未生效", "/" + scheduled.Code, http.StatusNotFound, "", "URL_NOT_YET_ACTIVE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(t, http.MethodGet, tt.path, "", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if loc := w.Header().Get("Location"); loc != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", loc, tt.wantLocation)
+			}
+			if tt.wantCode != "" {
+				var body map[string]string
+				decode(t, w, &body)
+				if body["code"] != tt.wantCode {
+					t.Errorf("code = %q, want %q", body["code"], tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	created := s.createURL(t, apiKey, gin.H{
+		"url": "https://example.com",
+		"rules": []gin.H{
+			{"id": "ref", "url": "https://example.com/partner", "query": gin.H{"ref": "*"}},
+			{"id": "fr", "url": "https://example.fr", "languages": []string{"fr"}},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		lang    string
+		wantLoc string
+	}{
+		{"查询参数", "/" + created.Code + "?ref=abc", "", "https://example.com/partner"},
+		{"Accept-Language", "/" + created.Code, "fr-FR,fr;q=0.9", "https://example.fr"},
+		{"默认地址", "/" + created.Code, "en-US", "https://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.doWithHeaders(t, http.MethodGet, tt.path, map[string]string{"Accept-Language": tt.lang}, nil)
+			if w.Code != http.StatusFound || w.Header().Get("Location") != tt.wantLoc {
+				t.Fatalf("status = %d, Location = %q, want %q", w.Code, w.Header().Get("Location"), tt.wantLoc)
+			}
+		})
+	}
+
+	w := s.do(t, http.MethodPost, "/api/v1/urls", apiKey, gin.H{"url": "https://example.com", "rules": []gin.H{{"url": "https://a.com"}}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("没有条件的规则 status = %d, want 400", w.Code)
+	}
+}
+
+func TestRedirectVariants(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	created := s.createURL(t, apiKey, gin.H{
+		"url": "https://example.com",
+		"variants": []gin.H{
+			{"id": "a", "url": "https://example.com/a", "weight": 50},
+			{"id": "b", "url": "https://example.com/b", "weight": 50},
+		},
+	})
+
+	// 首次访问分配目标并写入 Cookie
+	w := s.do(t, http.MethodGet, "/"+created.Code, "", nil)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == linkVariantCookie {
+			cookie = c
+		}
+	}
+	if w.Code != http.StatusFound || cookie == nil || cookie.Path != "/"+created.Code ||
+		w.Header().Get("Location") != "https://example.com/"+cookie.Value {
+		t.Fatalf("status = %d, Location = %q, cookie = %+v", w.Code, w.Header().Get("Location"), cookie)
+	}
+
+	// 带 Cookie 访问时沿用 Cookie 中的目标，不再重复写入
+	other := map[string]string{"a": "b", "b": "a"}[cookie.Value]
+	req := httptest.NewRequest(http.MethodGet, "/"+created.Code, nil)
+	req.AddCookie(&http.Cookie{Name: linkVariantCookie, Value: other})
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Header().Get("Location") != "https://example.com/"+other || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("Location = %q, cookies = %v", w.Header().Get("Location"), w.Result().Cookies())
+	}
+
+	w = s.do(t, http.MethodPost, "/api/v1/urls", apiKey, gin.H{"url": "https://example.com", "variants": []gin.H{{"url": "https://a.com", "weight": 0}}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("权重都为 0 status = %d, want 400", w.Code)
+	}
+}
+
+func TestRedirectOptions(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	docs := s.createURL(t, apiKey, gin.H{
+		"url":             "https://docs.example.com/v2",
+		"redirect_status": http.StatusMovedPermanently,
+		"forward_query":   true,
+		"forward_path":    true,
+		"utm":             gin.H{"utm_source": "short"},
+	})
+	form := s.createURL(t, apiKey, gin.H{"url": "https://forms.example.com/submit", "redirect_status": http.StatusTemporaryRedirect})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantLoc    string
+	}{
+		{"状态码与 UTM", http.MethodGet, "/" + docs.Code, http.StatusMovedPermanently, "https://docs.example.com/v2?utm_source=short"},
+		{"路径后缀与查询参数", http.MethodGet, "/" + docs.Code + "/guide/intro?lang=zh", http.StatusMovedPermanently, "https://docs.example.com/v2/guide/intro?lang=zh&utm_source=short"},
+		{"末尾斜杠不算后缀", http.MethodGet, "/" + form.Code + "/", http.StatusTemporaryRedirect, "https://forms.example.com/submit"},
+		{"未开启 forward_path", http.MethodGet, "/" + form.Code + "/extra", http.StatusNotFound, ""},
+		{"POST 表单按 307 转发", http.MethodPost, "/" + form.Code, http.StatusTemporaryRedirect, "https://forms.example.com/submit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(t, tt.method, tt.path, "", nil)
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLoc {
+				t.Fatalf("status = %d, Location = %q, want %d %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLoc)
+			}
+		})
+	}
+
+	w := s.do(t, http.MethodPost, "/api/v1/urls", apiKey, gin.H{"url": "https://example.com", "redirect_status": 303})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("redirect_status=303 status = %d, want 400", w.Code)
+	}
+}
+
+func TestPasswordProtectedLink(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	created := s.createURL(t, apiKey, gin.H{"url": "https://example.com/internal", "password": "s3cret"})
+	if !created.PasswordProtected {
+		t.Fatal("password_protected = false")
+	}
+
+	// 没有访问凭证时返回密码输入页
+	w := s.do(t, http.MethodGet, "/"+created.Code, "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "" || !strings.Contains(w.Body.String(), `name="password"`) {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	unlock := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/"+created.Code, strings.NewReader("password="+password))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := unlock("wrong"); w.Code != http.StatusForbidden {
+		t.Fatalf("wrong password status = %d", w.Code)
+	}
+
+	w = unlock("s3cret")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/"+created.Code {
+		t.Fatalf("status = %d, Location = %q", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/"+created.Code || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	// 带上 Cookie 后正常重定向
+	req := httptest.NewRequest(http.MethodGet, "/"+created.Code, nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/internal" {
+		t.Fatalf("status = %d, Location = %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// 输错次数超过上限后，即使密码正确也被拒绝
+	for i := 0; i < 5; i++ {
+		unlock("wrong")
+	}
+	if w := unlock("s3cret"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+
+	// 取消密码后直接重定向
+	w = s.do(t, http.MethodPatch, "/api/v1/urls/"+created.ID.String(), apiKey, gin.H{"password": ""})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodGet, "/"+created.Code, "", nil); w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", w.Code)
+	}
+}
+
+func TestShortURLLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	otherKey := s.createTenant(t)
+	created := s.createURL(t, apiKey, gin.H{"url": "https://example.com"})
+	path := "/api/v1/urls/" + created.ID.String()
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		apiKey string
+		body   interface{}
+		want   int
+	}{
+		{"无效 ID", http.MethodGet, "/api/v1/urls/not-a-uuid", apiKey, nil, http.StatusBadRequest},
+		{"查询", http.MethodGet, path, apiKey, nil, http.StatusOK},
+		{"包含机器人点击", http.MethodGet, path + "?include_bots=true", apiKey, nil, http.StatusOK},
+		{"include_bots 非法", http.MethodGet, path + "?include_bots=maybe", apiKey, nil, http.StatusBadRequest},
+		{"其他租户查询", http.MethodGet, path, otherKey, nil, http.StatusNotFound},
+		{"其他租户删除", http.MethodDelete, path, otherKey, nil, http.StatusNotFound},
+		{"修改目标地址", http.MethodPatch, path, apiKey, gin.H{"url": "https://example.org"}, http.StatusOK},
+		{"修改后立即生效", http.MethodGet, "/" + created.Code, "", nil, http.StatusFound},
+		{"点击分析", http.MethodGet, path + "/clicks?interval=hour", apiKey, nil, http.StatusOK},
+		{"点击分析参数错误", http.MethodGet, path + "/clicks?from=yesterday", apiKey, nil, http.StatusBadRequest},
+		{"删除", http.MethodDelete, path, apiKey, nil, http.StatusNoContent},
+		{"删除后查询", http.MethodGet, path, apiKey, nil, http.StatusNotFound},
+		{"删除后重定向", http.MethodGet, "/" + created.Code, "", nil, http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		w := s.do(t, step.method, step.path, step.apiKey, step.body)
+		if w.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d, body = %s", step.name, w.Code, step.want, w.Body)
+		}
+		if step.name == "修改后立即生效" && w.Header().Get("Location") != "https://example.org" {
+			t.Fatalf("%s: Location = %q", step.name, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t) // free 套餐：每分钟 100 次
+
+	for i := 1; i <= 100; i++ {
+		w := s.do(t, http.MethodGet, "/api/v1/urls", apiKey, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, body = %s", i, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "100" {
+			t.Fatalf("X-RateLimit-Limit = %q", got)
+		}
+		if got, want := w.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(100-i); got != want {
+			t.Fatalf("request %d: X-RateLimit-Remaining = %q, want %q", i, got, want)
+		}
+		if w.Header().Get("X-RateLimit-Reset") == "" || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("request %d: unexpected headers %v", i, w.Header())
+		}
+	}
+
+	w := s.do(t, http.MethodGet, "/api/v1/urls", apiKey, nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Unix() {
+		t.Errorf("X-RateLimit-Reset = %q", w.Header().Get("X-RateLimit-Reset"))
+	}
+}
+
+func TestPlanManagement(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com/1"})
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com/2"})
+
+	tiny := gin.H{"name": "tiny", "rate_limit": 10, "max_urls": 1, "features": []string{"webhooks"}}
+
+	steps := []struct {
+		name   string
+		admin  bool // true 使用管理令牌，false 使用租户 admin Key
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"缺少管理令牌", false, http.MethodGet, "/api/v1/admin/plans", nil, http.StatusUnauthorized},
+		{"创建套餐", true, http.MethodPost, "/api/v1/admin/plans", tiny, http.StatusCreated},
+		{"套餐名重复", true, http.MethodPost, "/api/v1/admin/plans", tiny, http.StatusConflict},
+		{"套餐名非法", true, http.MethodPost, "/api/v1/admin/plans", gin.H{"name": "Bad Name", "rate_limit": 1, "max_urls": 1}, http.StatusBadRequest},
+		{"租户查看套餐", false, http.MethodGet, "/api/v1/plans", nil, http.StatusOK},
+		{"降级低于当前用量", false, http.MethodPost, "/api/v1/tenant/plan", gin.H{"plan": "tiny"}, http.StatusConflict},
+		{"未知套餐", false, http.MethodPost, "/api/v1/tenant/plan", gin.H{"plan": "platinum"}, http.StatusNotFound},
+		{"升级", false, http.MethodPost, "/api/v1/tenant/plan", gin.H{"plan": "pro"}, http.StatusOK},
+		{"删除使用中的套餐", true, http.MethodDelete, "/api/v1/admin/plans/pro", nil, http.StatusConflict},
+		{"删除套餐", true, http.MethodDelete, "/api/v1/admin/plans/tiny", nil, http.StatusNoContent},
+		{"删除后查询", true, http.MethodGet, "/api/v1/admin/plans/tiny", nil, http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		var w *httptest.ResponseRecorder
+		if step.admin {
+			w = s.doAdmin(t, step.method, step.path, step.body)
+		} else {
+			w = s.do(t, step.method, step.path, apiKey, step.body)
+		}
+		if w.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d, body = %s", step.name, w.Code, step.want, w.Body)
+		}
+	}
+
+	// 升级后新配额立即生效
+	if got := s.do(t, http.MethodGet, "/api/v1/urls", apiKey, nil).Header().Get("X-RateLimit-Limit"); got != "500" {
+		t.Fatalf("X-RateLimit-Limit after upgrade = %q, want 500", got)
+	}
+
+	// 修改套餐配额同步到该套餐下的租户
+	w := s.doAdmin(t, http.MethodPut, "/api/v1/admin/plans/pro", gin.H{"rate_limit": 600, "max_urls": 10000})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update plan status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := s.do(t, http.MethodGet, "/api/v1/urls", apiKey, nil).Header().Get("X-RateLimit-Limit"); got != "600" {
+		t.Fatalf("X-RateLimit-Limit after plan update = %q, want 600", got)
+	}
+}
+
+func TestCreateTenantUnknownPlan(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do(t, http.MethodPost, "/api/v1/tenants", "", gin.H{"name": "acme", "plan": "platinum"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestBatchCreateShortURLs(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	w := s.do(t, http.MethodPost, "/api/v1/urls/batch", apiKey, gin.H{"items": []gin.H{
+		{"url": "https://a.com", "custom_code": "batch1"},
+		{"url": "not a url"},
+		{"url": "https://b.com", "custom_code": "batch1"},
+	}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp model.BatchCreateResponse
+	decode(t, w, &resp)
+	if resp.Created != 1 || resp.Failed != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if resp.Results[0].URL == nil || resp.Results[1].Error.Code != "INVALID_REQUEST" || resp.Results[2].Error.Code != "CODE_TAKEN" {
+		t.Fatalf("results = %s", w.Body)
+	}
+
+	if w := s.do(t, http.MethodPost, "/api/v1/urls/batch", apiKey, gin.H{"items": []gin.H{}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("空批量 status = %d, want 400", w.Code)
+	}
+}
+
+func TestImportShortURLs(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	importFile := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		want        int
+		created     int
+	}{
+		{"CSV", "/api/v1/urls/import", "text/csv; charset=utf-8", "url,custom_code\nhttps://a.com,imp1\nhttps://b.com,\n", http.StatusOK, 2},
+		{"JSON Lines", "/api/v1/urls/import?format=jsonl", "application/octet-stream", `{"url":"https://c.com"}` + "\n", http.StatusOK, 1},
+		{"格式不支持", "/api/v1/urls/import", "application/json", `[]`, http.StatusUnsupportedMediaType, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := importFile(tt.path, tt.contentType, tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var resp model.ImportResponse
+			decode(t, w, &resp)
+			if resp.Created != tt.created || resp.Failed != 0 {
+				t.Fatalf("resp = %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com", "custom_code": "export1"})
+
+	w := s.do(t, http.MethodGet, "/api/v1/export/urls?format=jsonl", apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+		t.Errorf("Content-Disposition = %s", cd)
+	}
+	var url model.ShortURLResponse
+	decode(t, w, &url)
+	if url.Code != "export1" {
+		t.Fatalf("url = %+v", url)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"点击 CSV", "/api/v1/export/clicks", http.StatusOK},
+		{"格式不支持", "/api/v1/export/urls?format=xml", http.StatusBadRequest},
+		{"gzip 参数错误", "/api/v1/export/urls?gzip=yes", http.StatusBadRequest},
+		{"时间格式错误", "/api/v1/export/clicks?from=yesterday", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(t, http.MethodGet, tt.path, apiKey, nil); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestGetQRCode(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	created := s.createURL(t, apiKey, gin.H{"url": "https://example.com", "custom_code": "qr1"})
+	path := "/api/v1/urls/" + created.ID.String() + "/qr"
+
+	w := s.doWithHeaders(t, http.MethodGet, path+"?size=128", map[string]string{"X-API-Key": apiKey, "X-Forwarded-Proto": "https"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	// 未配置 PUBLIC_BASE_URL 时按请求的协议和 Host 生成
+	if content := w.Header().Get("X-QR-Content"); content != "https://example.com/qr1" {
+		t.Errorf("X-QR-Content = %s", content)
+	}
+	if !strings.HasPrefix(w.Header().Get("Cache-Control"), "private, max-age=") {
+		t.Errorf("Cache-Control = %s", w.Header().Get("Cache-Control"))
+	}
+
+	w = s.do(t, http.MethodGet, path+"?format=svg&ecc=H&margin=0", apiKey, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Fatalf("status = %d, Content-Type = %s, body = %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"尺寸不是整数", path + "?size=big", http.StatusBadRequest},
+		{"尺寸超出上限", path + "?size=100000", http.StatusBadRequest},
+		{"颜色格式错误", path + "?fg=red", http.StatusBadRequest},
+		{"短链接不存在", "/api/v1/urls/00000000-0000-0000-0000-000000000001/qr", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(t, http.MethodGet, tt.path, apiKey, nil); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCustomDomains(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	w := s.do(t, http.MethodPost, "/api/v1/domains", apiKey, gin.H{"hostname": "links.example.com"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var domain model.DomainResponse
+	decode(t, w, &domain)
+	if domain.Verified || domain.Verification == nil {
+		t.Fatalf("domain = %+v", domain)
+	}
+	if w := s.do(t, http.MethodPost, "/api/v1/domains", apiKey, gin.H{"hostname": "links.example.com"}); w.Code != http.StatusConflict {
+		t.Fatalf("重复添加 status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodPost, "/api/v1/domains", apiKey, gin.H{"hostname": "not a domain"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("非法域名 status = %d, body = %s", w.Code, w.Body)
+	}
+
+	// 未验证的域名不能创建短链接
+	if w := s.do(t, http.MethodPost, "/api/v1/urls", apiKey, gin.H{"url": "https://example.com", "domain": "links.example.com"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	verifyPath := "/api/v1/domains/" + domain.ID.String() + "/verify"
+	if w := s.do(t, http.MethodPost, verifyPath, apiKey, gin.H{"method": "dns"}); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	s.txt[domain.Verification.TXTName] = []string{domain.Verification.TXTValue}
+	// 请求体可以省略
+	w = s.do(t, http.MethodPost, verifyPath, apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	decode(t, w, &domain)
+	if !domain.Verified {
+		t.Fatalf("domain = %+v", domain)
+	}
+
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com/default", "custom_code": "launch"})
+	branded := s.createURL(t, apiKey, gin.H{"url": "https://example.com/branded", "custom_code": "launch", "domain": "links.example.com"})
+	if branded.ShortURL != "https://links.example.com/launch" {
+		t.Errorf("short_url = %q", branded.ShortURL)
+	}
+
+	// 同一个短码按请求的 Host 重定向到不同的目标
+	for host, want := range map[string]string{
+		"links.example.com": "https://example.com/branded",
+		"example.com":       "https://example.com/default",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/launch", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("%s: status = %d, Location = %q, want %q", host, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+
+	w = s.do(t, http.MethodGet, "/api/v1/domains", apiKey, nil)
+	var list struct {
+		Data []model.DomainResponse `json:"data"`
+	}
+	decode(t, w, &list)
+	if w.Code != http.StatusOK || len(list.Data) != 1 {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	// 域名下还有短链接时不能删除
+	domainPath := "/api/v1/domains/" + domain.ID.String()
+	if w := s.do(t, http.MethodDelete, domainPath, apiKey, nil); w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodDelete, "/api/v1/urls/"+branded.ID.String(), apiKey, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodDelete, domainPath, apiKey, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodDelete, domainPath, apiKey, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+
+	if w := s.do(t, http.MethodPost, "/api/v1/webhooks", apiKey, gin.H{"url": "https://hooks.example.com"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("缺少 events status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodPost, "/api/v1/webhooks", apiKey, gin.H{"url": "https://hooks.example.com", "events": []string{"url.visited"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("未知事件 status = %d, body = %s", w.Code, w.Body)
+	}
+
+	w := s.do(t, http.MethodPost, "/api/v1/webhooks", apiKey, gin.H{"url": "https://hooks.example.com", "events": []string{model.EventURLCreated}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var webhook model.CreateWebhookResponse
+	decode(t, w, &webhook)
+	if webhook.Secret == "" || !webhook.IsActive {
+		t.Fatalf("webhook = %+v", webhook)
+	}
+
+	// 列表中不返回签名密钥
+	w = s.do(t, http.MethodGet, "/api/v1/webhooks", apiKey, nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), webhook.Secret) {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	// 只读 Key 不能管理 Webhook
+	w = s.do(t, http.MethodPost, "/api/v1/keys", apiKey, gin.H{"name": "ci", "scopes": []string{model.ScopeURLsRead}})
+	var readKey model.CreateAPIKeyResponse
+	decode(t, w, &readKey)
+	if w := s.do(t, http.MethodGet, "/api/v1/webhooks", readKey.Key, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com"})
+	webhookPath := "/api/v1/webhooks/" + webhook.ID.String()
+	w = s.do(t, http.MethodGet, webhookPath+"/deliveries?status=pending", apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var deliveries model.WebhookDeliveryListResponse
+	decode(t, w, &deliveries)
+	if len(deliveries.Data) != 1 || deliveries.Data[0].Event != model.EventURLCreated {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+	if w := s.do(t, http.MethodGet, webhookPath+"/deliveries?status=failed", apiKey, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	redeliverPath := webhookPath + "/deliveries/" + deliveries.Data[0].ID.String() + "/redeliver"
+	if w := s.do(t, http.MethodPost, redeliverPath, apiKey, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodPost, webhookPath+"/deliveries/bogus/redeliver", apiKey, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	w = s.do(t, http.MethodPatch, webhookPath, apiKey, gin.H{"is_active": false})
+	var updated model.WebhookResponse
+	decode(t, w, &updated)
+	if w.Code != http.StatusOK || updated.IsActive {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	if w := s.do(t, http.MethodDelete, webhookPath, apiKey, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodDelete, webhookPath, apiKey, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodPost, redeliverPath, apiKey, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestStreamClicks(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	u := s.createURL(t, apiKey, gin.H{"url": "https://example.com"})
+
+	if w := s.do(t, http.MethodGet, "/api/v1/stream/clicks?url_id=bogus", apiKey, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	w := s.doWithHeaders(t, http.MethodGet, "/api/v1/stream/clicks", map[string]string{"X-API-Key": apiKey, "Last-Event-ID": "bogus"}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream/clicks?url_id="+u.ID.String(), nil)
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if w := s.do(t, http.MethodGet, "/"+u.Code, "", nil); w.Code != http.StatusFound {
+		t.Fatalf("redirect status = %d", w.Code)
+	}
+
+	// 读取到第一个完整的事件（空行结束）
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	fields := map[string]string{}
+	timeout := time.After(5 * time.Second)
+	for fields["data"] == "" {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("连接提前关闭，fields = %v", fields)
+			}
+			if name, value, found := strings.Cut(line, ": "); found && name != "" {
+				fields[name] = value
+			}
+		case <-timeout:
+			t.Fatalf("等待点击事件超时，fields = %v", fields)
+		}
+	}
+	var click model.ClickEvent
+	if err := json.Unmarshal([]byte(fields["data"]), &click); err != nil {
+		t.Fatal(err)
+	}
+	if fields["retry"] == "" || fields["event"] != "click" || fields["id"] == "" || click.ShortURLID != u.ID {
+		t.Fatalf("fields = %v", fields)
+	}
+}
+
+func TestListShortURLsFilter(t *testing.T) {
+	s := newTestServer(t)
+	apiKey := s.createTenant(t)
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com/a", "custom_code": "lista", "tags": []string{"promo"}, "folder": "q3"})
+	s.createURL(t, apiKey, gin.H{"url": "https://example.com/b", "custom_code": "listb", "title": "Docs"})
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+		codes string
+	}{
+		{"按标签", "?tag=promo", http.StatusOK, "lista"},
+		{"按文件夹", "?folder=q3", http.StatusOK, "lista"},
+		{"搜索标题", "?q=docs", http.StatusOK, "listb"},
+		{"按短码排序", "?sort=code", http.StatusOK, "lista,listb"},
+		{"启用状态", "?active=true&expired=false&sort=code&order=desc", http.StatusOK, "listb,lista"},
+		{"排序字段非法", "?sort=title", http.StatusBadRequest, ""},
+		{"布尔参数非法", "?active=maybe", http.StatusBadRequest, ""},
+		{"时间参数非法", "?created_after=today", http.StatusBadRequest, ""},
+		{"游标非法", "?cursor=abc", http.StatusBadRequest, ""},
+		{"不再支持 page 参数", "?page=2", http.StatusBadRequest, ""},
+		{"include_total 非法", "?include_total=maybe", http.StatusBadRequest, ""},
+		{"include_bots 非法", "?include_bots=maybe", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(t, http.MethodGet, "/api/v1/urls"+tt.query, apiKey, nil)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Data []model.ShortURLResponse `json:"data"`
+			}
+			decode(t, w, &resp)
+			var codes []string
+			for _, u := range resp.Data {
+				codes = append(codes, u.Code)
+			}
+			if got := strings.Join(codes, ","); got != tt.codes {
+				t.Fatalf("codes = %s, want %s", got, tt.codes)
+			}
+		})
+	}
+
+	t.Run("游标翻页", func(t *testing.T) {
+		var page model.ShortURLListResponse
+		w := s.do(t, http.MethodGet, "/api/v1/urls?sort=code&page_size=1&include_total=false", apiKey, nil)
+		decode(t, w, &page)
+		if w.Code != http.StatusOK || len(page.Data) != 1 || page.Data[0].Code != "lista" || page.NextCursor == nil || page.Total != nil {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+
+		w = s.do(t, http.MethodGet, "/api/v1/urls?sort=code&page_size=1&cursor="+*page.NextCursor, apiKey, nil)
+		page = model.ShortURLListResponse{}
+		decode(t, w, &page)
+		if len(page.Data) != 1 || page.Data[0].Code != "listb" || page.NextCursor != nil || page.PrevCursor == nil ||
+			page.Total == nil || *page.Total != 2 {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 密码保护短链接 ====================
+
+// linkAccessCookie 访问凭证 Cookie 名，Path 限定为 /<code>，不同短链接的凭证互不影响
+const linkAccessCookie = "link_access"
+
+// passwordForm 密码输入页，表单提交到当前地址（POST /:code）
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>需要密码</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;margin-top:15vh;color:#222}
+form{width:320px}
+input{width:100%;box-sizing:border-box;padding:8px;margin:8px 0;font-size:16px}
+button{width:100%;padding:8px;font-size:16px}
+.error{color:#c00}
+</style>
+</head>
+<body>
+<form method="post">
+<h3>此链接需要密码才能访问</h3>
+{{if .Message}}<p class="error">{{.Message}}</p>{{end}}
+<input type="password" name="password" placeholder="请输入密码" autofocus required>
+<button type="submit">访问</button>
+</form>
+</body>
+</html>
+`))
+
+// UnlockShortURL 校验密码保护短链接的访问密码
+// POST /:code（表单字段 password）
+// 密码正确时写入访问凭证 Cookie，并 303 跳回 GET /:code 完成重定向（点击在那里记录）；
+// 不需要密码的链接直接按重定向处理，配合 307 / 308 可以把表单原样转发到目标地址
+func (h *Handler) UnlockShortURL(c *gin.Context) {
+	code := c.Param("code")
+	if h.svc.IsReservedCode(code) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "链接不存在",
+			"code":  "URL_NOT_FOUND",
+		})
+		return
+	}
+
+	access, err := h.svc.UnlockShortURL(c.Request.Context(), c.Request.Host, code, c.PostForm("password"), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrURLNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "链接不存在",
+				"code":  "URL_NOT_FOUND",
+			})
+		case errors.Is(err, service.ErrURLNotYetActive):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "链接尚未生效",
+				"code":  "URL_NOT_YET_ACTIVE",
+			})
+		case errors.Is(err, service.ErrURLExpired):
+			c.JSON(http.StatusGone, gin.H{
+				"error": "链接已过期",
+				"code":  "URL_EXPIRED",
+			})
+		case errors.Is(err, service.ErrClickLimitReached):
+			c.JSON(http.StatusGone, gin.H{
+				"error": "链接点击次数已达上限",
+				"code":  "URL_CLICK_LIMIT_REACHED",
+			})
+		case errors.Is(err, service.ErrWrongPassword):
+			renderPasswordForm(c, http.StatusForbidden, "密码错误")
+		case errors.Is(err, service.ErrTooManyAttempts):
+			renderPasswordForm(c, http.StatusTooManyRequests, err.Error())
+		default:
+			h.logger.Error("校验短链接密码失败", zap.String("code", code), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "服务器错误",
+			})
+		}
+		return
+	}
+
+	if access.Token == "" {
+		h.Redirect(c)
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     linkAccessCookie,
+		Value:    access.Token,
+		Path:     "/" + code,
+		Expires:  access.ExpiresAt,
+		MaxAge:   int(time.Until(access.ExpiresAt).Seconds()),
+		Secure:   isHTTPS(c),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusSeeOther, c.Request.URL.RequestURI())
+}
+
+// renderPasswordForm 输出密码输入页，message 为空时不显示错误提示
+func renderPasswordForm(c *gin.Context, status int, message string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	_ = passwordForm.Execute(c.Writer, struct{ Message string }{message})
+}
+
+// isHTTPS 判断客户端是否通过 HTTPS 访问（考虑 Ingress / 反向代理终止 TLS 的情况）
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 套餐处理器 ====================
+
+// ListPlans 查询套餐目录
+// GET /api/v1/plans（租户）
+// GET /api/v1/admin/plans（平台管理员）
+func (h *Handler) ListPlans(c *gin.Context) {
+	plans, err := h.svc.ListPlans(c.Request.Context())
+	if err != nil {
+		h.respondPlanError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": plans,
+	})
+}
+
+// GetPlan 查询单个套餐
+// GET /api/v1/admin/plans/:name
+func (h *Handler) GetPlan(c *gin.Context) {
+	plan, err := h.svc.GetPlan(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		h.respondPlanError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// CreatePlan 创建套餐
+// POST /api/v1/admin/plans
+func (h *Handler) CreatePlan(c *gin.Context) {
+	var req model.CreatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.svc.CreatePlan(c.Request.Context(), &req)
+	if err != nil {
+		h.respondPlanError(c, err, "创建失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdatePlan 修改套餐（整体替换配额）
+// PUT /api/v1/admin/plans/:name
+func (h *Handler) UpdatePlan(c *gin.Context) {
+	var req model.PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.svc.UpdatePlan(c.Request.Context(), c.Param("name"), &req)
+	if err != nil {
+		h.respondPlanError(c, err, "修改失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeletePlan 删除套餐
+// DELETE /api/v1/admin/plans/:name
+func (h *Handler) DeletePlan(c *gin.Context) {
+	if err := h.svc.DeletePlan(c.Request.Context(), c.Param("name")); err != nil {
+		h.respondPlanError(c, err, "删除失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ChangeTenantPlan 当前租户升级/降级套餐
+// POST /api/v1/tenant/plan
+func (h *Handler) ChangeTenantPlan(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var req model.ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updated, err := h.svc.ChangeTenantPlan(c.Request.Context(), tenant.ID, req.Plan)
+	if err != nil {
+		h.respondPlanError(c, err, "变更套餐失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// respondPlanError 将套餐相关的业务错误映射为 HTTP 响应
+func (h *Handler) respondPlanError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "套餐不存在",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidPlan):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrPlanExists), errors.Is(err, service.ErrPlanInUse),
+		errors.Is(err, service.ErrPlanBelowUsage):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   msg,
+			"message": err.Error(),
+		})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+	}
+}
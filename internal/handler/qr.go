@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== 二维码 ====================
+
+// GetQRCode 生成短链接的二维码图片
+// GET /api/v1/urls/:id/qr?format=png|svg&size=256&margin=4&ecc=L|M|Q|H&fg=000000&bg=ffffff
+func (h *Handler) GetQRCode(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseURLID(c)
+	if !ok {
+		return
+	}
+
+	req := &service.QRRequest{
+		Format:     c.Query("format"),
+		ECC:        c.Query("ecc"),
+		Foreground: c.Query("fg"),
+		Background: c.Query("bg"),
+	}
+	size, ok := parseIntQuery(c, "size")
+	if !ok {
+		return
+	}
+	if size != nil {
+		req.Size = *size
+	}
+	if req.Margin, ok = parseIntQuery(c, "margin"); !ok {
+		return
+	}
+
+	code, err := h.svc.GetQRCode(c.Request.Context(), tenant.ID, id, requestBaseURL(c), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQROptions) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.respondURLError(c, err, "生成二维码失败")
+		return
+	}
+
+	// 短码不可修改，图片内容只随参数变化，允许客户端缓存
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.cfg.QR.CacheTTL.Seconds())))
+	c.Header("X-QR-Content", code.Content)
+	c.Data(http.StatusOK, code.ContentType, code.Data)
+}
+
+// requestBaseURL 按当前请求的协议和 Host 推断服务地址
+// 只在未配置 PUBLIC_BASE_URL 时用于默认域名的短链接（仅适用于 API 与重定向同域名部署）
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if isHTTPS(c) {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// parseIntQuery 解析可选的整数查询参数，未传时返回 nil
+func parseIntQuery(c *gin.Context, name string) (*int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": name + " 必须是整数",
+		})
+		return nil, false
+	}
+	return &n, true
+}
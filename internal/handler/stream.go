@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// sseRetry 建议客户端断线后的重连间隔
+const sseRetry = 3 * time.Second
+
+// ==================== 实时点击流 ====================
+
+// StreamClicks 以 Server-Sent Events 推送租户的实时点击
+// GET /api/v1/stream/clicks?url_id=<短链接 ID>
+// 每个点击是一个 event: click 事件，id 可以作为 Last-Event-ID 请求头断线续传（EventSource 会自动携带）；
+// 没有点击时每隔 STREAM_HEARTBEAT（默认 15s）发送一行注释作为心跳
+func (h *Handler) StreamClicks(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var urlID *uuid.UUID
+	if value := c.Query("url_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": "无效的短链接 ID",
+			})
+			return
+		}
+		urlID = &id
+	}
+
+	stream, err := h.svc.OpenClickStream(c.Request.Context(), tenant.ID, urlID, c.GetHeader("Last-Event-ID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStreamID):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "参数错误",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrStreamLimit):
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "连接数超限",
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrStreamClosed):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "服务不可用",
+				"message": err.Error(),
+			})
+		default:
+			h.respondURLError(c, err, "打开点击流失败")
+		}
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no") // 关闭 Nginx Ingress 的响应缓冲
+	c.Status(http.StatusOK)
+
+	// 连接可能持续数小时，不能套用服务器的 WriteTimeout：每次写入前单独设置超时
+	rc := http.NewResponseController(c.Writer)
+	write := func(format string, args ...interface{}) bool {
+		if err := rc.SetWriteDeadline(time.Now().Add(stream.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+		if _, err := fmt.Fprintf(c.Writer, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	send := func(click *repository.StreamedClick) bool {
+		data, err := json.Marshal(&click.Event)
+		if err != nil {
+			h.logger.Error("序列化点击事件失败", zap.Error(err))
+			return true
+		}
+		return write("id: %s\nevent: click\ndata: %s\n\n", click.ID, data)
+	}
+
+	if !write("retry: %d\n\n", sseRetry.Milliseconds()) {
+		return
+	}
+	for i := range stream.Backlog {
+		if !send(&stream.Backlog[i]) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case click, ok := <-stream.C:
+			if !ok {
+				// 订阅中断（如 Redis 断开）或服务关闭，客户端会用 Last-Event-ID 重连补齐
+				return
+			}
+			if !send(&click) {
+				return
+			}
+		}
+	}
+}
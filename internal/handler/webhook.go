@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/middleware"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/service"
+)
+
+// ==================== Webhook ====================
+
+// CreateWebhook 添加 Webhook，响应中的 secret 只返回这一次
+// POST /api/v1/webhooks
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	var req model.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.CreateWebhook(c.Request.Context(), tenant.ID, &req)
+	if err != nil {
+		h.respondWebhookError(c, err, "添加 Webhook 失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks 查询 Webhook 列表
+// GET /api/v1/webhooks
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	webhooks, err := h.svc.ListWebhooks(c.Request.Context(), tenant.ID)
+	if err != nil {
+		h.respondWebhookError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": webhooks,
+	})
+}
+
+// UpdateWebhook 修改 Webhook 的地址、订阅事件或启用状态
+// PATCH /api/v1/webhooks/:id
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 Webhook ID")
+	if !ok {
+		return
+	}
+	var req model.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.svc.UpdateWebhook(c.Request.Context(), tenant.ID, id, &req)
+	if err != nil {
+		h.respondWebhookError(c, err, "更新失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteWebhook 删除 Webhook（未完成的投递一并丢弃）
+// DELETE /api/v1/webhooks/:id
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 Webhook ID")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteWebhook(c.Request.Context(), tenant.ID, id); err != nil {
+		h.respondWebhookError(c, err, "删除失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries 查询 Webhook 的投递记录（按创建时间倒序）
+// GET /api/v1/webhooks/:id/deliveries?status=pending|delivered|dead&page_size=20&cursor=<next_cursor>
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 Webhook ID")
+	if !ok {
+		return
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	req := &model.PageRequest{
+		Cursor:   c.Query("cursor"),
+		PageSize: pageSize,
+	}
+
+	resp, err := h.svc.ListWebhookDeliveries(c.Request.Context(), tenant.ID, id, c.Query("status"), req)
+	if err != nil {
+		h.respondWebhookError(c, err, "查询失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RedeliverWebhook 重新投递一条记录（包括已投递成功和已放弃的），由后台立即发送
+// POST /api/v1/webhooks/:id/deliveries/:delivery_id/redeliver
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	tenant := middleware.GetTenantFromContext(c)
+	if tenant == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	id, ok := parseIDParam(c, "无效的 Webhook ID")
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": "无效的投递记录 ID",
+		})
+		return
+	}
+
+	resp, err := h.svc.RedeliverWebhook(c.Request.Context(), tenant.ID, id, deliveryID)
+	if err != nil {
+		h.respondWebhookError(c, err, "重新投递失败")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// respondWebhookError 将 Webhook 接口的业务错误映射为 HTTP 响应
+func (h *Handler) respondWebhookError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook 不存在",
+		})
+	case errors.Is(err, service.ErrDeliveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "投递记录不存在",
+		})
+	case errors.Is(err, service.ErrInvalidWebhook), errors.Is(err, service.ErrInvalidFilter):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "参数错误",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrWebhookLimit):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "配额不足",
+			"message": err.Error(),
+		})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+	}
+}
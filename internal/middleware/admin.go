@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminAuth 平台管理员认证中间件
+// 套餐管理等平台级接口不属于任何租户，不能使用租户的 API Key，
+// 而是使用通过 Secret 注入的管理令牌（ADMIN_API_TOKEN），请求头为 X-Admin-Token
+// 未配置令牌时管理接口整体关闭
+func AdminAuth(token string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "管理接口未启用",
+				"message": "请配置 ADMIN_API_TOKEN",
+			})
+			return
+		}
+
+		// 常量时间比较，避免通过响应时间逐字节猜测令牌
+		provided := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Warn("管理员认证失败", zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "未授权",
+				"message": "请在 Header 中提供有效的 X-Admin-Token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
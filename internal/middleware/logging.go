@@ -52,6 +52,11 @@ func StructuredLogging(logger *zap.Logger) gin.HandlerFunc {
 			)
 		}
 
+		// 关联链路追踪：在日志中记录 trace_id，可以从日志跳转到对应的链路
+		if traceID := TraceID(c); traceID != "" {
+			fields = append(fields, zap.String("trace_id", traceID))
+		}
+
 		// 如果有错误，记录错误信息
 		if len(c.Errors) > 0 {
 			fields = append(fields, zap.String("errors", c.Errors.String()))
@@ -98,6 +98,13 @@ func RecordURLCreated(tenantID, plan string) {
 	urlCreatedTotal.WithLabelValues(tenantID, plan).Inc()
 }
 
+// RecordURLsCreated 记录批量创建/导入的短链接数量
+func RecordURLsCreated(tenantID, plan string, n int) {
+	if n > 0 {
+		urlCreatedTotal.WithLabelValues(tenantID, plan).Add(float64(n))
+	}
+}
+
 // RecordRedirect 记录重定向指标
 func RecordRedirect(tenantID string) {
 	urlRedirectsTotal.WithLabelValues(tenantID).Inc()
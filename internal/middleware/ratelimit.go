@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/yourname/saas-shortener/internal/repository"
 	"github.com/yourname/saas-shortener/internal/service"
 )
 
@@ -16,8 +20,15 @@ import (
 // 2. 推动免费用户升级到付费套餐
 // 3. 保护系统整体稳定性
 //
-// 实现原理：使用 Redis 的 Sorted Set 实现滑动窗口计数器
+// 实现原理：使用 Redis 的 Sorted Set + Lua 脚本实现原子的滑动日志限流
 // 这是分布式限流的标准方案，适用于多实例部署的云原生环境
+//
+// 每个通过认证的响应都带上限流响应头，客户端据此主动退避：
+//
+//	X-RateLimit-Limit     每分钟允许的请求数
+//	X-RateLimit-Remaining 当前窗口剩余配额
+//	X-RateLimit-Reset     下一个配额释放的时间（Unix 秒）
+//	Retry-After           被限流时需要等待的秒数（仅 429 响应）
 func RateLimit(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tenant := GetTenantFromContext(c)
@@ -27,7 +38,7 @@ func RateLimit(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 		}
 
 		// 检查限流
-		allowed, err := svc.CheckRateLimit(c.Request.Context(), tenant.ID, tenant.RateLimit)
+		result, err := svc.CheckRateLimit(c.Request.Context(), tenant.ID, tenant.RateLimit)
 		if err != nil {
 			logger.Error("限流检查失败",
 				zap.String("tenant_id", tenant.ID.String()),
@@ -38,7 +49,10 @@ func RateLimit(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		if !allowed {
+		setRateLimitHeaders(c, result)
+
+		if !result.Allowed {
+			RecordRateLimitHit(tenant.ID.String(), tenant.Plan)
 			logger.Warn("租户触发限流",
 				zap.String("tenant_id", tenant.ID.String()),
 				zap.String("plan", tenant.Plan),
@@ -46,10 +60,11 @@ func RateLimit(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 			)
 
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error":   "请求过于频繁",
-				"message": "已超过每分钟请求限制，请稍后重试或升级套餐",
-				"plan":    tenant.Plan,
-				"limit":   tenant.RateLimit,
+				"error":       "请求过于频繁",
+				"message":     "已超过每分钟请求限制，请稍后重试或升级套餐",
+				"plan":        tenant.Plan,
+				"limit":       tenant.RateLimit,
+				"retry_after": retryAfterSeconds(result),
 			})
 			return
 		}
@@ -57,3 +72,22 @@ func RateLimit(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// setRateLimitHeaders 写入限流响应头
+// 在 c.Next() 之前设置，后续 handler 写响应时会一并带上
+func setRateLimitHeaders(c *gin.Context, result *repository.RateLimitResult) {
+	h := c.Writer.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	// 向上取整，保证客户端在 Reset 时刻重试一定有配额
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(result.ResetAt.UnixMilli())/1000)), 10))
+
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.Itoa(retryAfterSeconds(result)))
+	}
+}
+
+// retryAfterSeconds 被限流时需要等待的秒数，至少 1 秒
+func retryAfterSeconds(result *repository.RateLimitResult) int {
+	return max(int(math.Ceil(result.RetryAfter(time.Now()).Seconds())), 1)
+}
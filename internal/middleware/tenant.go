@@ -6,6 +6,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -14,11 +15,13 @@ import (
 
 	"github.com/yourname/saas-shortener/internal/model"
 	"github.com/yourname/saas-shortener/internal/service"
+	"github.com/yourname/saas-shortener/internal/tracing"
 )
 
 // 上下文 key 常量
 const (
-	TenantKey = "tenant" // Gin Context 中存储租户信息的 key
+	TenantKey = "tenant"  // Gin Context 中存储租户信息的 key
+	APIKeyKey = "api_key" // Gin Context 中存储当前请求所用 API Key 的 key
 )
 
 // TenantAuth 租户认证中间件
@@ -50,15 +53,19 @@ func TenantAuth(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 		}
 
 		// 认证租户
-		tenant, err := svc.AuthenticateTenant(c.Request.Context(), apiKey)
+		tenant, key, err := svc.AuthenticateTenant(c.Request.Context(), apiKey)
 		if err != nil {
 			logger.Warn("租户认证失败",
 				zap.String("ip", c.ClientIP()),
 				zap.Error(err),
 			)
+			message := "无效的 API Key"
+			if errors.Is(err, service.ErrAPIKeyExpired) {
+				message = err.Error()
+			}
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "认证失败",
-				"message": "无效的 API Key",
+				"message": message,
 			})
 			return
 		}
@@ -66,6 +73,12 @@ func TenantAuth(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 		// 将租户信息注入到 Gin Context 中
 		// 后续 Handler 可通过 GetTenantFromContext() 获取
 		c.Set(TenantKey, tenant)
+		c.Set(APIKeyKey, key)
+
+		// 租户信息同时写入 Request.Context()，后续的 span 都会带上 tenant_id / plan
+		c.Request = c.Request.WithContext(
+			tracing.ContextWithTenant(c.Request.Context(), tenant.ID.String(), tenant.Plan),
+		)
 
 		// 记录结构化日志
 		logger.Debug("租户认证成功",
@@ -78,6 +91,35 @@ func TenantAuth(svc *service.Service, logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// RequireScope 权限范围检查中间件
+// 挂在具体路由上，必须位于 TenantAuth 之后：
+// 当前请求所用的 API Key 不具备该权限时返回 403
+//
+// 例如只读 Key（urls:read）可以查询短链接，但不能创建或删除
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := GetAPIKeyFromContext(c)
+		if key == nil || !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "权限不足",
+				"message": "当前 API Key 缺少权限: " + scope,
+				"scope":   scope,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAPIKeyFromContext 从 Gin Context 中获取当前请求所用的 API Key
+func GetAPIKeyFromContext(c *gin.Context) *model.APIKey {
+	key, exists := c.Get(APIKeyKey)
+	if !exists {
+		return nil
+	}
+	return key.(*model.APIKey)
+}
+
 // GetTenantFromContext 从 Gin Context 中获取当前租户信息
 // Handler 中使用此函数获取认证后的租户
 func GetTenantFromContext(c *gin.Context) *model.Tenant {
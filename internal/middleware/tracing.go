@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+// untracedPaths 探针和指标端点调用频繁且没有分析价值，不创建 span
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Tracing 链路追踪中间件
+// 云原生可观测性三大支柱之三：Tracing（链路追踪）
+//
+//  1. 从请求头中提取 W3C traceparent，延续上游（网关、调用方）的链路
+//  2. 为每个请求创建 server span，并写入 Request.Context()，
+//     Service / GORM / Redis 的 span 都会挂在它下面
+//  3. 租户信息由 TenantAuth 认证成功后补充到 span 上
+func Tracing() gin.HandlerFunc {
+	propagator := otel.GetTextMapPropagator()
+
+	return func(c *gin.Context) {
+		if untracedPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath() // 使用路由模板，避免 span 名称高基数（如 /abc123 → /:code）
+		if route == "" {
+			route = "unknown"
+		}
+		ctx, span := tracing.Tracer().Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// TraceID 返回当前请求的 trace ID（没有有效链路时返回空字符串），用于日志关联
+func TraceID(c *gin.Context) string {
+	sc := trace.SpanContextFromContext(c.Request.Context())
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
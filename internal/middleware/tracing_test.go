@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := gin.New()
+	r.Use(Tracing())
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/v1/urls/:id", func(c *gin.Context) {
+		// 模拟 TenantAuth 写入租户信息后，Service 层创建子 span
+		ctx := tracing.ContextWithTenant(c.Request.Context(), "tenant-1", "pro")
+		_, span := tracing.Start(ctx, "Service.GetShortURL")
+		span.End()
+		c.Status(http.StatusNotFound)
+	})
+
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/urls/123", nil)
+	req.Header.Set("traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2 (探针请求不应创建 span)", len(spans))
+	}
+
+	child, server := spans[0], spans[1]
+	if server.Name() != "GET /api/v1/urls/:id" {
+		t.Errorf("server span name = %q", server.Name())
+	}
+	if got := server.SpanContext().TraceID().String(); got != parentTraceID {
+		t.Errorf("trace id = %s, want %s (应延续 traceparent)", got, parentTraceID)
+	}
+	if child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("service span should be a child of the server span")
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range child.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["tenant_id"] != "tenant-1" || attrs["plan"] != "pro" {
+		t.Errorf("child attributes = %v, want tenant_id and plan", attrs)
+	}
+}
@@ -0,0 +1,329 @@
+// Package migrate 版本化数据库迁移
+//
+// 为什么不用 GORM AutoMigrate？
+// 1. AutoMigrate 只会加列/加索引，不能删除或重命名列
+// 2. HPA 同时拉起多个副本时，每个 Pod 都在启动时执行 AutoMigrate，会互相竞争
+// 3. 没有任何历史记录，无法知道数据库当前处于哪个版本，也无法回滚
+//
+// 本包的做法：
+// - 迁移脚本是带版本号的 up/down SQL 文件（见 migrations 目录）
+// - schema_migrations 表记录已执行的版本
+// - 执行前获取 PostgreSQL advisory lock，多个副本/Job 同时执行时串行化
+// - 每个迁移与版本记录在同一事务中提交，失败则整体回滚
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lockID advisory lock 的 key，所有实例使用同一个值
+const lockID int64 = 0x5aa5_5407_e0e0_0001
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 单个迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+// New 从 fsys 中加载迁移脚本并创建 Migrator
+func New(db *sql.DB, fsys fs.FS, logger *zap.Logger) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// Load 解析目录下的 <版本号>_<描述>.up.sql / .down.sql 文件，按版本号排序
+// 每个版本必须同时有 up 和 down 脚本，版本号不能重复
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移目录失败: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的迁移版本号 %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取迁移文件 %s 失败: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("迁移版本号 %d 重复: %s / %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("迁移 %d_%s 缺少 up 或 down 脚本", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up 执行所有未执行的迁移，返回本次执行的数量
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			start := time.Now()
+			if err := runInTx(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())`,
+				mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("执行迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("迁移已执行",
+				zap.Int64("version", mig.Version),
+				zap.String("name", mig.Name),
+				zap.Duration("duration", time.Since(start)),
+			)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down 回滚最近执行的 n 个迁移
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("回滚数量必须大于 0")
+	}
+
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := runInTx(ctx, conn, mig.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+				return fmt.Errorf("回滚迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("迁移已回滚",
+				zap.Int64("version", mig.Version),
+				zap.String("name", mig.Name),
+			)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status 返回所有迁移的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Force 不执行任何 SQL，直接把数据库标记为指定版本：
+// 小于等于 version 的迁移视为已执行，大于 version 的视为未执行
+// 用于手动修复后校正版本记录；version 为 0 表示清空记录
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && !m.hasVersion(version) {
+		return fmt.Errorf("不存在版本 %d 的迁移", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, now())`,
+				mig.Version, mig.Name); err != nil {
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		m.logger.Warn("迁移版本已强制设置", zap.Int64("version", version))
+		return nil
+	})
+}
+
+func (m *Migrator) hasVersion(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock 在持有 advisory lock 的专用连接上执行 fn
+// advisory lock 属于会话级别，加锁、迁移、解锁必须使用同一个连接
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("获取迁移锁失败: %w", err)
+	}
+	defer func() {
+		// 使用独立 context，保证 ctx 取消后仍能释放锁
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			m.logger.Error("释放迁移锁失败", zap.Error(err))
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable 创建版本记录表
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    bigint PRIMARY KEY,
+			name       varchar(255) NOT NULL,
+			applied_at timestamptz  NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions 查询已执行的版本及执行时间
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("查询迁移记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// runInTx 在同一事务中执行迁移脚本和版本记录语句
+// PostgreSQL 的 DDL 支持事务，脚本失败时表结构和版本记录一起回滚
+func runInTx(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
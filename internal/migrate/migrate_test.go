@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yourname/saas-shortener/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		wantErr  string
+		wantVers []int64
+	}{
+		{
+			name: "按版本号排序，忽略非迁移文件",
+			files: fstest.MapFS{
+				"0002_b.up.sql":   {Data: []byte("b up")},
+				"0002_b.down.sql": {Data: []byte("b down")},
+				"0001_a.up.sql":   {Data: []byte("a up")},
+				"0001_a.down.sql": {Data: []byte("a down")},
+				"README.md":       {Data: []byte("doc")},
+			},
+			wantVers: []int64{1, 2},
+		},
+		{
+			name: "缺少 down 脚本",
+			files: fstest.MapFS{
+				"0001_a.up.sql": {Data: []byte("a up")},
+			},
+			wantErr: "缺少 up 或 down",
+		},
+		{
+			name: "版本号重复",
+			files: fstest.MapFS{
+				"0001_a.up.sql":   {Data: []byte("a up")},
+				"0001_a.down.sql": {Data: []byte("a down")},
+				"0001_b.up.sql":   {Data: []byte("b up")},
+			},
+			wantErr: "重复",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.wantVers) {
+				t.Fatalf("len = %d, want %d", len(got), len(tt.wantVers))
+			}
+			for i, v := range tt.wantVers {
+				if got[i].Version != v {
+					t.Errorf("got[%d].Version = %d, want %d", i, got[i].Version, v)
+				}
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrations 确保仓库中的迁移文件都能被正确解析，且版本号连续
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range got {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: want version %d", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -10,6 +10,11 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,67 +24,695 @@ import (
 // SaaS 中的"租户"就是你的客户（通常是一家公司/组织）
 type Tenant struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	Name      string    `gorm:"size:255;not null" json:"name"`                // 租户名称（公司名）
-	APIKey    string    `gorm:"size:64;uniqueIndex;not null" json:"-"`        // API 密钥，用于认证
-	Plan      string    `gorm:"size:50;not null;default:'free'" json:"plan"`  // 订阅套餐: free/pro/enterprise
-	RateLimit int       `gorm:"not null;default:100" json:"rate_limit"`       // 每分钟请求限制
-	MaxURLs   int       `gorm:"not null;default:1000" json:"max_urls"`        // 最大 URL 数
-	IsActive  bool      `gorm:"not null;default:true" json:"is_active"`       // 是否激活
+	Name      string    `gorm:"size:255;not null" json:"name"`                     // 租户名称（公司名）
+	Plan      string    `gorm:"size:50;not null;default:'free';index" json:"plan"` // 订阅套餐，对应 plans 表的 name
+	RateLimit int       `gorm:"not null;default:100" json:"rate_limit"`            // 每分钟请求限制
+	MaxURLs   int       `gorm:"not null;default:1000" json:"max_urls"`             // 最大 URL 数
+	IsActive  bool      `gorm:"not null;default:true" json:"is_active"`            // 是否激活
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// Plan 订阅套餐
+// 套餐目录存储在数据库中，运营人员通过管理 API 调整配额，无需重新发布
+// 租户表冗余保存 RateLimit / MaxURLs（认证和限流的热路径不必再查套餐），
+// 修改租户套餐或套餐配额时同步更新
+type Plan struct {
+	Name                   string    `gorm:"size:50;primary_key" json:"name"`                    // 套餐标识，如 free/pro/enterprise
+	DisplayName            string    `gorm:"size:255;not null" json:"display_name"`              // 展示名称
+	RateLimit              int       `gorm:"not null" json:"rate_limit"`                         // 每分钟请求限制
+	MaxURLs                int       `gorm:"not null" json:"max_urls"`                           // 最大 URL 数
+	MonthlyClickQuota      int64     `gorm:"not null;default:0" json:"monthly_click_quota"`      // 每月点击配额，0 表示不限制
+	AllowCustomCode        bool      `gorm:"not null;default:false" json:"allow_custom_code"`    // 是否允许自定义短码
+	AnalyticsRetentionDays int       `gorm:"not null;default:0" json:"analytics_retention_days"` // 点击分析可查询的天数，0 表示不限制
+	Features               string    `gorm:"size:1024;not null;default:''" json:"-"`             // 逗号分隔的功能开关
+	CreatedAt              time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt              time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// FeatureList 返回开启的功能列表
+func (p *Plan) FeatureList() []string {
+	if p.Features == "" {
+		return nil
+	}
+	return strings.Split(p.Features, ",")
+}
+
+// HasFeature 判断套餐是否开启了指定功能
+func (p *Plan) HasFeature(feature string) bool {
+	for _, f := range p.FeatureList() {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// API Key 权限范围（Scope）
+// 一个租户可以有多个 API Key，每个 Key 只授予需要的权限（最小权限原则）
+// 例如 CI 任务只需要 urls:read，泄露后影响范围有限
+const (
+	ScopeURLsRead  = "urls:read"  // 查询短链接
+	ScopeURLsWrite = "urls:write" // 创建/修改/删除短链接
+	ScopeStatsRead = "stats:read" // 查询统计数据
+	ScopeAdmin     = "admin"      // 管理 API Key 等租户配置，隐含所有其他权限
+)
+
+// AllScopes 所有合法的权限范围
+var AllScopes = []string{ScopeURLsRead, ScopeURLsWrite, ScopeStatsRead, ScopeAdmin}
+
+// APIKey API 密钥模型
+// 只存储 Key 的 SHA-256 哈希，明文只在创建/轮换时返回一次
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	TenantID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"tenant_id"`
+	Name       string     `gorm:"size:255;not null" json:"name"`         // 用途说明，如 "ci-readonly"
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"` // Key 的哈希值
+	Prefix     string     `gorm:"size:8;not null" json:"prefix"`         // 明文前几位，便于用户辨认是哪个 Key
+	Scopes     string     `gorm:"size:255;not null" json:"-"`            // 逗号分隔的权限范围
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`                // 最近使用时间（按分钟粒度更新）
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`                  // 过期时间（可选）
+	Revoked    bool       `gorm:"not null;default:false" json:"revoked"` // 是否已吊销
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ScopeList 返回权限范围列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope 判断 Key 是否拥有指定权限，admin 拥有所有权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Domain 租户的自定义短链接域名
+// 通过 DNS TXT 记录或 HTTP 文件验证所有权后才能用于创建短链接；
+// 不同租户可以同时申请同一域名，只有一个能通过验证
+type Domain struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	TenantID          uuid.UUID  `gorm:"type:uuid;not null" json:"tenant_id"`
+	Hostname          string     `gorm:"size:253;not null" json:"hostname"` // 小写、不含端口，如 go.example.com
+	VerificationToken string     `gorm:"size:64;not null" json:"-"`         // 所有权验证令牌
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`             // 通过验证的时间，为空表示尚未验证
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// IsVerified 是否已通过所有权验证
+func (d *Domain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// LinkKey 短链接在所有域名中的唯一标识，用作缓存 key 等
+// 默认域名直接使用短码（与引入自定义域名之前保持一致），自定义域名为 <domain>/<code>
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + "/" + code
+}
+
 // ShortURL 短链接模型
 // 注意 TenantID 字段 —— 这是多租户数据隔离的关键
 type ShortURL struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	TenantID    uuid.UUID `gorm:"type:uuid;index;not null" json:"tenant_id"`   // 所属租户 ← 多租户关键字段
-	Code        string    `gorm:"size:10;uniqueIndex;not null" json:"code"`     // 短码，如 "abc123"
-	OriginalURL string    `gorm:"type:text;not null" json:"original_url"`       // 原始长 URL
-	Clicks      int64     `gorm:"not null;default:0" json:"clicks"`            // 点击次数
-	IsActive    bool      `gorm:"not null;default:true" json:"is_active"`       // 是否启用
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                        // 过期时间（可选）
-	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	TenantID    uuid.UUID `gorm:"type:uuid;index;not null" json:"tenant_id"`                                                              // 所属租户 ← 多租户关键字段
+	Domain      string    `gorm:"size:253;not null;default:'';uniqueIndex:idx_short_urls_domain_code,priority:1" json:"domain,omitempty"` // 自定义域名，空表示默认域名
+	Code        string    `gorm:"size:10;not null;uniqueIndex:idx_short_urls_domain_code,priority:2" json:"code"`                         // 短码，如 "abc123"，在同一域名内唯一
+	OriginalURL string    `gorm:"type:text;not null" json:"original_url"`                                                                 // 原始长 URL
+
+	// 整理与搜索
+	Title  string   `gorm:"size:255;not null;default:''" json:"title,omitempty"`  // 标题（可选），用于搜索
+	Folder string   `gorm:"size:100;not null;default:''" json:"folder,omitempty"` // 文件夹/营销活动（可选）
+	Tags   []string `gorm:"-" json:"tags,omitempty"`                              // 标签，存储在 tags / short_url_tags 表中
+
+	// 点击统计
+	Clicks    int64 `gorm:"not null;default:0" json:"clicks"`     // 点击次数（不含机器人）
+	BotClicks int64 `gorm:"not null;default:0" json:"bot_clicks"` // 机器人点击次数
+
+	// 状态与有效期
+	IsActive  bool       `gorm:"not null;default:true" json:"is_active"` // 是否启用
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`                   // 过期时间（可选）
+	NotBefore *time.Time `json:"not_before,omitempty"`                   // 生效时间（可选），之前访问返回 404
+	MaxClicks int64      `gorm:"not null;default:0" json:"max_clicks"`   // 最大点击次数，0 表示不限制
+
+	// 访问密码的 bcrypt 哈希，为空表示不需要密码
+	// 重定向使用的 url:detail:<code> 缓存依赖该字段判断是否需要密码，因此要参与 JSON 序列化；
+	// 对外响应使用 ShortURLResponse，不会泄露
+	PasswordHash string `gorm:"size:100;not null;default:''" json:"password_hash,omitempty"`
+
+	Rules RedirectRules `gorm:"type:jsonb" json:"rules,omitempty"` // 条件跳转规则，按顺序匹配，都不满足时跳转到 OriginalURL
+
+	Variants []ShortURLVariant `gorm:"-" json:"variants,omitempty"` // A/B 测试目标，存储在 short_url_variants 表中；没有命中规则时按权重分配
+
+	// 重定向选项
+	RedirectStatus int       `gorm:"not null;default:302" json:"redirect_status,omitempty"` // 301 / 302 / 307 / 308
+	ForwardQuery   bool      `gorm:"not null;default:false" json:"forward_query,omitempty"` // 把访问时的查询参数合并到跳转地址
+	ForwardPath    bool      `gorm:"not null;default:false" json:"forward_path,omitempty"`  // 允许 /<code>/<rest>，把 rest 追加到跳转地址的路径
+	UTM            UTMParams `gorm:"type:jsonb" json:"utm,omitempty"`                       // 跳转时注入的 UTM 参数
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// LinkKey 见 LinkKey 函数
+func (u *ShortURL) LinkKey() string {
+	return LinkKey(u.Domain, u.Code)
+}
+
+// IsProtected 是否需要输入密码才能访问
+func (u *ShortURL) IsProtected() bool {
+	return u.PasswordHash != ""
+}
+
+// StatusCode 重定向使用的 HTTP 状态码，未设置时为 302
+func (u *ShortURL) StatusCode() int {
+	if u.RedirectStatus == 0 {
+		return http.StatusFound
+	}
+	return u.RedirectStatus
+}
+
+// RedirectStatuses 允许的重定向状态码
+// 301 / 308 为永久重定向（利于 SEO，但浏览器会缓存，修改目标地址后老访客可能不再经过短链接）；
+// 307 / 308 要求客户端保持请求方法和请求体，用于转发 POST 表单
+var RedirectStatuses = []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect}
+
+// UTMParams 跳转时注入的 UTM 参数，目标地址中已有的同名参数不会被覆盖
+type UTMParams struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
 }
 
+// IsZero 是否没有设置任何参数
+func (p UTMParams) IsZero() bool {
+	return p == UTMParams{}
+}
+
+// Values 以查询参数名返回已设置的参数
+func (p UTMParams) Values() map[string]string {
+	values := make(map[string]string, 5)
+	for name, v := range map[string]string{
+		"utm_source": p.Source, "utm_medium": p.Medium, "utm_campaign": p.Campaign,
+		"utm_term": p.Term, "utm_content": p.Content,
+	} {
+		if v != "" {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// Value 实现 driver.Valuer，没有参数时存为 NULL
+func (p UTMParams) Value() (driver.Value, error) {
+	if p.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (p *UTMParams) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = UTMParams{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return errors.New("utm 列类型不支持")
+}
+
+// ShortURLVariant A/B 测试的跳转目标
+// 流量按 Weight 占全部目标权重之和的比例分配，Weight 为 0 表示暂停该目标（已分配的访问者也会重新分配）
+type ShortURLVariant struct {
+	ShortURLID uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	ID         string    `gorm:"size:32;primaryKey" json:"id,omitempty"` // 目标 ID，记录在点击事件和访问者 Cookie 中；为空时自动生成
+	URL        string    `gorm:"type:text;not null" json:"url"`
+	Weight     int       `gorm:"not null" json:"weight"`
+	Position   int       `gorm:"not null;default:0" json:"-"` // 在列表中的顺序
+}
+
+// MaxVariantsPerURL 每个短链接最多的 A/B 测试目标数
+const MaxVariantsPerURL = 10
+
+// RedirectRule 条件跳转规则
+// 设置了的条件全部满足时跳转到 URL；同一条件内的多个取值满足任意一个即可
+type RedirectRule struct {
+	ID        string            `json:"id,omitempty"`        // 规则 ID，记录在点击事件中；为空时自动生成
+	URL       string            `json:"url"`                 // 目标地址
+	Devices   []string          `json:"devices,omitempty"`   // 设备类型：desktop / mobile / tablet / bot
+	OS        []string          `json:"os,omitempty"`        // 操作系统：ios / android / windows / macos / linux / chromeos
+	Languages []string          `json:"languages,omitempty"` // Accept-Language 语言，如 zh 匹配 zh-CN / zh-TW，zh-cn 只匹配 zh-CN
+	Countries []string          `json:"countries,omitempty"` // 国家/地区（ISO 3166-1 alpha-2），需要配置 GeoIP 数据库
+	Schedule  *RuleSchedule     `json:"schedule,omitempty"`  // 时间段
+	Query     map[string]string `json:"query,omitempty"`     // 查询参数，值为 "*" 时只要求参数存在
+}
+
+// RuleSchedule 规则生效的时间段
+type RuleSchedule struct {
+	Timezone string   `json:"timezone,omitempty"` // IANA 时区，如 Asia/Shanghai，默认 UTC
+	Days     []string `json:"days,omitempty"`     // 星期：mon / tue / wed / thu / fri / sat / sun，为空表示每天
+	Start    string   `json:"start,omitempty"`    // 开始时间 HH:MM（含），为空表示 00:00
+	End      string   `json:"end,omitempty"`      // 结束时间 HH:MM（不含），为空表示 24:00；早于 Start 时表示跨午夜
+}
+
+// 规则取值
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+
+	MaxRulesPerURL = 20
+)
+
+// RedirectRules 以 jsonb 存储的规则列表
+type RedirectRules []RedirectRule
+
+// Value 实现 driver.Valuer，空列表存为 NULL
+func (r RedirectRules) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (r *RedirectRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return errors.New("rules 列类型不支持")
+}
+
+// 列表筛选与排序使用的 (tenant_id, folder / created_at / clicks / code) 联合索引
+// 以及 q= 子串搜索使用的 trigram 索引见 migrations/0007_url_tags_and_search.up.sql
+
+// Tag 标签，按租户隔离，同一租户内名称唯一
+type Tag struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_tags_tenant_name,priority:1" json:"tenant_id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_tags_tenant_name,priority:2" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ShortURLTag 短链接与标签的多对多关联
+type ShortURLTag struct {
+	ShortURLID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TagID      uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+}
+
+// 标签规则
+const (
+	MaxTagsPerURL = 20 // 每个短链接最多的标签数
+	MaxTagLength  = 50 // 标签最大长度（字符数）
+)
+
 // ClickEvent 点击事件模型（用于统计分析）
 type ClickEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	ShortURLID uuid.UUID `gorm:"type:uuid;index;index:idx_click_events_url_time,priority:1;not null" json:"short_url_id"`
+	TenantID   uuid.UUID `gorm:"type:uuid;index;index:idx_click_events_tenant_time,priority:1;not null" json:"tenant_id"` // 冗余存储租户ID，方便按租户查询
+	IP         string    `gorm:"size:45" json:"ip"`
+	UserAgent  string    `gorm:"type:text" json:"user_agent"`
+	Referer    string    `gorm:"type:text" json:"referer"`
+	RuleID     string    `gorm:"size:32;not null;default:''" json:"rule_id,omitempty"`    // 命中的条件跳转规则，为空表示跳转到默认地址
+	VariantID  string    `gorm:"size:32;not null;default:''" json:"variant_id,omitempty"` // 分配到的 A/B 测试目标，为空表示没有参与分流
+	// 写入时由 User-Agent 解析得到，见 internal/useragent
+	Browser string `gorm:"size:64;not null;default:''" json:"browser,omitempty"`
+	OS      string `gorm:"size:32;not null;default:''" json:"os,omitempty"`
+	Device  string `gorm:"size:16;not null;default:''" json:"device,omitempty"` // desktop / mobile / tablet / bot
+	IsBot   bool   `gorm:"not null;default:false" json:"is_bot"`                // 爬虫、链接预览、监控等，不计入点击数
+	// 由 IP 查询 GeoIP 数据库得到，未配置数据库或查询不到时为空
+	Country   string    `gorm:"size:2;not null;default:''" json:"country,omitempty"` // ISO 3166-1 alpha-2
+	Region    string    `gorm:"size:100;not null;default:''" json:"region,omitempty"`
+	City      string    `gorm:"size:100;not null;default:''" json:"city,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_click_events_url_time,priority:2;index:idx_click_events_tenant_time,priority:2" json:"created_at"` // 分别与 ShortURLID、TenantID 组成联合索引，用于单链接时间序列查询和按租户导出
+}
+
+// Webhook 事件类型
+const (
+	EventURLCreated    = "url.created"
+	EventURLUpdated    = "url.updated"
+	EventURLDeleted    = "url.deleted"
+	EventClickRecorded = "click.recorded"
+	EventQuotaWarning  = "quota.warning"
+)
+
+// WebhookEvents 可以订阅的全部事件
+var WebhookEvents = []string{EventURLCreated, EventURLUpdated, EventURLDeleted, EventClickRecorded, EventQuotaWarning}
+
+// Webhook 租户配置的事件推送地址
+// Secret 用于对推送内容签名，需要保存明文，只在创建时返回一次
+type Webhook struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	ShortURLID uuid.UUID `gorm:"type:uuid;index;not null" json:"short_url_id"`
-	TenantID  uuid.UUID `gorm:"type:uuid;index;not null" json:"tenant_id"`    // 冗余存储租户ID，方便按租户查询
-	IP        string    `gorm:"size:45" json:"ip"`
-	UserAgent string    `gorm:"type:text" json:"user_agent"`
-	Referer   string    `gorm:"type:text" json:"referer"`
+	TenantID  uuid.UUID `gorm:"type:uuid;index;not null" json:"tenant_id"`
+	URL       string    `gorm:"type:text;not null" json:"url"`
+	Secret    string    `gorm:"size:64;not null" json:"-"`
+	Events    string    `gorm:"size:255;not null" json:"-"`             // 逗号分隔的订阅事件
+	IsActive  bool      `gorm:"not null;default:true" json:"is_active"` // 停用后不再生成新的投递，已排队的投递暂停发送
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// EventList 返回订阅的事件列表
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return nil
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// Subscribes 是否订阅了 event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook 投递状态
+const (
+	DeliveryPending   = "pending"   // 等待投递或等待重试
+	DeliveryDelivered = "delivered" // 对方返回 2xx
+	DeliveryDead      = "dead"      // 重试次数用完，只能手动重新投递
+)
+
+// WebhookDelivery 一个事件对一个 Webhook 的投递记录，同时作为持久化的投递队列
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	TenantID       uuid.UUID  `gorm:"type:uuid;not null" json:"tenant_id"`
+	WebhookID      uuid.UUID  `gorm:"type:uuid;not null" json:"webhook_id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null" json:"event_id"` // 同一事件投递到多个 Webhook 时相同，接收方可以据此去重
+	Event          string     `gorm:"size:32;not null" json:"event"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"-"` // 推送的请求体，重试和重新投递时原样发送
+	Status         string     `gorm:"size:16;not null;default:pending" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `gorm:"not null;default:0" json:"response_status"` // 最近一次投递的 HTTP 状态码，0 表示没有收到响应
+	LastError      string     `gorm:"type:text;not null;default:''" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookPayload 推送的请求体
+type WebhookPayload struct {
+	ID        uuid.UUID   `json:"id"`   // 事件 ID，即 WebhookDelivery.EventID
+	Type      string      `json:"type"` // 事件类型，如 url.created
+	TenantID  uuid.UUID   `json:"tenant_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
 }
 
 // --- 请求/响应 DTO ---
 
 // CreateShortURLRequest 创建短链接请求
 type CreateShortURLRequest struct {
-	URL       string `json:"url" binding:"required,url"` // 原始 URL
+	URL        string `json:"url" binding:"required,url"` // 原始 URL
 	CustomCode string `json:"custom_code,omitempty"`      // 自定义短码（可选）
+	Domain     string `json:"domain,omitempty"`           // 已验证的自定义域名（可选），为空时使用默认域名
+
+	// 有效期设置（均为可选）
+	// expires_at 与 expires_in 二选一，expires_in 为相对时长，如 "72h"
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`                           // 生效时间
+	MaxClicks int64      `json:"max_clicks,omitempty" binding:"omitempty,min=0"` // 最大点击次数
+
+	// 整理与搜索（均为可选）
+	Title  string   `json:"title,omitempty" binding:"max=255"`
+	Folder string   `json:"folder,omitempty" binding:"max=100"`
+	Tags   []string `json:"tags,omitempty"` // 标签不区分大小写，统一保存为小写
+
+	// 访问密码（可选），只保存 bcrypt 哈希
+	Password string `json:"password,omitempty" binding:"omitempty,min=4,max=72"`
+
+	// 条件跳转规则（可选），按顺序匹配
+	Rules []RedirectRule `json:"rules,omitempty"`
+
+	// A/B 测试目标（可选），没有命中规则时按权重分配
+	Variants []ShortURLVariant `json:"variants,omitempty"`
+
+	// 重定向选项（均为可选）
+	RedirectStatus int        `json:"redirect_status,omitempty"` // 301 / 302（默认）/ 307 / 308
+	ForwardQuery   bool       `json:"forward_query,omitempty"`
+	ForwardPath    bool       `json:"forward_path,omitempty"`
+	UTM            *UTMParams `json:"utm,omitempty"`
+}
+
+// BatchCreateRequest 批量创建短链接请求
+type BatchCreateRequest struct {
+	Items []CreateShortURLRequest `json:"items" binding:"required,min=1"`
+}
+
+// ItemError 批量创建/导入中单条记录的错误
+type ItemError struct {
+	Code       string `json:"code"` // 错误码，如 INVALID_REQUEST / CODE_TAKEN / QUOTA_EXCEEDED
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"` // CODE_TAKEN 时可用的候选短码
+}
+
+// BatchItemResult 批量创建中单条记录的结果，URL 与 Error 二选一
+type BatchItemResult struct {
+	Index int               `json:"index"` // 在请求 items 中的下标
+	URL   *ShortURLResponse `json:"url,omitempty"`
+	Error *ItemError        `json:"error,omitempty"`
+}
+
+// BatchCreateResponse 批量创建短链接响应
+type BatchCreateResponse struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// ImportError 导入中失败的一行
+type ImportError struct {
+	Line int `json:"line"` // 行号，从 1 开始（CSV 表头也算一行）
+	ItemError
+}
+
+// ImportResponse 导入短链接响应
+// 导入数据量可能很大，只返回失败的行（最多 MaxImportErrors 条）
+type ImportResponse struct {
+	Total           int           `json:"total"`
+	Created         int           `json:"created"`
+	Failed          int           `json:"failed"`
+	Errors          []ImportError `json:"errors"`
+	ErrorsTruncated bool          `json:"errors_truncated,omitempty"`
+}
+
+// MaxImportErrors 导入响应中最多返回的失败行数
+const MaxImportErrors = 1000
+
+// UpdateShortURLRequest 更新短链接请求
+// 字段均为指针：nil 表示不修改该字段
+type UpdateShortURLRequest struct {
+	URL      *string `json:"url,omitempty" binding:"omitempty,url"` // 新的原始 URL
+	IsActive *bool   `json:"is_active,omitempty"`                   // 启用/停用
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn *string    `json:"expires_in,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	MaxClicks *int64     `json:"max_clicks,omitempty" binding:"omitempty,min=0"` // 设为 0 表示取消限制
+
+	Title  *string   `json:"title,omitempty" binding:"omitempty,max=255"`
+	Folder *string   `json:"folder,omitempty" binding:"omitempty,max=100"` // 设为 "" 表示移出文件夹
+	Tags   *[]string `json:"tags,omitempty"`                               // 整体替换标签，设为 [] 表示清空
+
+	Password *string `json:"password,omitempty"` // 设置新密码，设为 "" 表示取消密码保护
+
+	Rules *[]RedirectRule `json:"rules,omitempty"` // 整体替换条件跳转规则，设为 [] 表示清空
+
+	Variants *[]ShortURLVariant `json:"variants,omitempty"` // 整体替换 A/B 测试目标，设为 [] 表示停止分流；保留 ID 才能让访问者继续分配到原目标
+
+	RedirectStatus *int       `json:"redirect_status,omitempty"`
+	ForwardQuery   *bool      `json:"forward_query,omitempty"`
+	ForwardPath    *bool      `json:"forward_path,omitempty"`
+	UTM            *UTMParams `json:"utm,omitempty"` // 整体替换 UTM 参数，设为 {} 表示清空
 }
 
 // ShortURLResponse 短链接响应
 type ShortURLResponse struct {
 	ID          uuid.UUID  `json:"id"`
 	Code        string     `json:"code"`
-	ShortURL    string     `json:"short_url"`
+	Domain      string     `json:"domain,omitempty"`
+	ShortURL    string     `json:"short_url"` // 完整短链接地址；默认域名未配置 PUBLIC_BASE_URL 时为 /<code>
 	OriginalURL string     `json:"original_url"`
-	Clicks      int64      `json:"clicks"`
+	Title       string     `json:"title,omitempty"`
+	Folder      string     `json:"folder,omitempty"`
+	Tags        []string   `json:"tags"`
+	Clicks      int64      `json:"clicks"` // include_bots=true 时包含机器人点击
+	BotClicks   int64      `json:"bot_clicks"`
+	IsActive    bool       `json:"is_active"`
+	MaxClicks   int64      `json:"max_clicks,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+
+	PasswordProtected bool              `json:"password_protected"`
+	Rules             []RedirectRule    `json:"rules,omitempty"`
+	Variants          []ShortURLVariant `json:"variants,omitempty"`
+
+	RedirectStatus int        `json:"redirect_status"`
+	ForwardQuery   bool       `json:"forward_query"`
+	ForwardPath    bool       `json:"forward_path"`
+	UTM            *UTMParams `json:"utm,omitempty"`
+}
+
+// 短链接列表排序字段
+const (
+	SortCreatedAt = "created_at"
+	SortClicks    = "clicks"
+	SortCode      = "code"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// URLListFilter 短链接列表的筛选与排序条件，零值表示不筛选
+type URLListFilter struct {
+	Query         string     // 在短码、原始 URL、标题中做子串匹配（不区分大小写）
+	Tag           string     // 包含该标签
+	Folder        string     // 属于该文件夹
+	Active        *bool      // 是否启用
+	Expired       *bool      // 是否已过期（expires_at 早于当前时间）
+	CreatedAfter  *time.Time // 创建时间 >= CreatedAfter
+	CreatedBefore *time.Time // 创建时间 < CreatedBefore
+	Sort          string     // created_at（默认）/ clicks / code，按 clicks 排序时不含机器人点击
+	Order         string     // asc / desc，为空时 code 升序，其余倒序
+	IncludeBots   bool       // 响应中的 clicks 是否包含机器人点击
+}
+
+// SortValue 返回短链接在排序字段上的值，用作游标分页的排序键
+func (u *ShortURL) SortValue(sort string) any {
+	switch sort {
+	case SortClicks:
+		return u.Clicks
+	case SortCode:
+		return u.Code
+	default:
+		return u.CreatedAt
+	}
+}
+
+// PageRequest 游标分页请求参数
+type PageRequest struct {
+	Cursor       string // 上一次响应中的 next_cursor / prev_cursor，为空时从第一页开始
+	PageSize     int
+	IncludeTotal bool // 是否统计满足条件的总数（需要额外执行一次 COUNT）
+}
+
+// ShortURLListResponse 短链接列表响应
+type ShortURLListResponse struct {
+	Data       []ShortURLResponse `json:"data"`
+	NextCursor *string            `json:"next_cursor"` // 没有下一页时为 null
+	PrevCursor *string            `json:"prev_cursor"` // 没有上一页时为 null
+	PageSize   int                `json:"page_size"`
+	Total      *int64             `json:"total,omitempty"` // include_total=false 时不返回
 }
 
 // StatsResponse 统计响应
 type StatsResponse struct {
 	TotalURLs   int64 `json:"total_urls"`
-	TotalClicks int64 `json:"total_clicks"`
+	TotalClicks int64 `json:"total_clicks"` // include_bots=true 时包含机器人点击
+	BotClicks   int64 `json:"bot_clicks"`
 	ActiveURLs  int64 `json:"active_urls"`
 }
 
+// 点击分析时间粒度
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// TruncateToInterval 按 PostgreSQL date_trunc 的规则截断 UTC 时间（周从周一开始）
+func TruncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为 0
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// ClickBucket 时间序列中的一个时间桶
+type ClickBucket struct {
+	Time   time.Time `json:"time"` // 桶起始时间（UTC）
+	Clicks int64     `json:"clicks"`
+}
+
+// CountItem 分组计数（如来源、User-Agent 排行）
+type CountItem struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ClickAnalyticsResponse 单个短链接的点击分析响应
+type ClickAnalyticsResponse struct {
+	URLID         uuid.UUID      `json:"url_id"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Interval      string         `json:"interval"`
+	TotalClicks   int64          `json:"total_clicks"`
+	Series        []ClickBucket  `json:"series"`
+	TopReferrers  []CountItem    `json:"top_referrers"`
+	TopUserAgents []CountItem    `json:"top_user_agents"`
+	TopCountries  []CountItem    `json:"top_countries"`      // 国家/地区排行（ISO 代码），未配置 GeoIP 数据库时为空字符串
+	TopCities     []CountItem    `json:"top_cities"`         // 城市排行（不含查询不到城市的点击），取值如 "US/California/San Francisco"
+	Variants      []VariantStats `json:"variants,omitempty"` // A/B 测试各目标的点击数，没有分流时省略
+}
+
+// VariantStats A/B 测试目标在时间范围内的点击数
+// 已被删除但在时间范围内有点击的目标也会列出（URL 为空）
+type VariantStats struct {
+	ID     string  `json:"id"`
+	URL    string  `json:"url,omitempty"`
+	Weight int     `json:"weight"`
+	Clicks int64   `json:"clicks"`
+	Share  float64 `json:"share"` // 占全部分流点击的比例
+}
+
 // CreateTenantRequest 创建租户请求
 type CreateTenantRequest struct {
 	Name string `json:"name" binding:"required"`
@@ -93,3 +726,161 @@ type CreateTenantResponse struct {
 	APIKey string    `json:"api_key"` // 只在创建时返回一次
 	Plan   string    `json:"plan"`
 }
+
+// PlanRequest 套餐配额（创建/修改套餐共用）
+type PlanRequest struct {
+	DisplayName            string   `json:"display_name" binding:"max=255"`
+	RateLimit              int      `json:"rate_limit" binding:"required,min=1"`
+	MaxURLs                int      `json:"max_urls" binding:"required,min=1"`
+	MonthlyClickQuota      int64    `json:"monthly_click_quota" binding:"min=0"`
+	AllowCustomCode        bool     `json:"allow_custom_code"`
+	AnalyticsRetentionDays int      `json:"analytics_retention_days" binding:"min=0"`
+	Features               []string `json:"features"`
+}
+
+// CreatePlanRequest 创建套餐请求
+type CreatePlanRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+	PlanRequest
+}
+
+// PlanResponse 套餐信息
+type PlanResponse struct {
+	Name                   string    `json:"name"`
+	DisplayName            string    `json:"display_name"`
+	RateLimit              int       `json:"rate_limit"`
+	MaxURLs                int       `json:"max_urls"`
+	MonthlyClickQuota      int64     `json:"monthly_click_quota"`
+	AllowCustomCode        bool      `json:"allow_custom_code"`
+	AnalyticsRetentionDays int       `json:"analytics_retention_days"`
+	Features               []string  `json:"features"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// ChangePlanRequest 租户变更套餐请求
+type ChangePlanRequest struct {
+	Plan string `json:"plan" binding:"required"`
+}
+
+// CreateAPIKeyRequest 创建 API Key 请求
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=255"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"` // 相对时长，如 "720h"
+}
+
+// APIKeyResponse API Key 信息（不含明文）
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Revoked    bool       `json:"revoked"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse 创建/轮换 API Key 响应
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"` // 明文 Key 只返回一次
+}
+
+// 自定义域名所有权验证方式
+const (
+	DomainVerifyDNS  = "dns"
+	DomainVerifyHTTP = "http"
+)
+
+// CreateDomainRequest 添加自定义域名请求
+type CreateDomainRequest struct {
+	Hostname string `json:"hostname" binding:"required,max=253"`
+}
+
+// VerifyDomainRequest 验证域名所有权请求，method 为空时依次尝试 DNS 和 HTTP
+type VerifyDomainRequest struct {
+	Method string `json:"method,omitempty"`
+}
+
+// DomainVerification 域名所有权验证说明，二选一即可
+type DomainVerification struct {
+	TXTName  string `json:"txt_name"`  // 需要添加的 TXT 记录名
+	TXTValue string `json:"txt_value"` // TXT 记录的值
+	HTTPURL  string `json:"http_url"`  // 或者在该地址返回 HTTPBody
+	HTTPBody string `json:"http_body"`
+}
+
+// DomainResponse 自定义域名信息
+type DomainResponse struct {
+	ID           uuid.UUID           `json:"id"`
+	Hostname     string              `json:"hostname"`
+	Verified     bool                `json:"verified"`
+	VerifiedAt   *time.Time          `json:"verified_at,omitempty"`
+	Verification *DomainVerification `json:"verification,omitempty"` // 只在尚未验证时返回
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// CreateWebhookRequest 创建 Webhook 请求
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1"`
+}
+
+// UpdateWebhookRequest 修改 Webhook 请求，未传的字段保持不变
+type UpdateWebhookRequest struct {
+	URL      *string   `json:"url,omitempty" binding:"omitempty,url,max=2048"`
+	Events   *[]string `json:"events,omitempty" binding:"omitempty,min=1"`
+	IsActive *bool     `json:"is_active,omitempty"`
+}
+
+// WebhookResponse Webhook 信息（不含签名密钥）
+type WebhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateWebhookResponse 创建 Webhook 响应
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"` // 签名密钥只返回一次
+}
+
+// WebhookDeliveryResponse 投递记录
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"` // 只在 pending 时返回
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// WebhookDeliveryListResponse 投递记录列表响应（按创建时间倒序，游标分页）
+type WebhookDeliveryListResponse struct {
+	Data       []WebhookDeliveryResponse `json:"data"`
+	NextCursor *string                   `json:"next_cursor"`
+	PrevCursor *string                   `json:"prev_cursor"`
+	PageSize   int                       `json:"page_size"`
+}
+
+// QuotaWarning quota.warning 事件的内容
+type QuotaWarning struct {
+	Resource string `json:"resource"` // 目前只有 urls
+	Used     int64  `json:"used"`
+	Limit    int64  `json:"limit"`
+	Percent  int    `json:"percent"` // 触发提醒的阈值（百分比）
+}
@@ -0,0 +1,175 @@
+// Package pagination 基于键集（keyset）的游标分页
+//
+// 与 OFFSET 分页相比：
+// 1. 翻到多深都只扫描一页的数据（WHERE (sort_col, id) < (?, ?) 配合索引）
+// 2. 翻页期间新增或删除数据不会导致重复或遗漏
+// 3. 游标对客户端不透明，内部结构可以随时调整
+//
+// 列表按 "排序列 + id" 排序，游标记录一页边界行的这两个值；
+// 短链接列表和点击事件列表等任何按 (列, id) 排序的查询都可以复用
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor 游标无法解析，或与当前的排序方式不一致
+var ErrInvalidCursor = errors.New("cursor 无效")
+
+// 每页条数
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Key 一行数据在排序中的位置：排序列的值 + 唯一 ID（排序列的值相同时决定先后）
+// Value 只能是 time.Time、int64 或 string
+type Key struct {
+	Value any
+	ID    uuid.UUID
+}
+
+// Query 一页数据的查询条件
+type Query struct {
+	Limit    int  // 每页条数，存储层需要多取一行（Limit+1）用于判断是否还有更多数据
+	After    *Key // 为 nil 时从第一页开始
+	Backward bool // true 表示取 After 之前的一页（上一页），存储层需要反向排序
+}
+
+// Cursor 游标内容，编码为不透明的 base64 字符串返回给客户端
+type Cursor struct {
+	Sort     string    `json:"s"` // 生成游标时的排序方式，换了排序方式的游标不能继续使用
+	Order    string    `json:"o"`
+	Value    string    `json:"v"`
+	ID       uuid.UUID `json:"id"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// Encode 将游标编码为 URL 安全的字符串
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode 解析客户端传回的游标
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// NewCursor 由排序方式和边界行的位置生成游标
+func NewCursor(sort, order string, key *Key, backward bool) (*Cursor, error) {
+	var value string
+	switch v := key.Value.(type) {
+	case time.Time:
+		value = v.UTC().Format(time.RFC3339Nano)
+	case int64:
+		value = strconv.FormatInt(v, 10)
+	case string:
+		value = v
+	default:
+		return nil, fmt.Errorf("不支持的排序值类型 %T", key.Value)
+	}
+	return &Cursor{Sort: sort, Order: order, Value: value, ID: key.ID, Backward: backward}, nil
+}
+
+// Query 校验游标与当前排序方式一致，并转换为查询条件
+// sample 为排序列的一个示例值，用于确定 Value 的类型
+func (c *Cursor) Query(sort, order string, sample any, limit int) (Query, error) {
+	if c.Sort != sort || c.Order != order {
+		return Query{}, fmt.Errorf("%w: 游标由其他排序方式生成", ErrInvalidCursor)
+	}
+
+	var value any
+	var err error
+	switch sample.(type) {
+	case time.Time:
+		value, err = time.Parse(time.RFC3339Nano, c.Value)
+	case int64:
+		value, err = strconv.ParseInt(c.Value, 10, 64)
+	case string:
+		value = c.Value
+	default:
+		return Query{}, fmt.Errorf("不支持的排序值类型 %T", sample)
+	}
+	if err != nil {
+		return Query{}, ErrInvalidCursor
+	}
+	return Query{Limit: limit, After: &Key{Value: value, ID: c.ID}, Backward: c.Backward}, nil
+}
+
+// Compare 比较两个排序值，用于内存实现
+func Compare(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	panic(fmt.Sprintf("pagination: 不支持的排序值类型 %T", a))
+}
+
+// Trim 处理存储层多取的一行，恢复正常顺序，并返回上一页/下一页的边界
+// items 为存储层按 q 查询的结果（最多 Limit+1 条，Backward 时为反向顺序）；
+// next / prev 为 nil 表示没有下一页 / 上一页
+func Trim[T any](items []T, q Query, key func(*T) Key) (page []T, next, prev *Key) {
+	hasMore := len(items) > q.Limit
+	if hasMore {
+		items = items[:q.Limit]
+	}
+	if q.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if len(items) == 0 {
+		return items, nil, nil
+	}
+
+	first, last := key(&items[0]), key(&items[len(items)-1])
+	if q.Backward {
+		// 向前翻：多取到的一行说明前面还有数据；后面一定有数据（至少是游标所在行）
+		if hasMore {
+			prev = &first
+		}
+		next = &last
+		return items, next, prev
+	}
+	if hasMore {
+		next = &last
+	}
+	if q.After != nil {
+		prev = &first
+	}
+	return items, next, prev
+}
@@ -0,0 +1,156 @@
+// Package qr 在进程内生成二维码图片（PNG / SVG），不依赖外部服务
+// 二维码矩阵由 go-qrcode 编码，图片由本包绘制，以便统一控制尺寸、边距和颜色
+package qr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	goqrcode "github.com/skip2/go-qrcode"
+)
+
+// 图片格式
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Level 纠错等级，等级越高越能容忍污损，但同样内容的二维码越密
+type Level = goqrcode.RecoveryLevel
+
+const (
+	LevelL = goqrcode.Low     // 约 7%
+	LevelM = goqrcode.Medium  // 约 15%
+	LevelQ = goqrcode.High    // 约 25%
+	LevelH = goqrcode.Highest // 约 30%
+)
+
+// Options 绘制参数
+type Options struct {
+	Format     string // png / svg
+	Size       int    // 图片宽高（像素），SVG 为 width / height 属性
+	Margin     int    // 四周空白（静区）宽度，单位为模块
+	Level      Level
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// Render 生成 content 的二维码图片
+// PNG 中每个模块取整数像素，剩余像素平均分到四周，保证边缘清晰；
+// 参数不合法或 PNG 的 Size 小于模块总数（含边距）时返回错误
+func Render(content string, opts Options) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	code, err := goqrcode.New(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	if opts.Format == FormatSVG {
+		return renderSVG(bitmap, opts), nil
+	}
+	return renderPNG(bitmap, opts)
+}
+
+func (o *Options) validate() error {
+	switch {
+	case o.Format != FormatPNG && o.Format != FormatSVG:
+		return fmt.Errorf("图片格式不合法: %q", o.Format)
+	case o.Size <= 0:
+		return fmt.Errorf("尺寸必须大于 0: %d", o.Size)
+	case o.Margin < 0:
+		return fmt.Errorf("边距不能为负数: %d", o.Margin)
+	case o.Level < LevelL || o.Level > LevelH:
+		return fmt.Errorf("纠错等级不合法: %d", o.Level)
+	}
+	return nil
+}
+
+func renderPNG(bitmap [][]bool, opts Options) ([]byte, error) {
+	modules := len(bitmap) + 2*opts.Margin
+	scale := opts.Size / modules
+	if scale < 1 {
+		return nil, fmt.Errorf("尺寸过小，至少需要 %d 像素", modules)
+	}
+	offset := (opts.Size-scale*modules)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{opts.Background, opts.Foreground})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG 以模块为单位的 viewBox 绘制，同一行连续的深色模块合并为一个矩形
+func renderSVG(bitmap [][]bool, opts Options) []byte {
+	modules := len(bitmap) + 2*opts.Margin
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	if opts.Background.A > 0 {
+		fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" %s/>`, svgFill(opts.Background))
+	}
+	fmt.Fprintf(&buf, `<path d="%s" %s/></svg>`, path.String(), svgFill(opts.Foreground))
+	return buf.Bytes()
+}
+
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A < 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(c.A)/0xff)
+	}
+	return fill
+}
+
+// ParseColor 解析十六进制颜色：RGB、RRGGBB 或 RRGGBBAA，可以带 # 前缀
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return color.NRGBA{}, fmt.Errorf("颜色格式错误: %q", s)
+	}
+	return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+}
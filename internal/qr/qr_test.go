@@ -0,0 +1,178 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var (
+	black = color.NRGBA{A: 0xff}
+	white = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+const content = "https://s.example.com/abc123"
+
+func options(format string, size int) Options {
+	return Options{Format: format, Size: size, Margin: 4, Level: LevelM, Foreground: black, Background: white}
+}
+
+// modules 从 SVG 的 viewBox 读取模块总数（含边距）
+func modules(t *testing.T, svg []byte) int {
+	t.Helper()
+	var n, m int
+	i := bytes.Index(svg, []byte(`viewBox="`))
+	if i < 0 {
+		t.Fatalf("没有 viewBox: %s", svg)
+	}
+	if _, err := fmt.Sscanf(string(svg[i:]), `viewBox="0 0 %d %d"`, &n, &m); err != nil || n != m {
+		t.Fatalf("viewBox = %q, err = %v", svg[i:i+30], err)
+	}
+	return n
+}
+
+func TestRenderPNG(t *testing.T) {
+	data, err := Render(content, options(FormatPNG, 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Fatalf("bounds = %v", b)
+	}
+
+	svg, err := Render(content, options(FormatSVG, 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := modules(t, svg)
+	scale := 300 / n
+	offset := (300-scale*n)/2 + 4*scale
+
+	// 边距为背景色，左上角定位图案的外框为前景色
+	if got := color.NRGBAModel.Convert(img.At(offset-1, offset-1)); got != white {
+		t.Errorf("边距颜色 = %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(offset, offset)); got != black {
+		t.Errorf("定位图案颜色 = %v", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(offset+scale, offset+scale)); got != white {
+		t.Errorf("定位图案内圈颜色 = %v", got)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	opts := options(FormatSVG, 256)
+	opts.Foreground = color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x80}
+	data, err := Render(content, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"`,
+		`<rect width="100%" height="100%" fill="#ffffff"/>`,
+		`fill="#112233" fill-opacity="0.502"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG 缺少 %s: %s", want, svg)
+		}
+	}
+
+	// 透明背景不绘制背景矩形
+	opts.Background = color.NRGBA{}
+	data, err = Render(content, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("<rect")) {
+		t.Errorf("透明背景不应绘制 rect: %s", data)
+	}
+
+	// 边距计入 viewBox
+	opts.Margin = 0
+	noMargin, err := Render(content, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := modules(t, data)-modules(t, noMargin), 8; got != want {
+		t.Errorf("边距占用的模块数 = %d, want %d", got, want)
+	}
+}
+
+func TestRenderLevels(t *testing.T) {
+	// 纠错等级越高，同样内容的二维码模块越多
+	prev := 0
+	for _, level := range []Level{LevelL, LevelM, LevelQ, LevelH} {
+		opts := options(FormatSVG, 300)
+		opts.Level = level
+		data, err := Render(strings.Repeat(content, 2), opts)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		n := modules(t, data)
+		if n < prev {
+			t.Errorf("level %d: modules = %d, 小于更低等级的 %d", level, n, prev)
+		}
+		prev = n
+	}
+}
+
+func TestRenderInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+	}{
+		{"未知格式", func(o *Options) { o.Format = "gif" }},
+		{"格式为空", func(o *Options) { o.Format = "" }},
+		{"尺寸为 0", func(o *Options) { o.Size = 0 }},
+		{"尺寸为负数", func(o *Options) { o.Size = -1 }},
+		{"PNG 尺寸小于模块数", func(o *Options) { o.Size = 20 }},
+		{"边距为负数", func(o *Options) { o.Margin = -1 }},
+		{"纠错等级过低", func(o *Options) { o.Level = LevelL - 1 }},
+		{"纠错等级过高", func(o *Options) { o.Level = LevelH + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options(FormatPNG, 300)
+			tt.modify(&opts)
+			if _, err := Render(content, opts); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+
+	// SVG 是矢量图，尺寸小于模块数也可以绘制
+	if _, err := Render(content, options(FormatSVG, 20)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{in: "000", want: black},
+		{in: "#fff", want: white},
+		{in: "1a2B3c", want: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}},
+		{in: "#11223344", want: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x44}},
+		{in: "", wantErr: true},
+		{in: "12345", wantErr: true},
+		{in: "gggggg", wantErr: true},
+		{in: "#123456789", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v (err: %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
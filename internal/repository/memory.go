@@ -0,0 +1,1475 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/pagination"
+)
+
+// MemoryStore Store 的内存实现
+// 线程安全，行为与 Repository 保持一致（租户隔离、唯一约束、只返回启用的链接等），
+// 用于单元测试和本地演示，进程退出后数据丢失
+type MemoryStore struct {
+	mu         sync.RWMutex
+	tenants    map[uuid.UUID]model.Tenant
+	plans      map[string]model.Plan
+	apiKeys    map[uuid.UUID]model.APIKey
+	domains    map[uuid.UUID]model.Domain
+	webhooks   map[uuid.UUID]model.Webhook
+	deliveries map[uuid.UUID]model.WebhookDelivery
+	urls       map[uuid.UUID]model.ShortURL
+	events     []model.ClickEvent
+	rateLimit  map[uuid.UUID][]time.Time
+	failures   map[string]failureWindow
+	clickCount map[uuid.UUID]failureWindow   // 点击上限计数，见 ReserveClick
+	qrCodes    map[string]map[string]qrEntry // ShortURL.LinkKey -> 绘制参数 -> 图片
+
+	streamMu   sync.Mutex // 保护点击流，与 mu 分开，订阅者读取时不阻塞其他操作
+	streams    map[uuid.UUID]*memoryClickStream
+	lastStream uint64 // 上一个点击流 ID 的毫秒时间戳
+	streamSeq  uint64 // 同一毫秒内的序号
+
+	now func() time.Time // 可替换的时钟，便于测试限流窗口
+}
+
+// defaultPlans 内置套餐，与 migrations/0005_plans.up.sql 中的初始数据保持一致
+var defaultPlans = []model.Plan{
+	{Name: "free", DisplayName: "Free", RateLimit: 100, MaxURLs: 1000, MonthlyClickQuota: 10000, AllowCustomCode: true, AnalyticsRetentionDays: 30},
+	{Name: "pro", DisplayName: "Pro", RateLimit: 500, MaxURLs: 10000, MonthlyClickQuota: 1000000, AllowCustomCode: true, AnalyticsRetentionDays: 365},
+	{Name: "enterprise", DisplayName: "Enterprise", RateLimit: 5000, MaxURLs: 100000, AllowCustomCode: true},
+}
+
+// NewMemoryStore 创建内存存储，预置与数据库迁移相同的内置套餐
+func NewMemoryStore() *MemoryStore {
+	now := time.Now()
+	plans := make(map[string]model.Plan, len(defaultPlans))
+	for _, plan := range defaultPlans {
+		plan.CreatedAt, plan.UpdatedAt = now, now
+		plans[plan.Name] = plan
+	}
+
+	return &MemoryStore{
+		tenants:    make(map[uuid.UUID]model.Tenant),
+		plans:      plans,
+		apiKeys:    make(map[uuid.UUID]model.APIKey),
+		domains:    make(map[uuid.UUID]model.Domain),
+		webhooks:   make(map[uuid.UUID]model.Webhook),
+		deliveries: make(map[uuid.UUID]model.WebhookDelivery),
+		urls:       make(map[uuid.UUID]model.ShortURL),
+		rateLimit:  make(map[uuid.UUID][]time.Time),
+		failures:   make(map[string]failureWindow),
+		clickCount: make(map[uuid.UUID]failureWindow),
+		qrCodes:    make(map[string]map[string]qrEntry),
+		streams:    make(map[uuid.UUID]*memoryClickStream),
+		now:        time.Now,
+	}
+}
+
+// ==================== 租户相关操作 ====================
+
+// CreateTenant 创建新租户及其第一个 API Key
+func (m *MemoryStore) CreateTenant(ctx context.Context, tenant *model.Tenant, key *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tenants[tenant.ID]; ok {
+		return ErrDuplicate
+	}
+	if err := m.checkKeyHashLocked(key.KeyHash); err != nil {
+		return err
+	}
+
+	now := m.now()
+	stamp(&tenant.CreatedAt, now)
+	stamp(&tenant.UpdatedAt, now)
+	stamp(&key.CreatedAt, now)
+	m.tenants[tenant.ID] = *tenant
+	m.apiKeys[key.ID] = *key
+	return nil
+}
+
+// GetTenantByID 通过 ID 查询租户
+func (m *MemoryStore) GetTenantByID(ctx context.Context, id uuid.UUID) (*model.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenant, ok := m.tenants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &tenant, nil
+}
+
+// GetTenantByAPIKey 通过 API Key 哈希查询 Key 及其所属租户
+func (m *MemoryStore) GetTenantByAPIKey(ctx context.Context, keyHash string) (*model.Tenant, *model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if key.KeyHash != keyHash || key.Revoked {
+			continue
+		}
+		tenant, ok := m.tenants[key.TenantID]
+		if !ok || !tenant.IsActive {
+			return nil, nil, ErrNotFound
+		}
+		return &tenant, &key, nil
+	}
+	return nil, nil, ErrNotFound
+}
+
+// ChangeTenantPlan 切换租户套餐
+func (m *MemoryStore) ChangeTenantPlan(ctx context.Context, tenantID uuid.UUID, plan *model.Plan) (*model.Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	tenant.Plan = plan.Name
+	tenant.RateLimit = plan.RateLimit
+	tenant.MaxURLs = plan.MaxURLs
+	tenant.UpdatedAt = m.now()
+	m.tenants[tenantID] = tenant
+	return &tenant, nil
+}
+
+// CountTenantsByPlan 统计使用指定套餐的租户数
+func (m *MemoryStore) CountTenantsByPlan(ctx context.Context, plan string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, tenant := range m.tenants {
+		if tenant.Plan == plan {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ==================== 套餐相关操作 ====================
+
+// CreatePlan 创建套餐，名称重复时返回 ErrDuplicate
+func (m *MemoryStore) CreatePlan(ctx context.Context, plan *model.Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plans[plan.Name]; ok {
+		return ErrDuplicate
+	}
+	now := m.now()
+	stamp(&plan.CreatedAt, now)
+	stamp(&plan.UpdatedAt, now)
+	m.plans[plan.Name] = *plan
+	return nil
+}
+
+// GetPlan 查询套餐
+func (m *MemoryStore) GetPlan(ctx context.Context, name string) (*model.Plan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plan, ok := m.plans[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &plan, nil
+}
+
+// ListPlans 查询所有套餐，按限流配额从低到高排列
+func (m *MemoryStore) ListPlans(ctx context.Context) ([]model.Plan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plans := make([]model.Plan, 0, len(m.plans))
+	for _, plan := range m.plans {
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].RateLimit != plans[j].RateLimit {
+			return plans[i].RateLimit < plans[j].RateLimit
+		}
+		return plans[i].Name < plans[j].Name
+	})
+	return plans, nil
+}
+
+// UpdatePlan 修改套餐，并同步该套餐下所有租户的配额
+func (m *MemoryStore) UpdatePlan(ctx context.Context, plan *model.Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.plans[plan.Name]
+	if !ok {
+		return ErrNotFound
+	}
+	plan.CreatedAt = existing.CreatedAt
+	plan.UpdatedAt = m.now()
+	m.plans[plan.Name] = *plan
+
+	for id, tenant := range m.tenants {
+		if tenant.Plan == plan.Name {
+			tenant.RateLimit = plan.RateLimit
+			tenant.MaxURLs = plan.MaxURLs
+			m.tenants[id] = tenant
+		}
+	}
+	return nil
+}
+
+// DeletePlan 删除套餐
+func (m *MemoryStore) DeletePlan(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plans[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.plans, name)
+	return nil
+}
+
+// ==================== API Key 相关操作 ====================
+
+// CreateAPIKey 创建 API Key
+func (m *MemoryStore) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkKeyHashLocked(key.KeyHash); err != nil {
+		return err
+	}
+	stamp(&key.CreatedAt, m.now())
+	m.apiKeys[key.ID] = *key
+	return nil
+}
+
+// ListAPIKeysByTenant 查询租户的所有 API Key（按创建时间倒序）
+func (m *MemoryStore) ListAPIKeysByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []model.APIKey
+	for _, key := range m.apiKeys {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// RevokeAPIKey 吊销租户的 API Key
+func (m *MemoryStore) RevokeAPIKey(ctx context.Context, tenantID, id uuid.UUID) (*model.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok || key.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	var keys []model.APIKey
+	for _, k := range m.apiKeys {
+		if k.TenantID == tenantID {
+			keys = append(keys, k)
+		}
+	}
+	now := m.now()
+	if isLastAdminKey(keys, id, now) {
+		return nil, ErrLastAdminKey
+	}
+	key.Revoked = true
+	key.RevokedAt = &now
+	m.apiKeys[id] = key
+	return &key, nil
+}
+
+// RotateAPIKey 轮换 API Key：吊销旧 Key 并创建继承其名称和权限的新 Key
+func (m *MemoryStore) RotateAPIKey(ctx context.Context, tenantID, id uuid.UUID, newKey *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.apiKeys[id]
+	if !ok || old.TenantID != tenantID || old.Revoked {
+		return ErrNotFound
+	}
+	if err := m.checkKeyHashLocked(newKey.KeyHash); err != nil {
+		return err
+	}
+
+	now := m.now()
+	old.Revoked = true
+	old.RevokedAt = &now
+	m.apiKeys[id] = old
+
+	newKey.TenantID = old.TenantID
+	newKey.Name = old.Name
+	newKey.Scopes = old.Scopes
+	newKey.ExpiresAt = old.ExpiresAt
+	stamp(&newKey.CreatedAt, now)
+	m.apiKeys[newKey.ID] = *newKey
+	return nil
+}
+
+// TouchAPIKey 更新 API Key 的最近使用时间
+func (m *MemoryStore) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.apiKeys[id]; ok {
+		now := m.now()
+		key.LastUsedAt = &now
+		m.apiKeys[id] = key
+	}
+	return nil
+}
+
+// checkKeyHashLocked 检查 Key 哈希唯一约束，调用方需持有写锁
+func (m *MemoryStore) checkKeyHashLocked(keyHash string) error {
+	for _, k := range m.apiKeys {
+		if k.KeyHash == keyHash {
+			return ErrDuplicate
+		}
+	}
+	return nil
+}
+
+// ==================== 自定义域名 ====================
+
+// CreateDomain 添加租户的自定义域名，同一租户内域名唯一
+func (m *MemoryStore) CreateDomain(ctx context.Context, domain *model.Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.domains {
+		if d.TenantID == domain.TenantID && d.Hostname == domain.Hostname {
+			return ErrDuplicate
+		}
+	}
+
+	now := m.now()
+	stamp(&domain.CreatedAt, now)
+	stamp(&domain.UpdatedAt, now)
+	m.domains[domain.ID] = *domain
+	return nil
+}
+
+// ListDomainsByTenant 按添加时间查询租户的全部域名
+func (m *MemoryStore) ListDomainsByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Domain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var domains []model.Domain
+	for _, d := range m.domains {
+		if d.TenantID == tenantID {
+			domains = append(domains, d)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].CreatedAt.Before(domains[j].CreatedAt)
+	})
+	return domains, nil
+}
+
+// CountDomainsByTenant 统计租户的域名数量（包括尚未验证的）
+func (m *MemoryStore) CountDomainsByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, d := range m.domains {
+		if d.TenantID == tenantID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetDomainByID 按 ID 查询租户自己的域名
+func (m *MemoryStore) GetDomainByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Domain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.domains[id]
+	if !ok || d.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+// GetDomainByHostname 按域名查询租户自己的域名
+func (m *MemoryStore) GetDomainByHostname(ctx context.Context, tenantID uuid.UUID, hostname string) (*model.Domain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.domains {
+		if d.TenantID == tenantID && d.Hostname == hostname {
+			return &d, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// VerifyDomain 标记域名已通过验证，同一域名只能有一个租户通过验证
+func (m *MemoryStore) VerifyDomain(ctx context.Context, tenantID, id uuid.UUID, verifiedAt time.Time) (*model.Domain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.domains[id]
+	if !ok || d.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	for _, other := range m.domains {
+		if other.ID != id && other.Hostname == d.Hostname && other.IsVerified() {
+			return nil, ErrDuplicate
+		}
+	}
+
+	d.VerifiedAt = &verifiedAt
+	d.UpdatedAt = m.now()
+	m.domains[id] = d
+	return &d, nil
+}
+
+// DeleteDomain 删除租户自己的域名
+func (m *MemoryStore) DeleteDomain(ctx context.Context, tenantID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.domains[id]
+	if !ok || d.TenantID != tenantID {
+		return ErrNotFound
+	}
+	delete(m.domains, id)
+	return nil
+}
+
+// IsVerifiedDomain 判断 hostname 是否为已验证的自定义域名
+func (m *MemoryStore) IsVerifiedDomain(ctx context.Context, hostname string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.domains {
+		if d.Hostname == hostname && d.IsVerified() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ==================== Webhook ====================
+
+// CreateWebhook 创建 Webhook
+func (m *MemoryStore) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[webhook.ID]; ok {
+		return ErrDuplicate
+	}
+	now := m.now()
+	stamp(&webhook.CreatedAt, now)
+	stamp(&webhook.UpdatedAt, now)
+	m.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+// ListWebhooksByTenant 按创建时间查询租户的全部 Webhook
+func (m *MemoryStore) ListWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var webhooks []model.Webhook
+	for _, w := range m.webhooks {
+		if w.TenantID == tenantID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+// CountWebhooksByTenant 统计租户的 Webhook 数量
+func (m *MemoryStore) CountWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, w := range m.webhooks {
+		if w.TenantID == tenantID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetWebhookByID 按 ID 查询租户自己的 Webhook
+func (m *MemoryStore) GetWebhookByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	w, ok := m.webhooks[id]
+	if !ok || w.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return &w, nil
+}
+
+// UpdateWebhook 修改租户自己的 Webhook
+func (m *MemoryStore) UpdateWebhook(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}) (*model.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.webhooks[id]
+	if !ok || w.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	for column, value := range updates {
+		switch column {
+		case "url":
+			w.URL = value.(string)
+		case "events":
+			w.Events = value.(string)
+		case "is_active":
+			w.IsActive = value.(bool)
+		default:
+			return nil, fmt.Errorf("memory store: 不支持更新列 %q", column)
+		}
+	}
+	w.UpdatedAt = m.now()
+	m.webhooks[id] = w
+	return &w, nil
+}
+
+// DeleteWebhook 删除租户自己的 Webhook 及其投递记录
+func (m *MemoryStore) DeleteWebhook(ctx context.Context, tenantID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.webhooks[id]
+	if !ok || w.TenantID != tenantID {
+		return ErrNotFound
+	}
+	delete(m.webhooks, id)
+	for deliveryID, d := range m.deliveries {
+		if d.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// ListSubscribedWebhooks 查询这些租户中订阅了 event 的启用中的 Webhook
+func (m *MemoryStore) ListSubscribedWebhooks(ctx context.Context, tenantIDs []uuid.UUID, event string) ([]model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var webhooks []model.Webhook
+	for _, w := range m.webhooks {
+		if w.IsActive && slices.Contains(tenantIDs, w.TenantID) && w.Subscribes(event) {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+// GetWebhooks 按 ID 批量查询 Webhook
+func (m *MemoryStore) GetWebhooks(ctx context.Context, ids []uuid.UUID) ([]model.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var webhooks []model.Webhook
+	for _, id := range ids {
+		if w, ok := m.webhooks[id]; ok {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+// CreateWebhookDeliveries 批量写入投递记录
+func (m *MemoryStore) CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range deliveries {
+		if _, ok := m.deliveries[d.ID]; ok {
+			return ErrDuplicate
+		}
+	}
+	now := m.now()
+	for _, d := range deliveries {
+		stamp(&d.CreatedAt, now)
+		stamp(&d.UpdatedAt, now)
+		m.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries 领取到期的 pending 投递，按 next_attempt_at 先后
+func (m *MemoryStore) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []model.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) && m.webhooks[d.WebhookID].IsActive {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		due[i].UpdatedAt = now
+		m.deliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// SaveWebhookAttempt 保存一次投递的结果
+func (m *MemoryStore) SaveWebhookAttempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.deliveries[delivery.ID]
+	if !ok {
+		return nil // 与 UPDATE 一致：Webhook 已删除时不报错
+	}
+	d.Status = delivery.Status
+	d.Attempts = delivery.Attempts
+	d.NextAttemptAt = delivery.NextAttemptAt
+	d.LastAttemptAt = delivery.LastAttemptAt
+	d.ResponseStatus = delivery.ResponseStatus
+	d.LastError = delivery.LastError
+	d.DeliveredAt = delivery.DeliveredAt
+	d.UpdatedAt = m.now()
+	m.deliveries[d.ID] = d
+	return nil
+}
+
+// ListWebhookDeliveries 按创建时间倒序分页查询 Webhook 的投递记录，语义与 Repository 一致
+func (m *MemoryStore) ListWebhookDeliveries(ctx context.Context, tenantID, webhookID uuid.UUID, status string, page pagination.Query) ([]model.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// compare 按 (created_at, id) 比较，倒序时 a 排在 b 之前返回负数
+	compare := func(a *model.WebhookDelivery, createdAt time.Time, id uuid.UUID) int {
+		c := createdAt.Compare(a.CreatedAt)
+		if c == 0 {
+			c = strings.Compare(id.String(), a.ID.String())
+		}
+		if page.Backward {
+			c = -c
+		}
+		return c
+	}
+
+	var deliveries []model.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.TenantID != tenantID || d.WebhookID != webhookID || (status != "" && d.Status != status) {
+			continue
+		}
+		if page.After != nil && compare(&d, page.After.Value.(time.Time), page.After.ID) >= 0 {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return compare(&deliveries[i], deliveries[j].CreatedAt, deliveries[j].ID) < 0
+	})
+
+	if len(deliveries) > page.Limit+1 {
+		deliveries = deliveries[:page.Limit+1]
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhookDelivery 把投递重新放回队列
+func (m *MemoryStore) RedeliverWebhookDelivery(ctx context.Context, tenantID, webhookID, id uuid.UUID, now time.Time) (*model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.deliveries[id]
+	if !ok || d.TenantID != tenantID || d.WebhookID != webhookID {
+		return nil, ErrNotFound
+	}
+	d.Status = model.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.DeliveredAt = nil
+	d.UpdatedAt = m.now()
+	m.deliveries[id] = d
+	return &d, nil
+}
+
+// PurgeWebhookDeliveries 删除过期的已投递 / 已放弃记录
+func (m *MemoryStore) PurgeWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for id, d := range m.deliveries {
+		if d.Status != model.DeliveryPending && d.CreatedAt.Before(before) {
+			delete(m.deliveries, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ==================== 短链接相关操作 ====================
+
+// CreateShortURL 创建短链接，短码在同一域名内唯一
+func (m *MemoryStore) CreateShortURL(ctx context.Context, shortURL *model.ShortURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.urls {
+		if u.Domain == shortURL.Domain && u.Code == shortURL.Code {
+			return ErrDuplicate
+		}
+	}
+
+	now := m.now()
+	stamp(&shortURL.CreatedAt, now)
+	stamp(&shortURL.UpdatedAt, now)
+	u := *shortURL
+	u.Tags = sortedTags(u.Tags)
+	u.Variants = slices.Clone(u.Variants)
+	m.urls[shortURL.ID] = u
+	return nil
+}
+
+// GetShortURLByCode 通过域名 + 短码查询启用中的短链接
+func (m *MemoryStore) GetShortURLByCode(ctx context.Context, domain, code string) (*model.ShortURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.urls {
+		if u.Domain == domain && u.Code == code && u.IsActive {
+			u.Variants = slices.Clone(u.Variants)
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateShortURLs 批量创建短链接，短码已存在（包括同一批中重复）的记录跳过并返回其 ID
+func (m *MemoryStore) CreateShortURLs(ctx context.Context, urls []model.ShortURL) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	links := make(map[string]bool, len(m.urls)+len(urls))
+	for _, u := range m.urls {
+		links[u.LinkKey()] = true
+	}
+
+	now := m.now()
+	var conflicts []uuid.UUID
+	for _, u := range urls {
+		if links[u.LinkKey()] {
+			conflicts = append(conflicts, u.ID)
+			continue
+		}
+		links[u.LinkKey()] = true
+		stamp(&u.CreatedAt, now)
+		stamp(&u.UpdatedAt, now)
+		u.Tags = sortedTags(u.Tags)
+		u.Variants = slices.Clone(u.Variants)
+		m.urls[u.ID] = u
+	}
+	return conflicts, nil
+}
+
+// CodeExists 判断短码在该域名下是否已被使用（包括停用的短链接）
+func (m *MemoryStore) CodeExists(ctx context.Context, domain, code string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.urls {
+		if u.Domain == domain && u.Code == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetShortURLByID 按 ID 查询租户自己的短链接
+func (m *MemoryStore) GetShortURLByID(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.urls[id]
+	if !ok || u.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	u.Tags = slices.Clone(u.Tags)
+	u.Variants = slices.Clone(u.Variants)
+	return &u, nil
+}
+
+// UpdateShortURL 更新租户自己的短链接
+// updates 的 key 为数据库列名，与 Repository 保持一致
+func (m *MemoryStore) UpdateShortURL(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}, tags []string, variants []model.ShortURLVariant) (*model.ShortURL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.urls[id]
+	if !ok || u.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+
+	for column, value := range updates {
+		if err := applyURLUpdate(&u, column, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(updates) > 0 {
+		u.UpdatedAt = m.now()
+	}
+	if tags != nil {
+		u.Tags = sortedTags(tags)
+	}
+	if variants != nil {
+		u.Variants = slices.Clone(variants)
+	}
+	m.urls[id] = u
+	delete(m.qrCodes, u.LinkKey())
+	u.Tags = slices.Clone(u.Tags)
+	u.Variants = slices.Clone(u.Variants)
+	return &u, nil
+}
+
+// DeleteShortURL 删除租户自己的短链接及其点击事件
+func (m *MemoryStore) DeleteShortURL(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.urls[id]
+	if !ok || u.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	delete(m.urls, id)
+	delete(m.qrCodes, u.LinkKey())
+
+	kept := m.events[:0]
+	for _, e := range m.events {
+		if e.ShortURLID != id {
+			kept = append(kept, e)
+		}
+	}
+	m.events = kept
+	return &u, nil
+}
+
+// ListShortURLsByTenant 按租户查询短链接列表（筛选 + 排序 + 键集分页），语义与 Repository 一致
+func (m *MemoryStore) ListShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter, page pagination.Query) ([]model.ShortURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	desc := (filter.Order == model.OrderDesc) != page.Backward
+	now := m.now()
+	var urls []model.ShortURL
+	for _, u := range m.urls {
+		if u.TenantID != tenantID || !matchURLFilter(&u, filter, now) {
+			continue
+		}
+		if page.After != nil {
+			c := pagination.Compare(u.SortValue(filter.Sort), page.After.Value)
+			if c == 0 {
+				c = strings.Compare(u.ID.String(), page.After.ID.String())
+			}
+			if c == 0 || (c < 0) != desc {
+				continue
+			}
+		}
+		u.Tags = slices.Clone(u.Tags)
+		u.Variants = slices.Clone(u.Variants)
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		c := compareURLs(&urls[i], &urls[j], filter.Sort)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if len(urls) > page.Limit+1 {
+		urls = urls[:page.Limit+1]
+	}
+	return urls, nil
+}
+
+// CountFilteredShortURLs 统计满足列表筛选条件的短链接数
+func (m *MemoryStore) CountFilteredShortURLs(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	var total int64
+	for _, u := range m.urls {
+		if u.TenantID == tenantID && matchURLFilter(&u, filter, now) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// matchURLFilter 判断短链接是否满足列表筛选条件
+func matchURLFilter(u *model.ShortURL, f *model.URLListFilter, now time.Time) bool {
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(u.Code), q) &&
+			!strings.Contains(strings.ToLower(u.OriginalURL), q) &&
+			!strings.Contains(strings.ToLower(u.Title), q) {
+			return false
+		}
+	}
+	if f.Tag != "" && !slices.Contains(u.Tags, f.Tag) {
+		return false
+	}
+	if f.Folder != "" && u.Folder != f.Folder {
+		return false
+	}
+	if f.Active != nil && u.IsActive != *f.Active {
+		return false
+	}
+	if f.Expired != nil {
+		expired := u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+		if expired != *f.Expired {
+			return false
+		}
+	}
+	if f.CreatedAfter != nil && u.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !u.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// compareURLs 按排序字段比较两个短链接，相同时按 ID 比较保证顺序稳定
+func compareURLs(a, b *model.ShortURL, sortBy string) int {
+	var c int
+	switch sortBy {
+	case model.SortClicks:
+		c = cmp.Compare(a.Clicks, b.Clicks)
+	case model.SortCode:
+		c = strings.Compare(a.Code, b.Code)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID.String(), b.ID.String())
+	}
+	return c
+}
+
+// sortedTags 复制并按名称排序标签，与 Repository 返回的顺序一致
+func sortedTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return tags
+}
+
+// StreamShortURLsByTenant 按创建时间顺序分批遍历租户的全部短链接
+// 先在读锁内复制一份快照，回调期间不持有锁
+func (m *MemoryStore) StreamShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, batchSize int, fn func([]model.ShortURL) error) error {
+	m.mu.RLock()
+	var urls []model.ShortURL
+	for _, u := range m.urls {
+		if u.TenantID == tenantID {
+			urls = append(urls, u)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(urls, func(i, j int) bool {
+		if !urls[i].CreatedAt.Equal(urls[j].CreatedAt) {
+			return urls[i].CreatedAt.Before(urls[j].CreatedAt)
+		}
+		return urls[i].ID.String() < urls[j].ID.String()
+	})
+	return streamBatches(urls, batchSize, fn)
+}
+
+// CountURLsByTenant 统计租户的 URL 数量
+func (m *MemoryStore) CountURLsByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, u := range m.urls {
+		if u.TenantID == tenantID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountURLsByDomain 统计租户在某个域名下的短链接数量
+func (m *MemoryStore) CountURLsByDomain(ctx context.Context, tenantID uuid.UUID, domain string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, u := range m.urls {
+		if u.TenantID == tenantID && u.Domain == domain {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetClicks 查询短链接的点击次数
+func (m *MemoryStore) GetClicks(ctx context.Context, urlID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.urls[urlID].Clicks, nil
+}
+
+// ReserveClick 点击次数小于 limit 时计入一次点击，语义与 Repository 的 Lua 脚本一致
+func (m *MemoryStore) ReserveClick(ctx context.Context, urlID uuid.UUID, limit int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	w, ok := m.clickCount[urlID]
+	if !ok || !w.expiresAt.After(now) {
+		w.count = m.urls[urlID].Clicks
+	}
+	w.expiresAt = now.Add(clickCounterTTL)
+	reserved := w.count < limit
+	if reserved {
+		w.count++
+	}
+	m.clickCount[urlID] = w
+	return reserved, nil
+}
+
+// applyURLUpdate 按列名修改短链接字段
+func applyURLUpdate(u *model.ShortURL, column string, value interface{}) error {
+	switch column {
+	case "original_url":
+		u.OriginalURL = value.(string)
+	case "is_active":
+		u.IsActive = value.(bool)
+	case "max_clicks":
+		u.MaxClicks = value.(int64)
+	case "expires_at":
+		u.ExpiresAt = value.(*time.Time)
+	case "not_before":
+		u.NotBefore = value.(*time.Time)
+	case "title":
+		u.Title = value.(string)
+	case "folder":
+		u.Folder = value.(string)
+	case "password_hash":
+		u.PasswordHash = value.(string)
+	case "rules":
+		u.Rules = value.(model.RedirectRules)
+	case "redirect_status":
+		u.RedirectStatus = value.(int)
+	case "forward_query":
+		u.ForwardQuery = value.(bool)
+	case "forward_path":
+		u.ForwardPath = value.(bool)
+	case "utm":
+		u.UTM = value.(model.UTMParams)
+	default:
+		return fmt.Errorf("内存存储不支持更新列: %s", column)
+	}
+	return nil
+}
+
+// ==================== 点击事件相关操作 ====================
+
+// RecordClicks 批量写入点击事件并累加点击计数
+func (m *MemoryStore) RecordClicks(ctx context.Context, events []model.ClickEvent, batchSize int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 与 GORM 一样回填调用方切片中的 CreatedAt
+	now := m.now()
+	for i := range events {
+		stamp(&events[i].CreatedAt, now)
+		e := events[i]
+		m.events = append(m.events, e)
+		if u, ok := m.urls[e.ShortURLID]; ok {
+			if e.IsBot {
+				u.BotClicks++
+			} else {
+				u.Clicks++
+			}
+			m.urls[e.ShortURLID] = u
+		}
+	}
+	return nil
+}
+
+// GetClickSeries 按时间桶聚合单个短链接的点击数（只返回有点击的桶）
+func (m *MemoryStore) GetClickSeries(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, interval string, includeBots bool) ([]model.ClickBucket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[time.Time]int64)
+	for _, e := range m.clickEventsLocked(tenantID, urlID, from, to, includeBots) {
+		counts[model.TruncateToInterval(e.CreatedAt, interval)]++
+	}
+
+	buckets := make([]model.ClickBucket, 0, len(counts))
+	for t, n := range counts {
+		buckets = append(buckets, model.ClickBucket{Time: t, Clicks: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Time.Before(buckets[j].Time) })
+	return buckets, nil
+}
+
+// GetTopClickValues 统计单个短链接在时间范围内某一列的 Top N 取值
+func (m *MemoryStore) GetTopClickValues(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, column string, limit int, includeBots bool) ([]model.CountItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, e := range m.clickEventsLocked(tenantID, urlID, from, to, includeBots) {
+		switch column {
+		case "referer":
+			counts[e.Referer]++
+		case "user_agent":
+			counts[e.UserAgent]++
+		case "variant_id":
+			counts[e.VariantID]++
+		case "country":
+			counts[e.Country]++
+		case ClickLocationColumn:
+			if e.City != "" {
+				counts[e.Country+"/"+e.Region+"/"+e.City]++
+			}
+		default:
+			return nil, fmt.Errorf("内存存储不支持统计列: %s", column)
+		}
+	}
+	return topCounts(counts, limit), nil
+}
+
+// GetTenantStats 获取租户统计信息
+func (m *MemoryStore) GetTenantStats(ctx context.Context, tenantID uuid.UUID) (*model.StatsResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stats model.StatsResponse
+	for _, u := range m.urls {
+		if u.TenantID != tenantID {
+			continue
+		}
+		stats.TotalURLs++
+		stats.TotalClicks += u.Clicks
+		stats.BotClicks += u.BotClicks
+		if u.IsActive {
+			stats.ActiveURLs++
+		}
+	}
+	return &stats, nil
+}
+
+// CountClicksByTenantSince 统计租户自 since 以来的点击事件数
+func (m *MemoryStore) CountClicksByTenantSince(ctx context.Context, tenantID uuid.UUID, since time.Time) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, e := range m.events {
+		if e.TenantID == tenantID && !e.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// StreamClicksByTenant 按时间顺序分批遍历租户在 [from, to) 内的点击事件
+func (m *MemoryStore) StreamClicksByTenant(ctx context.Context, tenantID uuid.UUID, from, to time.Time, batchSize int, fn func([]model.ClickEvent) error) error {
+	m.mu.RLock()
+	var events []model.ClickEvent
+	for _, e := range m.events {
+		if e.TenantID == tenantID && !e.CreatedAt.Before(from) && e.CreatedAt.Before(to) {
+			events = append(events, e)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return streamBatches(events, batchSize, fn)
+}
+
+// streamBatches 将 items 按 batchSize 切分后依次交给 fn
+func streamBatches[T any](items []T, batchSize int, fn func([]T) error) error {
+	for start := 0; start < len(items); start += batchSize {
+		if err := fn(items[start:min(start+batchSize, len(items))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clickEventsLocked 返回租户某个短链接在 [from, to) 内的点击事件，调用方需持有读锁
+func (m *MemoryStore) clickEventsLocked(tenantID, urlID uuid.UUID, from, to time.Time, includeBots bool) []model.ClickEvent {
+	var events []model.ClickEvent
+	for _, e := range m.events {
+		if e.TenantID == tenantID && e.ShortURLID == urlID && (includeBots || !e.IsBot) &&
+			!e.CreatedAt.Before(from) && e.CreatedAt.Before(to) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// topCounts 按计数倒序取前 limit 项，计数相同时按取值排序保证结果稳定
+func topCounts(counts map[string]int64, limit int) []model.CountItem {
+	items := make([]model.CountItem, 0, len(counts))
+	for v, n := range counts {
+		items = append(items, model.CountItem{Value: v, Count: n})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Value < items[j].Value
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// ==================== 实时点击流 ====================
+
+// memoryClickStream 一个租户的回放队列和订阅者
+type memoryClickStream struct {
+	backlog []StreamedClick
+	subs    map[*memoryClickSubscription]struct{}
+}
+
+// PublishClicks 追加到回放队列并非阻塞地发送给订阅者（跟不上的订阅者丢弃消息，与 Redis 实现一致）
+// 回放队列按 maxLen 精确截断；ttl 在内存实现中忽略
+func (m *MemoryStore) PublishClicks(ctx context.Context, events []model.ClickEvent, maxLen int, ttl time.Duration) error {
+	m.streamMu.Lock()
+	defer m.streamMu.Unlock()
+
+	for _, e := range events {
+		ms := uint64(m.now().UnixMilli())
+		if ms <= m.lastStream {
+			ms = m.lastStream
+			m.streamSeq++
+		} else {
+			m.lastStream, m.streamSeq = ms, 0
+		}
+		click := StreamedClick{ID: fmt.Sprintf("%d-%d", ms, m.streamSeq), Event: e}
+
+		stream := m.clickStream(e.TenantID)
+		stream.backlog = append(stream.backlog, click)
+		if maxLen > 0 && len(stream.backlog) > maxLen {
+			stream.backlog = slices.Clone(stream.backlog[len(stream.backlog)-maxLen:])
+		}
+		for sub := range stream.subs {
+			select {
+			case sub.ch <- click:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// SubscribeClicks 订阅租户的实时点击
+func (m *MemoryStore) SubscribeClicks(ctx context.Context, tenantID uuid.UUID) (ClickSubscription, error) {
+	m.streamMu.Lock()
+	defer m.streamMu.Unlock()
+
+	sub := &memoryClickSubscription{store: m, tenantID: tenantID, ch: make(chan StreamedClick, clickSubscriptionBuffer)}
+	m.clickStream(tenantID).subs[sub] = struct{}{}
+	context.AfterFunc(ctx, func() { sub.Close() })
+	return sub, nil
+}
+
+// ClickBacklog 返回回放队列中 afterID 之后的点击
+func (m *MemoryStore) ClickBacklog(ctx context.Context, tenantID uuid.UUID, afterID string, limit int) ([]StreamedClick, error) {
+	m.streamMu.Lock()
+	defer m.streamMu.Unlock()
+
+	var clicks []StreamedClick
+	for _, click := range m.clickStream(tenantID).backlog {
+		if afterID != "" && !StreamIDAfter(click.ID, afterID) {
+			continue
+		}
+		if len(clicks) >= limit {
+			break
+		}
+		clicks = append(clicks, click)
+	}
+	return clicks, nil
+}
+
+// clickStream 返回租户的点击流，不存在时创建；调用方需持有 streamMu
+func (m *MemoryStore) clickStream(tenantID uuid.UUID) *memoryClickStream {
+	stream, ok := m.streams[tenantID]
+	if !ok {
+		stream = &memoryClickStream{subs: make(map[*memoryClickSubscription]struct{})}
+		m.streams[tenantID] = stream
+	}
+	return stream
+}
+
+type memoryClickSubscription struct {
+	store    *MemoryStore
+	tenantID uuid.UUID
+	ch       chan StreamedClick
+	once     sync.Once
+}
+
+func (s *memoryClickSubscription) C() <-chan StreamedClick {
+	return s.ch
+}
+
+func (s *memoryClickSubscription) Close() error {
+	s.once.Do(func() {
+		s.store.streamMu.Lock()
+		defer s.store.streamMu.Unlock()
+		delete(s.store.clickStream(s.tenantID).subs, s)
+		close(s.ch)
+	})
+	return nil
+}
+
+// ==================== 限流 / 健康检查 ====================
+
+// CheckRateLimit 滑动日志限流，语义与 Repository 的 Lua 脚本一致：被拒绝的请求不记录
+func (m *MemoryStore) CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (*RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	windowStart := now.Add(-RateLimitWindow)
+
+	hits := m.rateLimit[tenantID][:0]
+	for _, t := range m.rateLimit[tenantID] {
+		if t.After(windowStart) {
+			hits = append(hits, t)
+		}
+	}
+
+	allowed := len(hits) < limit
+	if allowed {
+		hits = append(hits, now)
+	}
+	m.rateLimit[tenantID] = hits
+
+	resetAt := now.Add(RateLimitWindow)
+	if len(hits) > 0 {
+		resetAt = hits[0].Add(RateLimitWindow)
+	}
+	return &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(limit-len(hits), 0),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+// failureWindow 一个 key 的失败计数及窗口结束时间
+type failureWindow struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// RecordFailure 记录一次失败并返回窗口内的失败次数，语义与 Repository 的 Lua 脚本一致
+func (m *MemoryStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	w, ok := m.failures[key]
+	if !ok || !w.expiresAt.After(now) {
+		w = failureWindow{expiresAt: now.Add(window)}
+	}
+	w.count++
+	m.failures[key] = w
+	return w.count, nil
+}
+
+// ResetFailures 清除失败计数
+func (m *MemoryStore) ResetFailures(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+// ==================== 二维码缓存 ====================
+
+// qrEntry 缓存的二维码图片及过期时间
+type qrEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// GetQRCode 读取缓存的二维码图片
+func (m *MemoryStore) GetQRCode(ctx context.Context, link, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.qrCodes[link][key]
+	if !ok || !e.expiresAt.After(m.now()) {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(e.data), nil
+}
+
+// SetQRCode 缓存二维码图片
+func (m *MemoryStore) SetQRCode(ctx context.Context, link, key string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.qrCodes[link] == nil {
+		m.qrCodes[link] = make(map[string]qrEntry)
+	}
+	m.qrCodes[link][key] = qrEntry{data: slices.Clone(data), expiresAt: m.now().Add(ttl)}
+	return nil
+}
+
+// HealthCheck 内存存储始终可用
+func (m *MemoryStore) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// stamp 模拟 GORM autoCreateTime / autoUpdateTime：零值时填充当前时间
+func stamp(t *time.Time, now time.Time) {
+	if t.IsZero() {
+		*t = now
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+func TestMemoryStoreRateLimit(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	store.now = func() time.Time { return now }
+
+	tenantID := uuid.New()
+	steps := []struct {
+		name          string
+		offset        time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantResetAt   time.Time
+	}{
+		{"第一次请求", 0, true, 1, start.Add(RateLimitWindow)},
+		{"第二次请求", 30 * time.Second, true, 0, start.Add(RateLimitWindow)},
+		{"超出配额", 40 * time.Second, false, 0, start.Add(RateLimitWindow)},
+		{"持续超出配额", 50 * time.Second, false, 0, start.Add(RateLimitWindow)},
+		// 被拒绝的请求不占用配额：第一次请求滑出窗口后立即恢复
+		{"最早请求滑出窗口", 61 * time.Second, true, 0, start.Add(30*time.Second + RateLimitWindow)},
+	}
+
+	for _, step := range steps {
+		now = start.Add(step.offset)
+		got, err := store.CheckRateLimit(context.Background(), tenantID, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got.Allowed != step.wantAllowed || got.Remaining != step.wantRemaining || !got.ResetAt.Equal(step.wantResetAt) {
+			t.Fatalf("%s: got %+v, want allowed=%v remaining=%d reset=%v",
+				step.name, got, step.wantAllowed, step.wantRemaining, step.wantResetAt)
+		}
+		if got.Allowed && got.RetryAfter(now) != 0 {
+			t.Errorf("%s: RetryAfter = %v, want 0", step.name, got.RetryAfter(now))
+		}
+	}
+
+	now = start.Add(70 * time.Second)
+	got, _ := store.CheckRateLimit(context.Background(), tenantID, 2)
+	if want := 20 * time.Second; got.Allowed || got.RetryAfter(now) != want {
+		t.Fatalf("RetryAfter = %v, want %v", got.RetryAfter(now), want)
+	}
+}
+
+func TestMemoryStoreReserveClick(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	u := model.ShortURL{ID: uuid.New(), TenantID: uuid.New(), Code: "c", OriginalURL: "https://example.com", IsActive: true, Clicks: 3}
+	if err := store.CreateShortURL(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	// 以数据库中的点击次数为初始值，达到上限后拒绝且不再增加
+	for i, want := range []bool{true, true, false, false} {
+		if got, _ := store.ReserveClick(ctx, u.ID, 5); got != want {
+			t.Fatalf("ReserveClick #%d = %v, want %v", i, got, want)
+		}
+	}
+	// 调高上限后可以继续计入
+	if got, _ := store.ReserveClick(ctx, u.ID, 6); !got {
+		t.Fatal("调高上限后 ReserveClick = false")
+	}
+
+	// 过期后重新以数据库为准
+	now = start.Add(clickCounterTTL + time.Second)
+	if got, _ := store.ReserveClick(ctx, u.ID, 4); !got {
+		t.Fatal("过期后 ReserveClick = false")
+	}
+	if got, _ := store.ReserveClick(ctx, u.ID, 4); got {
+		t.Fatal("过期后第二次 ReserveClick = true")
+	}
+}
+
+func TestMemoryStoreFailures(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := int64(1); i <= 3; i++ {
+		now = start.Add(time.Duration(i) * time.Minute)
+		if got, _ := store.RecordFailure(ctx, "k", 10*time.Minute); got != i {
+			t.Fatalf("RecordFailure = %d, want %d", got, i)
+		}
+	}
+	if got, _ := store.RecordFailure(ctx, "other", 10*time.Minute); got != 1 {
+		t.Fatalf("RecordFailure(other) = %d, want 1", got)
+	}
+
+	// 窗口从第一次失败开始计时，之后的失败不会延长窗口
+	now = start.Add(11*time.Minute + time.Second)
+	if got, _ := store.RecordFailure(ctx, "k", 10*time.Minute); got != 1 {
+		t.Fatalf("RecordFailure after window = %d, want 1", got)
+	}
+
+	// 清零后重新计数
+	if err := store.ResetFailures(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.RecordFailure(ctx, "k", 10*time.Minute); got != 1 {
+		t.Fatalf("RecordFailure after reset = %d, want 1", got)
+	}
+}
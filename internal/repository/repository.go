@@ -7,20 +7,27 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/pagination"
 )
 
-// Repository 数据访问接口
-// 使用接口便于测试（mock）和未来替换存储实现
+// Repository Store 接口的 PostgreSQL + Redis 实现
+// Service 依赖 Store 接口而不是这个具体类型，测试时可替换为 MemoryStore
 type Repository struct {
 	db     *gorm.DB
 	rdb    *redis.Client
@@ -36,48 +43,54 @@ func New(db *gorm.DB, rdb *redis.Client, logger *zap.Logger) *Repository {
 	}
 }
 
-// AutoMigrate 自动迁移数据库表结构
-// 生产环境中建议使用专门的迁移工具（如 golang-migrate）
-func (r *Repository) AutoMigrate() error {
-	return r.db.AutoMigrate(
-		&model.Tenant{},
-		&model.ShortURL{},
-		&model.ClickEvent{},
-	)
-}
-
 // ==================== 租户相关操作 ====================
 
-// CreateTenant 创建新租户
-func (r *Repository) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
-	return r.db.WithContext(ctx).Create(tenant).Error
+// CreateTenant 创建新租户，同时创建其第一个 API Key（同一事务）
+func (r *Repository) CreateTenant(ctx context.Context, tenant *model.Tenant, key *model.APIKey) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+// apiKeyCacheEntry 认证缓存内容：Key 信息 + 所属租户
+type apiKeyCacheEntry struct {
+	Key    model.APIKey `json:"key"`
+	Tenant model.Tenant `json:"tenant"`
 }
 
-// GetTenantByAPIKey 通过 API Key 查询租户
-// SaaS 认证核心：每个 API 请求都携带 API Key，系统据此识别租户
-func (r *Repository) GetTenantByAPIKey(ctx context.Context, apiKey string) (*model.Tenant, error) {
+// GetTenantByAPIKey 通过 API Key 哈希查询 Key 及其所属租户
+// SaaS 认证核心：每个 API 请求都携带 API Key，系统据此识别租户和权限范围
+// 已吊销的 Key 和停用的租户都查不到
+func (r *Repository) GetTenantByAPIKey(ctx context.Context, keyHash string) (*model.Tenant, *model.APIKey, error) {
 	// 先尝试从 Redis 缓存获取（热路径优化）
-	cacheKey := fmt.Sprintf("tenant:apikey:%s", apiKey)
+	cacheKey := apiKeyCacheKey(keyHash)
 	cached, err := r.rdb.Get(ctx, cacheKey).Result()
 	if err == nil {
-		var tenant model.Tenant
-		if err := json.Unmarshal([]byte(cached), &tenant); err == nil {
-			return &tenant, nil
+		var entry apiKeyCacheEntry
+		if err := json.Unmarshal([]byte(cached), &entry); err == nil {
+			return &entry.Tenant, &entry.Key, nil
 		}
 	}
 
 	// 缓存未命中，查数据库
+	var key model.APIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ? AND revoked = ?", keyHash, false).First(&key).Error; err != nil {
+		return nil, nil, err
+	}
 	var tenant model.Tenant
-	if err := r.db.WithContext(ctx).Where("api_key = ? AND is_active = ?", apiKey, true).First(&tenant).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Where("id = ? AND is_active = ?", key.TenantID, true).First(&tenant).Error; err != nil {
+		return nil, nil, err
 	}
 
-	// 写入缓存，TTL 5分钟
-	if data, err := json.Marshal(tenant); err == nil {
+	// 写入缓存，TTL 5分钟；吊销 Key 时会主动删除
+	if data, err := json.Marshal(apiKeyCacheEntry{Key: key, Tenant: tenant}); err == nil {
 		r.rdb.Set(ctx, cacheKey, data, 5*time.Minute)
 	}
 
-	return &tenant, nil
+	return &tenant, &key, nil
 }
 
 // GetTenantByID 通过 ID 查询租户
@@ -89,28 +102,694 @@ func (r *Repository) GetTenantByID(ctx context.Context, id uuid.UUID) (*model.Te
 	return &tenant, nil
 }
 
+// ChangeTenantPlan 切换租户套餐
+// 租户信息缓存在 API Key 认证缓存中，更新后删除该租户所有 Key 的缓存，新配额立即生效
+func (r *Repository) ChangeTenantPlan(ctx context.Context, tenantID uuid.UUID, plan *model.Plan) (*model.Tenant, error) {
+	var tenant model.Tenant
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tenant, "id = ?", tenantID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&tenant).Updates(map[string]interface{}{
+			"plan":       plan.Name,
+			"rate_limit": plan.RateLimit,
+			"max_urls":   plan.MaxURLs,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidateTenantCache(ctx, "tenant_id = ?", tenantID)
+	return &tenant, nil
+}
+
+// CountTenantsByPlan 统计使用指定套餐的租户数
+func (r *Repository) CountTenantsByPlan(ctx context.Context, plan string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Tenant{}).
+		Where("plan = ?", plan).
+		Count(&count).Error
+	return count, err
+}
+
+// invalidateTenantCache 删除满足条件的租户下所有 API Key 的认证缓存
+// 认证缓存以 Key 哈希为键、同时保存租户信息，租户配额变化后必须一并清除
+func (r *Repository) invalidateTenantCache(ctx context.Context, query string, args ...interface{}) {
+	var hashes []string
+	if err := r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("revoked = ?", false).
+		Where(query, args...).
+		Pluck("key_hash", &hashes).Error; err != nil {
+		r.logger.Error("查询租户 API Key 失败，租户缓存将在过期后刷新", zap.Error(err))
+		return
+	}
+	if len(hashes) == 0 {
+		return
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = apiKeyCacheKey(hash)
+	}
+	if err := r.rdb.Del(ctx, keys...).Err(); err != nil {
+		r.logger.Error("清除租户缓存失败", zap.Error(err))
+	}
+}
+
+// ==================== 套餐相关操作 ====================
+
+// CreatePlan 创建套餐
+func (r *Repository) CreatePlan(ctx context.Context, plan *model.Plan) error {
+	return r.db.WithContext(ctx).Create(plan).Error
+}
+
+// GetPlan 查询套餐
+// 创建短链接、查询分析时都需要套餐信息，缓存 10 分钟；修改/删除套餐时主动清除
+func (r *Repository) GetPlan(ctx context.Context, name string) (*model.Plan, error) {
+	cacheKey := planCacheKey(name)
+	cached, err := r.rdb.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var plan model.Plan
+		if err := json.Unmarshal([]byte(cached), &plan); err == nil {
+			return &plan, nil
+		}
+	}
+
+	var plan model.Plan
+	if err := r.db.WithContext(ctx).First(&plan, "name = ?", name).Error; err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(plan); err == nil {
+		r.rdb.Set(ctx, cacheKey, data, 10*time.Minute)
+	}
+	return &plan, nil
+}
+
+// ListPlans 查询所有套餐，按限流配额从低到高排列
+func (r *Repository) ListPlans(ctx context.Context) ([]model.Plan, error) {
+	var plans []model.Plan
+	err := r.db.WithContext(ctx).Order("rate_limit, name").Find(&plans).Error
+	return plans, err
+}
+
+// UpdatePlan 修改套餐，并在同一事务中同步该套餐下所有租户的冗余配额
+func (r *Repository) UpdatePlan(ctx context.Context, plan *model.Plan) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing model.Plan
+		if err := tx.First(&existing, "name = ?", plan.Name).Error; err != nil {
+			return err
+		}
+		plan.CreatedAt = existing.CreatedAt
+		if err := tx.Save(plan).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Tenant{}).
+			Where("plan = ?", plan.Name).
+			Updates(map[string]interface{}{
+				"rate_limit": plan.RateLimit,
+				"max_urls":   plan.MaxURLs,
+			}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidatePlanCache(ctx, plan.Name)
+	r.invalidateTenantCache(ctx, "tenant_id IN (?)",
+		r.db.WithContext(ctx).Model(&model.Tenant{}).Select("id").Where("plan = ?", plan.Name))
+	return nil
+}
+
+// DeletePlan 删除套餐
+func (r *Repository) DeletePlan(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Delete(&model.Plan{}, "name = ?", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	r.invalidatePlanCache(ctx, name)
+	return nil
+}
+
+func (r *Repository) invalidatePlanCache(ctx context.Context, name string) {
+	if err := r.rdb.Del(ctx, planCacheKey(name)).Err(); err != nil {
+		r.logger.Error("清除套餐缓存失败", zap.String("plan", name), zap.Error(err))
+	}
+}
+
+func planCacheKey(name string) string {
+	return fmt.Sprintf("plan:%s", name)
+}
+
+// ==================== API Key 相关操作 ====================
+
+// CreateAPIKey 创建 API Key
+func (r *Repository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// ListAPIKeysByTenant 查询租户的所有 API Key（含已吊销的）
+func (r *Repository) ListAPIKeysByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey 吊销租户的 API Key
+// 用 SELECT ... FOR UPDATE 锁住租户的所有 Key 后再检查是否为最后一个 admin Key，
+// 并发的吊销请求会在这里排队，不会同时通过检查。
+// 同时删除认证缓存，使 Key 立即失效，而不是等缓存 5 分钟后过期
+func (r *Repository) RevokeAPIKey(ctx context.Context, tenantID, id uuid.UUID) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var keys []model.APIKey
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenantID).
+			Find(&keys).Error; err != nil {
+			return err
+		}
+		found := false
+		for i := range keys {
+			if keys[i].ID == id {
+				key, found = keys[i], true
+				break
+			}
+		}
+		if !found {
+			return ErrNotFound
+		}
+		if isLastAdminKey(keys, id, time.Now()) {
+			return ErrLastAdminKey
+		}
+		return revokeKey(tx, &key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidateAPIKeyCache(ctx, key.KeyHash)
+	return &key, nil
+}
+
+// RotateAPIKey 轮换 API Key：吊销旧 Key 并创建继承其名称和权限的新 Key（同一事务）
+func (r *Repository) RotateAPIKey(ctx context.Context, tenantID, id uuid.UUID, newKey *model.APIKey) error {
+	var old model.APIKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ? AND revoked = ?", id, tenantID, false).First(&old).Error; err != nil {
+			return err
+		}
+		if err := revokeKey(tx, &old); err != nil {
+			return err
+		}
+		newKey.TenantID = old.TenantID
+		newKey.Name = old.Name
+		newKey.Scopes = old.Scopes
+		newKey.ExpiresAt = old.ExpiresAt
+		return tx.Create(newKey).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidateAPIKeyCache(ctx, old.KeyHash)
+	return nil
+}
+
+// TouchAPIKey 更新 API Key 的最近使用时间
+// 每个请求都写数据库代价太高，用 Redis SETNX 限制为每个 Key 每分钟最多更新一次
+func (r *Repository) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	ok, err := r.rdb.SetNX(ctx, fmt.Sprintf("apikey:touch:%s", id), 1, time.Minute).Result()
+	if err != nil || !ok {
+		return err
+	}
+	return r.db.WithContext(ctx).
+		Model(&model.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", time.Now()).Error
+}
+
+// revokeKey 在事务中将 Key 标记为已吊销
+func revokeKey(tx *gorm.DB, key *model.APIKey) error {
+	now := time.Now()
+	key.Revoked = true
+	key.RevokedAt = &now
+	return tx.Model(key).Updates(map[string]interface{}{
+		"revoked":    true,
+		"revoked_at": now,
+	}).Error
+}
+
+// invalidateAPIKeyCache 删除 API Key 的认证缓存
+func (r *Repository) invalidateAPIKeyCache(ctx context.Context, keyHash string) {
+	if err := r.rdb.Del(ctx, apiKeyCacheKey(keyHash)).Err(); err != nil {
+		r.logger.Error("清除 API Key 缓存失败", zap.Error(err))
+	}
+}
+
+func apiKeyCacheKey(keyHash string) string {
+	return fmt.Sprintf("tenant:apikey:%s", keyHash)
+}
+
+// ==================== 自定义域名 ====================
+
+// domainCacheTTL 重定向时域名解析结果的缓存时间，验证或删除域名时主动清除
+const domainCacheTTL = 5 * time.Minute
+
+// CreateDomain 添加租户的自定义域名
+func (r *Repository) CreateDomain(ctx context.Context, domain *model.Domain) error {
+	return r.db.WithContext(ctx).Create(domain).Error
+}
+
+// ListDomainsByTenant 按添加时间查询租户的全部域名
+func (r *Repository) ListDomainsByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Domain, error) {
+	var domains []model.Domain
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at ASC").
+		Find(&domains).Error
+	return domains, err
+}
+
+// CountDomainsByTenant 统计租户的域名数量（包括尚未验证的）
+func (r *Repository) CountDomainsByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Domain{}).
+		Where("tenant_id = ?", tenantID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetDomainByID 按 ID 查询租户自己的域名
+func (r *Repository) GetDomainByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Domain, error) {
+	var domain model.Domain
+	if err := r.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&domain).Error; err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// GetDomainByHostname 按域名查询租户自己的域名
+func (r *Repository) GetDomainByHostname(ctx context.Context, tenantID uuid.UUID, hostname string) (*model.Domain, error) {
+	var domain model.Domain
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND hostname = ?", tenantID, hostname).First(&domain).Error; err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// VerifyDomain 标记域名已通过验证
+// idx_domains_verified_hostname 保证同一域名只有一个租户能通过验证，冲突时返回 ErrDuplicate
+func (r *Repository) VerifyDomain(ctx context.Context, tenantID, id uuid.UUID, verifiedAt time.Time) (*model.Domain, error) {
+	var domain model.Domain
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&domain).Error; err != nil {
+			return err
+		}
+		domain.VerifiedAt = &verifiedAt
+		return tx.Model(&domain).Update("verified_at", verifiedAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidateDomainCache(ctx, domain.Hostname)
+	return &domain, nil
+}
+
+// DeleteDomain 删除租户自己的域名
+func (r *Repository) DeleteDomain(ctx context.Context, tenantID, id uuid.UUID) error {
+	var domain model.Domain
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&domain).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	r.invalidateDomainCache(ctx, domain.Hostname)
+	return nil
+}
+
+// IsVerifiedDomain 判断 hostname 是否为已验证的自定义域名
+// 重定向的每个请求都会调用，结果（包括否定结果）缓存 domainCacheTTL
+func (r *Repository) IsVerifiedDomain(ctx context.Context, hostname string) (bool, error) {
+	cacheKey := domainCacheKey(hostname)
+	if cached, err := r.rdb.Get(ctx, cacheKey).Result(); err == nil {
+		return cached == "1", nil
+	}
+
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&model.Domain{}).
+		Where("hostname = ? AND verified_at IS NOT NULL", hostname).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	value := "0"
+	if count > 0 {
+		value = "1"
+	}
+	r.rdb.Set(ctx, cacheKey, value, domainCacheTTL)
+	return count > 0, nil
+}
+
+func (r *Repository) invalidateDomainCache(ctx context.Context, hostname string) {
+	if err := r.rdb.Del(ctx, domainCacheKey(hostname)).Err(); err != nil {
+		r.logger.Error("清除域名缓存失败",
+			zap.String("hostname", hostname),
+			zap.Error(err),
+		)
+	}
+}
+
+func domainCacheKey(hostname string) string {
+	return fmt.Sprintf("domain:%s", hostname)
+}
+
+// ==================== Webhook ====================
+
+// CreateWebhook 创建 Webhook
+func (r *Repository) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+// ListWebhooksByTenant 按创建时间查询租户的全部 Webhook
+func (r *Repository) ListWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at ASC").
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+// CountWebhooksByTenant 统计租户的 Webhook 数量
+func (r *Repository) CountWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Webhook{}).
+		Where("tenant_id = ?", tenantID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetWebhookByID 按 ID 查询租户自己的 Webhook
+func (r *Repository) GetWebhookByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := r.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// UpdateWebhook 修改租户自己的 Webhook
+func (r *Repository) UpdateWebhook(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&webhook).Error; err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&webhook).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook 删除租户自己的 Webhook 及其投递记录
+func (r *Repository) DeleteWebhook(ctx context.Context, tenantID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var webhook model.Webhook
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&webhook).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+}
+
+// ListSubscribedWebhooks 查询这些租户中订阅了 event 的启用中的 Webhook
+// 每个租户的 Webhook 数量有上限，订阅关系在内存中过滤
+func (r *Repository) ListSubscribedWebhooks(ctx context.Context, tenantIDs []uuid.UUID, event string) ([]model.Webhook, error) {
+	if len(tenantIDs) == 0 {
+		return nil, nil
+	}
+	var webhooks []model.Webhook
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id IN ? AND is_active = ?", tenantIDs, true).
+		Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	subscribed := webhooks[:0]
+	for _, w := range webhooks {
+		if w.Subscribes(event) {
+			subscribed = append(subscribed, w)
+		}
+	}
+	return subscribed, nil
+}
+
+// GetWebhooks 按 ID 批量查询 Webhook
+func (r *Repository) GetWebhooks(ctx context.Context, ids []uuid.UUID) ([]model.Webhook, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var webhooks []model.Webhook
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// CreateWebhookDeliveries 批量写入投递记录
+func (r *Repository) CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(deliveries, 500).Error
+}
+
+// ClaimWebhookDeliveries 领取到期的 pending 投递
+// FOR UPDATE SKIP LOCKED 使多个副本并发领取时互不阻塞、不会重复领取
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = ? AND d.next_attempt_at <= ? AND w.is_active
+			ORDER BY d.next_attempt_at
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(lease), now, model.DeliveryPending, now, limit,
+	).Scan(&deliveries).Error
+	return deliveries, err
+}
+
+// SaveWebhookAttempt 保存一次投递的结果
+func (r *Repository) SaveWebhookAttempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).
+		Model(&model.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"response_status": delivery.ResponseStatus,
+			"last_error":      delivery.LastError,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+}
+
+// ListWebhookDeliveries 按创建时间倒序分页查询 Webhook 的投递记录
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, tenantID, webhookID uuid.UUID, status string, page pagination.Query) ([]model.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).Where("tenant_id = ? AND webhook_id = ?", tenantID, webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 默认倒序，上一页时反向扫描
+	if page.After != nil {
+		op := "<"
+		if page.Backward {
+			op = ">"
+		}
+		query = query.Where(fmt.Sprintf("(created_at, id) %s (?, ?)", op), page.After.Value, page.After.ID)
+	}
+	order := "created_at DESC, id DESC"
+	if page.Backward {
+		order = "created_at, id"
+	}
+
+	var deliveries []model.WebhookDelivery
+	err := query.Order(order).Limit(page.Limit + 1).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// RedeliverWebhookDelivery 把投递重新放回队列
+func (r *Repository) RedeliverWebhookDelivery(ctx context.Context, tenantID, webhookID, id uuid.UUID, now time.Time) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ? AND webhook_id = ?", id, tenantID, webhookID).First(&delivery).Error; err != nil {
+			return err
+		}
+		delivery.Status = model.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = now
+		delivery.DeliveredAt = nil
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"delivered_at":    nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// PurgeWebhookDeliveries 删除过期的已投递 / 已放弃记录
+func (r *Repository) PurgeWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("status IN ? AND created_at < ?", []string{model.DeliveryDelivered, model.DeliveryDead}, before).
+		Delete(&model.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
 // ==================== 短链接相关操作 ====================
 
 // CreateShortURL 创建短链接
 // 注意：所有数据操作都绑定 TenantID，这是 SaaS 多租户隔离的核心
 func (r *Repository) CreateShortURL(ctx context.Context, shortURL *model.ShortURL) error {
-	if err := r.db.WithContext(ctx).Create(shortURL).Error; err != nil {
-		return err
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(shortURL).Error; err != nil {
+			return err
+		}
+		if err := replaceURLVariants(tx, shortURL.ID, shortURL.Variants); err != nil {
+			return err
+		}
+		if len(shortURL.Tags) == 0 {
+			return nil
+		}
+		return replaceURLTags(tx, shortURL.TenantID, map[uuid.UUID][]string{shortURL.ID: shortURL.Tags})
+	})
+	return err
+}
+
+// CreateShortURLs 批量创建短链接（同一事务）
+// 使用 INSERT ... ON CONFLICT (domain, code) DO NOTHING，短码冲突的记录被跳过而不是让整个事务失败，
+// 事务内再按 ID 查出实际写入的记录，其余即为冲突；缓存写入合并为一次 Redis Pipeline
+func (r *Repository) CreateShortURLs(ctx context.Context, urls []model.ShortURL) ([]uuid.UUID, error) {
+	if len(urls) == 0 {
+		return nil, nil
 	}
 
-	// 同时写入 Redis 缓存（短码 -> 原始URL 的映射）
-	// 这是短链接服务的热路径，必须快速响应
-	cacheKey := fmt.Sprintf("url:%s", shortURL.Code)
-	r.rdb.Set(ctx, cacheKey, shortURL.OriginalURL, 24*time.Hour)
+	ids := make([]uuid.UUID, len(urls))
+	for i := range urls {
+		ids[i] = urls[i].ID
+	}
 
-	return nil
+	var inserted []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "domain"}, {Name: "code"}},
+			DoNothing: true,
+		}).Create(&urls).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ShortURL{}).Where("id IN ?", ids).Pluck("id", &inserted).Error; err != nil {
+			return err
+		}
+
+		// 只为实际写入的记录保存标签和 A/B 测试目标
+		ok := make(map[uuid.UUID]bool, len(inserted))
+		for _, id := range inserted {
+			ok[id] = true
+		}
+		tagsByTenant := make(map[uuid.UUID]map[uuid.UUID][]string)
+		for i := range urls {
+			if !ok[urls[i].ID] {
+				continue
+			}
+			if err := replaceURLVariants(tx, urls[i].ID, urls[i].Variants); err != nil {
+				return err
+			}
+			if len(urls[i].Tags) == 0 {
+				continue
+			}
+			if tagsByTenant[urls[i].TenantID] == nil {
+				tagsByTenant[urls[i].TenantID] = make(map[uuid.UUID][]string)
+			}
+			tagsByTenant[urls[i].TenantID][urls[i].ID] = urls[i].Tags
+		}
+		for tenantID, urlTags := range tagsByTenant {
+			if err := replaceURLTags(tx, tenantID, urlTags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ok := make(map[uuid.UUID]bool, len(inserted))
+	for _, id := range inserted {
+		ok[id] = true
+	}
+
+	var conflicts []uuid.UUID
+	pipe := r.rdb.Pipeline()
+	for i := range urls {
+		if !ok[urls[i].ID] {
+			conflicts = append(conflicts, urls[i].ID)
+			continue
+		}
+		// 预热重定向使用的 url:detail:<link> 缓存，与 GetShortURLByCode 回填的内容和过期时间一致
+		data, err := json.Marshal(urls[i])
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, fmt.Sprintf("url:detail:%s", urls[i].LinkKey()), data, 1*time.Hour)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		// 缓存写入失败不影响结果，重定向时会回源数据库
+		r.logger.Warn("批量写入短链接缓存失败", zap.Error(err))
+	}
+
+	return conflicts, nil
 }
 
-// GetShortURLByCode 通过短码查询（重定向时使用）
+// GetShortURLByCode 通过域名 + 短码查询（重定向时使用）
 // 这是访问量最大的接口，优先走缓存
-func (r *Repository) GetShortURLByCode(ctx context.Context, code string) (*model.ShortURL, error) {
+func (r *Repository) GetShortURLByCode(ctx context.Context, domain, code string) (*model.ShortURL, error) {
 	// 先查 Redis
-	cacheKey := fmt.Sprintf("url:detail:%s", code)
+	cacheKey := fmt.Sprintf("url:detail:%s", model.LinkKey(domain, code))
 	cached, err := r.rdb.Get(ctx, cacheKey).Result()
 	if err == nil {
 		var shortURL model.ShortURL
@@ -121,7 +800,10 @@ func (r *Repository) GetShortURLByCode(ctx context.Context, code string) (*model
 
 	// 缓存未命中，查数据库
 	var shortURL model.ShortURL
-	if err := r.db.WithContext(ctx).Where("code = ? AND is_active = ?", code, true).First(&shortURL).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("domain = ? AND code = ? AND is_active = ?", domain, code, true).First(&shortURL).Error; err != nil {
+		return nil, err
+	}
+	if err := loadURLVariants(r.db.WithContext(ctx), []*model.ShortURL{&shortURL}); err != nil {
 		return nil, err
 	}
 
@@ -133,62 +815,506 @@ func (r *Repository) GetShortURLByCode(ctx context.Context, code string) (*model
 	return &shortURL, nil
 }
 
-// GetOriginalURL 快速获取原始 URL（仅用于重定向）
-func (r *Repository) GetOriginalURL(ctx context.Context, code string) (string, error) {
-	// 先查 Redis（最快路径）
-	cacheKey := fmt.Sprintf("url:%s", code)
-	url, err := r.rdb.Get(ctx, cacheKey).Result()
-	if err == nil {
-		return url, nil
-	}
+// CodeExists 判断短码在该域名下是否已被使用（包括停用的短链接）
+func (r *Repository) CodeExists(ctx context.Context, domain, code string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ShortURL{}).
+		Where("domain = ? AND code = ?", domain, code).
+		Count(&count).Error
+	return count > 0, err
+}
 
-	// 缓存未命中，查数据库
+// GetShortURLByID 按 ID 查询租户自己的短链接（管理接口使用，不走缓存）
+// SaaS 关键：WHERE tenant_id = ? 防止越权访问其他租户的数据
+func (r *Repository) GetShortURLByID(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error) {
 	var shortURL model.ShortURL
 	if err := r.db.WithContext(ctx).
-		Select("original_url").
-		Where("code = ? AND is_active = ?", code, true).
+		Where("id = ? AND tenant_id = ?", id, tenantID).
 		First(&shortURL).Error; err != nil {
-		return "", err
+		return nil, err
+	}
+	if err := loadURLTags(r.db.WithContext(ctx), []*model.ShortURL{&shortURL}); err != nil {
+		return nil, err
+	}
+	if err := loadURLVariants(r.db.WithContext(ctx), []*model.ShortURL{&shortURL}); err != nil {
+		return nil, err
+	}
+	return &shortURL, nil
+}
+
+// UpdateShortURL 更新租户自己的短链接
+// updates 为需要修改的列，tags / variants 不为 nil 时整体替换标签 / A/B 测试目标；
+// 更新成功后清除该短码的缓存，避免重定向命中旧数据
+func (r *Repository) UpdateShortURL(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}, tags []string, variants []model.ShortURLVariant) (*model.ShortURL, error) {
+	var shortURL model.ShortURL
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&shortURL).Error; err != nil {
+			return err
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&shortURL).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if tags != nil {
+			if err := replaceURLTags(tx, tenantID, map[uuid.UUID][]string{id: tags}); err != nil {
+				return err
+			}
+		}
+		if variants != nil {
+			if err := tx.Where("short_url_id = ?", id).Delete(&model.ShortURLVariant{}).Error; err != nil {
+				return err
+			}
+			if err := replaceURLVariants(tx, id, variants); err != nil {
+				return err
+			}
+		}
+		if err := loadURLVariants(tx, []*model.ShortURL{&shortURL}); err != nil {
+			return err
+		}
+		return loadURLTags(tx, []*model.ShortURL{&shortURL})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.InvalidateURLCache(ctx, shortURL.LinkKey())
+	return &shortURL, nil
+}
+
+// DeleteShortURL 删除租户自己的短链接及其点击事件
+// 删除后立即清除缓存，否则重定向会继续命中缓存最长 24 小时
+func (r *Repository) DeleteShortURL(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error) {
+	var shortURL model.ShortURL
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND tenant_id = ?", id, tenantID).First(&shortURL).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("short_url_id = ? AND tenant_id = ?", id, tenantID).Delete(&model.ClickEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("short_url_id = ?", id).Delete(&model.ShortURLTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("short_url_id = ?", id).Delete(&model.ShortURLVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&shortURL).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 回填缓存
-	r.rdb.Set(ctx, cacheKey, shortURL.OriginalURL, 24*time.Hour)
+	r.InvalidateURLCache(ctx, shortURL.LinkKey())
+	return &shortURL, nil
+}
 
-	return shortURL.OriginalURL, nil
+// InvalidateURLCache 清除短链接相关的所有缓存，link 为 ShortURL.LinkKey
+// 包括 GetShortURLByCode 使用的 url:detail:<link> 和二维码缓存 qr:<link>
+// 旧版本写入的 url:<link>（只保存目标地址，24 小时过期）已不再读取，但滚动发布期间仍可能被旧副本使用，继续删除
+func (r *Repository) InvalidateURLCache(ctx context.Context, link string) {
+	keys := []string{
+		fmt.Sprintf("url:%s", link),
+		fmt.Sprintf("url:detail:%s", link),
+		qrCacheKey(link),
+	}
+	if err := r.rdb.Del(ctx, keys...).Err(); err != nil {
+		r.logger.Error("清除短链接缓存失败",
+			zap.String("link", link),
+			zap.Error(err),
+		)
+	}
 }
 
-// ListShortURLsByTenant 按租户查询短链接列表（分页）
+// ListShortURLsByTenant 按租户查询短链接列表（筛选 + 排序 + 键集分页）
 // SaaS 关键：WHERE tenant_id = ? 确保租户只能看到自己的数据
-func (r *Repository) ListShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, offset, limit int) ([]model.ShortURL, int64, error) {
+// 按 (排序列, id) 的行值比较定位到游标之后，最多返回 page.Limit+1 条，page.Backward 时为反向顺序
+func (r *Repository) ListShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter, page pagination.Query) ([]model.ShortURL, error) {
 	var urls []model.ShortURL
+
+	query := applyURLFilter(r.db.WithContext(ctx).Model(&model.ShortURL{}).Where("tenant_id = ?", tenantID), tenantID, filter)
+
+	// 上一页时反向扫描，Service 负责把结果恢复为正常顺序
+	column := urlSortColumn(filter.Sort)
+	desc := (filter.Order == model.OrderDesc) != page.Backward
+	if page.After != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, op), page.After.Value, page.After.ID)
+	}
+
+	// id 作为第二排序键保证顺序稳定
+	order := column + ", id"
+	if desc {
+		order = column + " DESC, id DESC"
+	}
+	if err := query.Order(order).Limit(page.Limit + 1).Find(&urls).Error; err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*model.ShortURL, len(urls))
+	for i := range urls {
+		ptrs[i] = &urls[i]
+	}
+	if err := loadURLTags(r.db.WithContext(ctx), ptrs); err != nil {
+		return nil, err
+	}
+	if err := loadURLVariants(r.db.WithContext(ctx), ptrs); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// CountFilteredShortURLs 统计满足列表筛选条件的短链接数
+func (r *Repository) CountFilteredShortURLs(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter) (int64, error) {
 	var total int64
+	query := applyURLFilter(r.db.WithContext(ctx).Model(&model.ShortURL{}).Where("tenant_id = ?", tenantID), tenantID, filter)
+	err := query.Count(&total).Error
+	return total, err
+}
 
-	query := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+// StreamShortURLsByTenant 通过服务端游标导出租户的全部短链接
+// 每批数据在同一快照中补充标签
+func (r *Repository) StreamShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, batchSize int, fn func([]model.ShortURL) error) error {
+	var tx *gorm.DB
+	return streamCursor(ctx, r.db, func(cursorTx *gorm.DB) *gorm.DB {
+		tx = cursorTx
+		return tx.Model(&model.ShortURL{}).
+			Where("tenant_id = ?", tenantID).
+			Order("created_at, id")
+	}, batchSize, func(batch []model.ShortURL) error {
+		ptrs := make([]*model.ShortURL, len(batch))
+		for i := range batch {
+			ptrs[i] = &batch[i]
+		}
+		if err := loadURLTags(tx, ptrs); err != nil {
+			return err
+		}
+		if err := loadURLVariants(tx, ptrs); err != nil {
+			return err
+		}
+		return fn(batch)
+	})
+}
 
-	// 先查总数
-	if err := query.Model(&model.ShortURL{}).Count(&total).Error; err != nil {
-		return nil, 0, err
+// applyURLFilter 在 query 上追加列表筛选条件
+func applyURLFilter(query *gorm.DB, tenantID uuid.UUID, f *model.URLListFilter) *gorm.DB {
+	if f.Query != "" {
+		pattern := "%" + escapeLike(f.Query) + "%"
+		query = query.Where("(code ILIKE ? OR original_url ILIKE ? OR title ILIKE ?)", pattern, pattern, pattern)
 	}
+	if f.Tag != "" {
+		query = query.Where("id IN (?)", query.Session(&gorm.Session{NewDB: true}).
+			Table("short_url_tags").
+			Select("short_url_tags.short_url_id").
+			Joins("JOIN tags ON tags.id = short_url_tags.tag_id").
+			Where("tags.tenant_id = ? AND tags.name = ?", tenantID, f.Tag))
+	}
+	if f.Folder != "" {
+		query = query.Where("folder = ?", f.Folder)
+	}
+	if f.Active != nil {
+		query = query.Where("is_active = ?", *f.Active)
+	}
+	if f.Expired != nil {
+		if *f.Expired {
+			query = query.Where("expires_at IS NOT NULL AND expires_at <= NOW()")
+		} else {
+			query = query.Where("(expires_at IS NULL OR expires_at > NOW())")
+		}
+	}
+	if f.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *f.CreatedBefore)
+	}
+	return query
+}
 
-	// 分页查询
-	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&urls).Error; err != nil {
-		return nil, 0, err
+// urlSortColumn 排序字段白名单，未知字段按创建时间排序
+func urlSortColumn(sort string) string {
+	switch sort {
+	case model.SortClicks:
+		return "clicks"
+	case model.SortCode:
+		return "code"
+	default:
+		return "created_at"
 	}
+}
 
-	return urls, total, nil
+// escapeLike 转义 LIKE 模式中的通配符，使用户输入按字面匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
-// IncrementClicks 增加点击次数
-func (r *Repository) IncrementClicks(ctx context.Context, urlID uuid.UUID) error {
-	return r.db.WithContext(ctx).
+// replaceURLTags 用给定的标签替换短链接的标签
+// 先确保标签存在（INSERT ... ON CONFLICT DO NOTHING），再重建关联；不再使用的标签保留，供后续复用
+func replaceURLTags(tx *gorm.DB, tenantID uuid.UUID, urlTags map[uuid.UUID][]string) error {
+	if len(urlTags) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var tags []model.Tag
+	var names []string
+	urlIDs := make([]uuid.UUID, 0, len(urlTags))
+	for id, list := range urlTags {
+		urlIDs = append(urlIDs, id)
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+				tags = append(tags, model.Tag{ID: uuid.New(), TenantID: tenantID, Name: name})
+			}
+		}
+	}
+
+	if err := tx.Where("short_url_id IN ?", urlIDs).Delete(&model.ShortURLTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}},
+		DoNothing: true,
+	}).Create(&tags).Error; err != nil {
+		return err
+	}
+	var existing []model.Tag
+	if err := tx.Where("tenant_id = ? AND name IN ?", tenantID, names).Find(&existing).Error; err != nil {
+		return err
+	}
+	tagIDs := make(map[string]uuid.UUID, len(existing))
+	for _, t := range existing {
+		tagIDs[t.Name] = t.ID
+	}
+
+	var links []model.ShortURLTag
+	for id, list := range urlTags {
+		for _, name := range list {
+			links = append(links, model.ShortURLTag{ShortURLID: id, TagID: tagIDs[name]})
+		}
+	}
+	return tx.Create(&links).Error
+}
+
+// loadURLTags 一次查询补充多个短链接的标签（按名称排序）
+func loadURLTags(db *gorm.DB, urls []*model.ShortURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(urls))
+	for i, u := range urls {
+		ids[i] = u.ID
+	}
+
+	var rows []struct {
+		ShortURLID uuid.UUID
+		Name       string
+	}
+	if err := db.Table("short_url_tags").
+		Select("short_url_tags.short_url_id, tags.name").
+		Joins("JOIN tags ON tags.id = short_url_tags.tag_id").
+		Where("short_url_tags.short_url_id IN ?", ids).
+		Order("tags.name").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	byURL := make(map[uuid.UUID][]string, len(urls))
+	for _, row := range rows {
+		byURL[row.ShortURLID] = append(byURL[row.ShortURLID], row.Name)
+	}
+	for _, u := range urls {
+		u.Tags = byURL[u.ID]
+	}
+	return nil
+}
+
+// replaceURLVariants 写入短链接的 A/B 测试目标（调用方负责先删除旧目标），按列表顺序记录 Position
+func replaceURLVariants(tx *gorm.DB, urlID uuid.UUID, variants []model.ShortURLVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	rows := make([]model.ShortURLVariant, len(variants))
+	for i, v := range variants {
+		v.ShortURLID = urlID
+		v.Position = i
+		rows[i] = v
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadURLVariants 一次查询补充多个短链接的 A/B 测试目标
+func loadURLVariants(db *gorm.DB, urls []*model.ShortURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(urls))
+	for i, u := range urls {
+		ids[i] = u.ID
+	}
+
+	var rows []model.ShortURLVariant
+	if err := db.Where("short_url_id IN ?", ids).Order("short_url_id, position").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	byURL := make(map[uuid.UUID][]model.ShortURLVariant, len(urls))
+	for _, row := range rows {
+		byURL[row.ShortURLID] = append(byURL[row.ShortURLID], row)
+	}
+	for _, u := range urls {
+		u.Variants = byURL[u.ID]
+	}
+	return nil
+}
+
+// GetClicks 查询短链接的实时点击次数（不走缓存，用于点击上限检查）
+func (r *Repository) GetClicks(ctx context.Context, urlID uuid.UUID) (int64, error) {
+	var clicks int64
+	err := r.db.WithContext(ctx).
 		Model(&model.ShortURL{}).
 		Where("id = ?", urlID).
-		UpdateColumn("clicks", gorm.Expr("clicks + 1")).Error
+		Select("clicks").
+		Scan(&clicks).Error
+	return clicks, err
 }
 
-// CreateClickEvent 记录点击事件
-func (r *Repository) CreateClickEvent(ctx context.Context, event *model.ClickEvent) error {
-	return r.db.WithContext(ctx).Create(event).Error
+// clickCounterTTL 点击计数的过期时间，每次点击刷新
+// 点击由 ClickPipeline 异步写入数据库，计数过期时数据库早已追上，重新以数据库为准即可纠正偏差（如队列满时丢弃的点击）
+const clickCounterTTL = time.Hour
+
+// clickCounterScript 点击计数小于上限时加一，并刷新过期时间；达到上限时不再增加，之后调高上限可以继续使用
+// KEYS[1] 计数 key  ARGV[1] 上限  ARGV[2] 过期时间（毫秒）  ARGV[3] 计数不存在时的初始值，为空时不初始化
+// 返回 1 已计入 / 0 已达上限 / -1 计数不存在
+var clickCounterScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	if ARGV[3] == '' then
+		return -1
+	end
+	redis.call('SET', KEYS[1], ARGV[3])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if tonumber(redis.call('GET', KEYS[1])) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('INCR', KEYS[1])
+return 1
+`)
+
+// ReserveClick 点击次数小于 limit 时计入一次点击
+// 计数不存在时才查询数据库，多个副本同时初始化时由脚本保证只设置一次
+func (r *Repository) ReserveClick(ctx context.Context, urlID uuid.UUID, limit int64) (bool, error) {
+	key := fmt.Sprintf("url:clicks:%s", urlID)
+	res, err := clickCounterScript.Run(ctx, r.rdb, []string{key}, limit, clickCounterTTL.Milliseconds(), "").Int64()
+	if err != nil || res >= 0 {
+		return res == 1, err
+	}
+
+	clicks, err := r.GetClicks(ctx, urlID)
+	if err != nil {
+		return false, err
+	}
+	res, err = clickCounterScript.Run(ctx, r.rdb, []string{key}, limit, clickCounterTTL.Milliseconds(), clicks).Int64()
+	return res == 1, err
+}
+
+// RecordClicks 批量写入点击事件并累加点击计数（同一事务）
+// 点击事件使用多行 INSERT 分批写入；点击计数先在内存中按短链接合并，
+// 每个短链接只执行一次 UPDATE，避免热门链接的同一行被频繁更新导致行锁竞争
+// 机器人点击累加到 bot_clicks，不计入 clicks（也不占用点击上限）
+func (r *Repository) RecordClicks(ctx context.Context, events []model.ClickEvent, batchSize int) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	type increment struct{ clicks, bots int64 }
+	increments := make(map[uuid.UUID]*increment)
+	for i := range events {
+		inc := increments[events[i].ShortURLID]
+		if inc == nil {
+			inc = &increment{}
+			increments[events[i].ShortURLID] = inc
+		}
+		if events[i].IsBot {
+			inc.bots++
+		} else {
+			inc.clicks++
+		}
+	}
+	// 按 ID 排序后更新，多个 worker 并发刷新时加锁顺序一致，避免死锁
+	ids := make([]uuid.UUID, 0, len(increments))
+	for id := range increments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(events, batchSize).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := tx.Model(&model.ShortURL{}).
+				Where("id = ?", id).
+				UpdateColumns(map[string]any{
+					"clicks":     gorm.Expr("clicks + ?", increments[id].clicks),
+					"bot_clicks": gorm.Expr("bot_clicks + ?", increments[id].bots),
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetClickSeries 按时间桶聚合单个短链接的点击数
+// 使用 PostgreSQL date_trunc 在数据库端聚合，只返回有点击的桶；
+// 统一按 UTC 截断，保证与应用层补零的桶边界一致
+func (r *Repository) GetClickSeries(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, interval string, includeBots bool) ([]model.ClickBucket, error) {
+	var buckets []model.ClickBucket
+	err := clickRange(r.db.WithContext(ctx), tenantID, urlID, from, to, includeBots).
+		Select("date_trunc(?, created_at AT TIME ZONE 'UTC') AS time, COUNT(*) AS clicks", interval).
+		Group("1").
+		Order("1").
+		Scan(&buckets).Error
+	return buckets, err
+}
+
+// GetTopClickValues 统计单个短链接在时间范围内某一列的 Top N 取值
+// column 只能由调用方传入固定列名（referer / user_agent / country 等），不能来自用户输入
+func (r *Repository) GetTopClickValues(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, column string, limit int, includeBots bool) ([]model.CountItem, error) {
+	var items []model.CountItem
+	query := clickRange(r.db.WithContext(ctx), tenantID, urlID, from, to, includeBots)
+	if column == ClickLocationColumn {
+		query = query.Where("city <> ''")
+		column = "country || '/' || region || '/' || city"
+	}
+	err := query.
+		Select(fmt.Sprintf("COALESCE(%s, '') AS value, COUNT(*) AS count", column)).
+		Group("1").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// clickRange 单个短链接在 [from, to) 内的点击事件查询条件
+func clickRange(db *gorm.DB, tenantID, urlID uuid.UUID, from, to time.Time, includeBots bool) *gorm.DB {
+	db = db.Model(&model.ClickEvent{}).
+		Where("tenant_id = ? AND short_url_id = ?", tenantID, urlID).
+		Where("created_at >= ? AND created_at < ?", from, to)
+	if !includeBots {
+		db = db.Where("is_bot = ?", false)
+	}
+	return db
 }
 
 // CountURLsByTenant 统计租户的 URL 数量（用于配额检查）
@@ -201,6 +1327,16 @@ func (r *Repository) CountURLsByTenant(ctx context.Context, tenantID uuid.UUID)
 	return count, err
 }
 
+// CountURLsByDomain 统计租户在某个域名下的短链接数量
+func (r *Repository) CountURLsByDomain(ctx context.Context, tenantID uuid.UUID, domain string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ShortURL{}).
+		Where("tenant_id = ? AND domain = ?", tenantID, domain).
+		Count(&count).Error
+	return count, err
+}
+
 // GetTenantStats 获取租户统计信息
 func (r *Repository) GetTenantStats(ctx context.Context, tenantID uuid.UUID) (*model.StatsResponse, error) {
 	var stats model.StatsResponse
@@ -215,45 +1351,315 @@ func (r *Repository) GetTenantStats(ctx context.Context, tenantID uuid.UUID) (*m
 		Where("tenant_id = ? AND is_active = ?", tenantID, true).
 		Count(&stats.ActiveURLs)
 
-	// 总点击数
+	// 总点击数（机器人点击单独统计）
+	var clicks struct{ Clicks, BotClicks int64 }
 	r.db.WithContext(ctx).Model(&model.ShortURL{}).
 		Where("tenant_id = ?", tenantID).
-		Select("COALESCE(SUM(clicks), 0)").
-		Scan(&stats.TotalClicks)
+		Select("COALESCE(SUM(clicks), 0) AS clicks, COALESCE(SUM(bot_clicks), 0) AS bot_clicks").
+		Scan(&clicks)
+	stats.TotalClicks, stats.BotClicks = clicks.Clicks, clicks.BotClicks
 
 	return &stats, nil
 }
 
+// CountClicksByTenantSince 统计租户自 since 以来的点击事件数（用于每月点击配额）
+func (r *Repository) CountClicksByTenantSince(ctx context.Context, tenantID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ClickEvent{}).
+		Where("tenant_id = ? AND created_at >= ?", tenantID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// StreamClicksByTenant 通过服务端游标导出租户在 [from, to) 内的点击事件
+// 使用 (tenant_id, created_at) 联合索引按时间顺序扫描
+func (r *Repository) StreamClicksByTenant(ctx context.Context, tenantID uuid.UUID, from, to time.Time, batchSize int, fn func([]model.ClickEvent) error) error {
+	return streamCursor(ctx, r.db, func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&model.ClickEvent{}).
+			Where("tenant_id = ?", tenantID).
+			Where("created_at >= ? AND created_at < ?", from, to).
+			Order("created_at, id")
+	}, batchSize, fn)
+}
+
+// streamCursor 在只读事务中声明服务端游标（DECLARE ... CURSOR），每次 FETCH batchSize 行交给 fn
+// 与 OFFSET 分页相比不会越翻越慢，内存占用也只与 batchSize 有关，适合导出百万行级别的数据；
+// REPEATABLE READ 保证整个导出基于同一个快照，导出过程中新写入的数据不会造成重复或遗漏
+func streamCursor[T any](ctx context.Context, db *gorm.DB, query func(tx *gorm.DB) *gorm.DB, batchSize int, fn func([]T) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DECLARE export_cursor NO SCROLL CURSOR FOR ?", query(tx)).Error; err != nil {
+			return fmt.Errorf("声明游标失败: %w", err)
+		}
+		// FETCH 的行数不支持参数占位符，batchSize 由调用方传入整数，直接拼接是安全的
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", batchSize)
+		for {
+			var batch []T
+			if err := tx.Raw(fetch).Scan(&batch).Error; err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := fn(batch); err != nil {
+				return err
+			}
+			if len(batch) < batchSize {
+				return nil
+			}
+		}
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// ==================== 实时点击流（Redis） ====================
+
+// clickStreamScript 把一个租户的一批点击追加到回放 Stream，并逐条发布到租户频道
+// 追加和发布在同一个脚本中完成，频道中的消息顺序与 Stream ID 的顺序一致，订阅者可以按 ID 去重
+//
+// KEYS[1] 回放 Stream  ARGV[1] 频道  ARGV[2] 近似最大长度  ARGV[3] 过期时间（毫秒）  ARGV[4...] 点击事件 JSON
+// 频道消息格式为 "<Stream ID> <点击事件 JSON>"
+var clickStreamScript = redis.NewScript(`
+for i = 4, #ARGV do
+	local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', 'event', ARGV[i])
+	redis.call('PUBLISH', ARGV[1], id .. ' ' .. ARGV[i])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return #ARGV - 3
+`)
+
+func clickStreamKey(tenantID uuid.UUID) string {
+	return fmt.Sprintf("clickstream:%s", tenantID)
+}
+
+func clickChannel(tenantID uuid.UUID) string {
+	return fmt.Sprintf("clicks:%s", tenantID)
+}
+
+// PublishClicks 按租户分组发布点击，每个租户执行一次脚本
+func (r *Repository) PublishClicks(ctx context.Context, events []model.ClickEvent, maxLen int, ttl time.Duration) error {
+	byTenant := make(map[uuid.UUID][]interface{})
+	var order []uuid.UUID
+	for i := range events {
+		data, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+		tenantID := events[i].TenantID
+		if _, ok := byTenant[tenantID]; !ok {
+			order = append(order, tenantID)
+		}
+		byTenant[tenantID] = append(byTenant[tenantID], data)
+	}
+
+	var errs []error
+	for _, tenantID := range order {
+		args := append([]interface{}{clickChannel(tenantID), maxLen, ttl.Milliseconds()}, byTenant[tenantID]...)
+		if err := clickStreamScript.Run(ctx, r.rdb, []string{clickStreamKey(tenantID)}, args...).Err(); err != nil {
+			errs = append(errs, fmt.Errorf("租户 %s: %w", tenantID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SubscribeClicks 订阅租户频道，等到 Redis 确认订阅后才返回，之后发布的点击都不会遗漏
+func (r *Repository) SubscribeClicks(ctx context.Context, tenantID uuid.UUID) (ClickSubscription, error) {
+	pubsub := r.rdb.Subscribe(ctx, clickChannel(tenantID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &redisClickSubscription{pubsub: pubsub, ch: make(chan StreamedClick, clickSubscriptionBuffer)}
+	go sub.run(ctx, r.logger)
+	return sub, nil
+}
+
+// ClickBacklog 从回放 Stream 中读取 afterID 之后的点击
+func (r *Repository) ClickBacklog(ctx context.Context, tenantID uuid.UUID, afterID string, limit int) ([]StreamedClick, error) {
+	start := "-"
+	if afterID != "" {
+		start = "(" + afterID
+	}
+	msgs, err := r.rdb.XRangeN(ctx, clickStreamKey(tenantID), start, "+", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	clicks := make([]StreamedClick, 0, len(msgs))
+	for _, msg := range msgs {
+		data, _ := msg.Values["event"].(string)
+		click := StreamedClick{ID: msg.ID}
+		if err := json.Unmarshal([]byte(data), &click.Event); err != nil {
+			r.logger.Warn("解析回放点击事件失败", zap.String("id", msg.ID), zap.Error(err))
+			continue
+		}
+		clicks = append(clicks, click)
+	}
+	return clicks, nil
+}
+
+// clickSubscriptionBuffer 订阅 channel 的缓冲大小，消费者跟不上时丢弃新消息（客户端可以用 Last-Event-ID 重连补齐）
+const clickSubscriptionBuffer = 256
+
+// redisClickSubscription 基于 Redis Pub/Sub 的点击订阅
+type redisClickSubscription struct {
+	pubsub *redis.PubSub
+	ch     chan StreamedClick
+	once   sync.Once
+}
+
+func (s *redisClickSubscription) C() <-chan StreamedClick {
+	return s.ch
+}
+
+func (s *redisClickSubscription) Close() error {
+	var err error
+	s.once.Do(func() { err = s.pubsub.Close() })
+	return err
+}
+
+// run 把频道消息转换为 StreamedClick，订阅关闭（Close 或 ctx 结束）后关闭 ch
+func (s *redisClickSubscription) run(ctx context.Context, logger *zap.Logger) {
+	defer close(s.ch)
+
+	msgs := s.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			id, data, found := strings.Cut(msg.Payload, " ")
+			if !found {
+				continue
+			}
+			click := StreamedClick{ID: id}
+			if err := json.Unmarshal([]byte(data), &click.Event); err != nil {
+				logger.Warn("解析实时点击事件失败", zap.String("id", id), zap.Error(err))
+				continue
+			}
+			select {
+			case s.ch <- click:
+			default:
+				// 消费者跟不上，丢弃
+			}
+		}
+	}
+}
+
 // ==================== 限流相关（Redis） ====================
 
+// rateLimitScript 滑动日志限流，检查与记录在一个脚本内原子完成
+// 旧实现用 Pipeline 先 ZADD 再 ZCARD，并发请求之间没有原子性，
+// 而且被拒绝的请求也会写入窗口，持续超限的客户端永远等不到配额恢复
+//
+// KEYS[1] 限流 key
+// ARGV[1] 窗口长度（毫秒）  ARGV[2] 窗口内允许的请求数  ARGV[3] 本次请求的唯一成员
+// 返回 {是否放行, 剩余配额, 重置时间（Unix 毫秒）}
+//
+// 使用 Redis 服务端时间，避免多个实例之间的时钟偏差
+var rateLimitScript = redis.NewScript(`
+local key    = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit  = tonumber(ARGV[2])
+
+local t   = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[3])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = now + window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+
+local remaining = limit - count
+if remaining < 0 then
+	remaining = 0
+end
+return {allowed, remaining, reset}
+`)
+
 // CheckRateLimit 检查租户是否超过限流
 // SaaS 重要功能：不同套餐的租户有不同的 API 调用配额
-// 使用 Redis 的滑动窗口计数器实现分布式限流
-func (r *Repository) CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (bool, error) {
+// 使用 Redis Sorted Set 滑动日志 + Lua 脚本实现原子的分布式限流
+func (r *Repository) CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (*RateLimitResult, error) {
 	key := fmt.Sprintf("ratelimit:%s", tenantID.String())
-	now := time.Now().Unix()
-	windowStart := now - 60 // 1分钟滑动窗口
+	member := uuid.NewString()
 
-	pipe := r.rdb.Pipeline()
+	res, err := rateLimitScript.Run(ctx, r.rdb, []string{key},
+		RateLimitWindow.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("限流脚本返回值异常: %v", res)
+	}
+
+	return &RateLimitResult{
+		Allowed:   res[0] == 1,
+		Limit:     limit,
+		Remaining: int(res[1]),
+		ResetAt:   time.UnixMilli(res[2]),
+	}, nil
+}
 
-	// 移除窗口外的记录
-	pipe.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", windowStart))
+// failureScript 失败计数：第一次失败时设置过期时间，窗口结束后计数自动清零
+// KEYS[1] 计数 key  ARGV[1] 窗口长度（毫秒）
+var failureScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
 
-	// 添加当前请求
-	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now), Member: fmt.Sprintf("%d-%d", now, time.Now().UnixNano())})
+// RecordFailure 记录一次失败并返回窗口内的失败次数
+func (r *Repository) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return failureScript.Run(ctx, r.rdb, []string{"failures:" + key}, window.Milliseconds()).Int64()
+}
 
-	// 获取窗口内的请求数
-	countCmd := pipe.ZCard(ctx, key)
+// ResetFailures 清除失败计数
+func (r *Repository) ResetFailures(ctx context.Context, key string) error {
+	return r.rdb.Del(ctx, "failures:"+key).Err()
+}
 
-	// 设置 key 过期时间
-	pipe.Expire(ctx, key, 2*time.Minute)
+// ==================== 二维码缓存 ====================
 
-	if _, err := pipe.Exec(ctx); err != nil {
-		return false, err
+// qrCacheKey 同一短链接的二维码图片存放在一个 Hash 中，field 为绘制参数，便于整体失效
+func qrCacheKey(link string) string {
+	return fmt.Sprintf("qr:%s", link)
+}
+
+// GetQRCode 读取缓存的二维码图片
+func (r *Repository) GetQRCode(ctx context.Context, link, key string) ([]byte, error) {
+	data, err := r.rdb.HGet(ctx, qrCacheKey(link), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
 	}
+	return data, err
+}
 
-	count := countCmd.Val()
-	return count <= int64(limit), nil
+// SetQRCode 缓存二维码图片，每次写入都会刷新整组的过期时间
+func (r *Repository) SetQRCode(ctx context.Context, link, key string, data []byte, ttl time.Duration) error {
+	pipe := r.rdb.TxPipeline()
+	pipe.HSet(ctx, qrCacheKey(link), key, data)
+	pipe.Expire(ctx, qrCacheKey(link), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 // HealthCheck 健康检查 - 验证数据库和 Redis 连接
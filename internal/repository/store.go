@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/pagination"
+)
+
+// 存储层通用错误
+// 所有 Store 实现都返回这些错误，Service 层据此判断，而不依赖具体存储
+var (
+	ErrNotFound  = gorm.ErrRecordNotFound // 记录不存在（或不属于当前租户）
+	ErrDuplicate = gorm.ErrDuplicatedKey  // 违反唯一约束
+
+	ErrLastAdminKey = errors.New("不能吊销最后一个有效的 admin Key") // 吊销后租户将无法再管理自己的 Key
+)
+
+// Store 存储层接口
+// Service 只依赖这个接口：生产环境使用 PostgreSQL + Redis 实现（Repository），
+// 单元测试使用内存实现（MemoryStore），无需启动任何外部服务
+type Store interface {
+	TenantStore
+	PlanStore
+	APIKeyStore
+	DomainStore
+	WebhookStore
+	URLStore
+	ClickStore
+	ClickStream
+	RateLimiter
+	QRCodeCache
+
+	// HealthCheck 检查底层存储是否可用
+	HealthCheck(ctx context.Context) error
+}
+
+// TenantStore 租户存储
+type TenantStore interface {
+	CreateTenant(ctx context.Context, tenant *model.Tenant, key *model.APIKey) error
+	GetTenantByID(ctx context.Context, id uuid.UUID) (*model.Tenant, error)
+	GetTenantByAPIKey(ctx context.Context, keyHash string) (*model.Tenant, *model.APIKey, error)
+	// ChangeTenantPlan 将租户切换到指定套餐，并按套餐重新计算 RateLimit / MaxURLs
+	ChangeTenantPlan(ctx context.Context, tenantID uuid.UUID, plan *model.Plan) (*model.Tenant, error)
+	CountTenantsByPlan(ctx context.Context, plan string) (int64, error)
+}
+
+// PlanStore 套餐目录存储
+type PlanStore interface {
+	CreatePlan(ctx context.Context, plan *model.Plan) error
+	GetPlan(ctx context.Context, name string) (*model.Plan, error)
+	ListPlans(ctx context.Context) ([]model.Plan, error)
+	// UpdatePlan 修改套餐配额，同时同步该套餐下所有租户的 RateLimit / MaxURLs
+	UpdatePlan(ctx context.Context, plan *model.Plan) error
+	DeletePlan(ctx context.Context, name string) error
+}
+
+// APIKeyStore API Key 存储
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	ListAPIKeysByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.APIKey, error)
+	// RevokeAPIKey 吊销 Key；如果它是租户最后一个有效的 admin Key，返回 ErrLastAdminKey
+	// 检查与吊销必须是原子的，否则并发吊销不同的 admin Key 可能把它们全部吊销
+	RevokeAPIKey(ctx context.Context, tenantID, id uuid.UUID) (*model.APIKey, error)
+	RotateAPIKey(ctx context.Context, tenantID, id uuid.UUID, newKey *model.APIKey) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+// DomainStore 租户自定义域名存储
+type DomainStore interface {
+	// CreateDomain 添加域名，同一租户重复添加同一域名时返回 ErrDuplicate
+	CreateDomain(ctx context.Context, domain *model.Domain) error
+	ListDomainsByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Domain, error)
+	CountDomainsByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error)
+	GetDomainByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Domain, error)
+	GetDomainByHostname(ctx context.Context, tenantID uuid.UUID, hostname string) (*model.Domain, error)
+	// VerifyDomain 标记域名已通过所有权验证，其他租户已验证同一域名时返回 ErrDuplicate
+	VerifyDomain(ctx context.Context, tenantID, id uuid.UUID, verifiedAt time.Time) (*model.Domain, error)
+	DeleteDomain(ctx context.Context, tenantID, id uuid.UUID) error
+	// IsVerifiedDomain 判断 hostname 是否为某个租户已验证的域名（重定向时使用，走缓存）
+	IsVerifiedDomain(ctx context.Context, hostname string) (bool, error)
+}
+
+// WebhookStore Webhook 配置与投递队列存储
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	ListWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) ([]model.Webhook, error)
+	CountWebhooksByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error)
+	GetWebhookByID(ctx context.Context, tenantID, id uuid.UUID) (*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}) (*model.Webhook, error)
+	// DeleteWebhook 删除 Webhook 及其全部投递记录
+	DeleteWebhook(ctx context.Context, tenantID, id uuid.UUID) error
+	// ListSubscribedWebhooks 查询这些租户中订阅了 event 的启用中的 Webhook
+	ListSubscribedWebhooks(ctx context.Context, tenantIDs []uuid.UUID, event string) ([]model.Webhook, error)
+	// GetWebhooks 按 ID 批量查询（投递 worker 使用，不按租户过滤）
+	GetWebhooks(ctx context.Context, ids []uuid.UUID) ([]model.Webhook, error)
+
+	CreateWebhookDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	// ClaimWebhookDeliveries 领取最多 limit 条到期的 pending 投递（所属 Webhook 须为启用状态），
+	// 同时把 next_attempt_at 推迟到 now+lease：多个副本同时领取不会拿到同一条，
+	// 领取后 worker 崩溃的记录在租约到期后重新可见
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	// SaveWebhookAttempt 保存一次投递的结果（status / attempts / next_attempt_at 等）
+	SaveWebhookAttempt(ctx context.Context, delivery *model.WebhookDelivery) error
+	// ListWebhookDeliveries 按 (created_at, id) 倒序分页查询投递记录，status 为空时不过滤，分页语义同 ListShortURLsByTenant
+	ListWebhookDeliveries(ctx context.Context, tenantID, webhookID uuid.UUID, status string, page pagination.Query) ([]model.WebhookDelivery, error)
+	// RedeliverWebhookDelivery 把投递重新放回队列，立即投递并重新计算重试次数
+	RedeliverWebhookDelivery(ctx context.Context, tenantID, webhookID, id uuid.UUID, now time.Time) (*model.WebhookDelivery, error)
+	// PurgeWebhookDeliveries 删除 before 之前创建的已投递 / 已放弃记录，返回删除的条数
+	PurgeWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// URLStore 短链接存储
+// 短码在同一域名内唯一，domain 为空表示默认域名；
+// 除 GetShortURLByCode（公开重定向）外，所有方法都按 TenantID 过滤；
+// 创建时一并保存 ShortURL.Tags 和 ShortURL.Variants，按 ID 查询、列表和导出返回的短链接带有 Tags，
+// 按短码 / ID 查询和列表返回的短链接带有 Variants
+type URLStore interface {
+	CreateShortURL(ctx context.Context, shortURL *model.ShortURL) error
+	// CreateShortURLs 在一个事务中批量创建短链接，短码已存在的记录跳过并返回其 ID
+	CreateShortURLs(ctx context.Context, urls []model.ShortURL) (conflicts []uuid.UUID, err error)
+	GetShortURLByCode(ctx context.Context, domain, code string) (*model.ShortURL, error)
+	// CodeExists 判断短码在该域名下是否已被使用（包括停用的短链接），不走缓存
+	CodeExists(ctx context.Context, domain, code string) (bool, error)
+	GetShortURLByID(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error)
+	// UpdateShortURL 修改列并（tags / variants 不为 nil 时）整体替换标签 / A/B 测试目标，在同一事务中完成
+	UpdateShortURL(ctx context.Context, tenantID, id uuid.UUID, updates map[string]interface{}, tags []string, variants []model.ShortURLVariant) (*model.ShortURL, error)
+	// DeleteShortURL 删除短链接并返回被删除的记录
+	DeleteShortURL(ctx context.Context, tenantID, id uuid.UUID) (*model.ShortURL, error)
+	// ListShortURLsByTenant 按筛选条件查询一页短链接，最多返回 page.Limit+1 条（多出的一条用于判断是否还有下一页），
+	// page.Backward 时按反向顺序返回
+	ListShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter, page pagination.Query) ([]model.ShortURL, error)
+	CountFilteredShortURLs(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter) (int64, error)
+	// StreamShortURLsByTenant 按创建时间顺序遍历租户的全部短链接，每批最多 batchSize 条交给 fn，
+	// fn 返回错误时停止遍历并返回该错误
+	StreamShortURLsByTenant(ctx context.Context, tenantID uuid.UUID, batchSize int, fn func([]model.ShortURL) error) error
+	CountURLsByTenant(ctx context.Context, tenantID uuid.UUID) (int64, error)
+	CountURLsByDomain(ctx context.Context, tenantID uuid.UUID, domain string) (int64, error)
+	GetClicks(ctx context.Context, urlID uuid.UUID) (int64, error)
+	// ReserveClick 点击次数小于 limit 时原子地计入一次点击并返回 true，用于在点击写入数据库之前执行点击上限
+	// 计数不存在时以数据库中的 clicks 为初始值；超过 clickCounterTTL 没有点击后过期，下次重新以数据库为准
+	ReserveClick(ctx context.Context, urlID uuid.UUID, limit int64) (bool, error)
+}
+
+// ClickStore 点击事件存储与统计
+type ClickStore interface {
+	// RecordClicks 写入点击事件，机器人点击（IsBot）累加到 bot_clicks，其余累加到 clicks
+	RecordClicks(ctx context.Context, events []model.ClickEvent, batchSize int) error
+	// GetClickSeries / GetTopClickValues 的 includeBots 为 false 时只统计非机器人点击
+	GetClickSeries(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, interval string, includeBots bool) ([]model.ClickBucket, error)
+	// GetTopClickValues 的 column 为 ClickLocationColumn 时按城市统计
+	GetTopClickValues(ctx context.Context, tenantID, urlID uuid.UUID, from, to time.Time, column string, limit int, includeBots bool) ([]model.CountItem, error)
+	// GetTenantStats 返回的 TotalClicks 不含机器人点击，机器人点击单独计入 BotClicks
+	GetTenantStats(ctx context.Context, tenantID uuid.UUID) (*model.StatsResponse, error)
+	CountClicksByTenantSince(ctx context.Context, tenantID uuid.UUID, since time.Time) (int64, error)
+	// StreamClicksByTenant 按时间顺序遍历租户在 [from, to) 内的点击事件，分批方式同 StreamShortURLsByTenant
+	StreamClicksByTenant(ctx context.Context, tenantID uuid.UUID, from, to time.Time, batchSize int, fn func([]model.ClickEvent) error) error
+}
+
+// ClickLocationColumn GetTopClickValues 的虚拟列：按 "国家/省州/城市" 统计（同名城市不会合并），不含城市为空的点击
+const ClickLocationColumn = "location"
+
+// ClickStream 实时点击流：按租户广播点击事件，并保留一段可回放的历史
+type ClickStream interface {
+	// PublishClicks 把点击追加到各自租户的回放队列（最多保留约 maxLen 条，ttl 内没有新点击则过期）并广播给订阅者
+	PublishClicks(ctx context.Context, events []model.ClickEvent, maxLen int, ttl time.Duration) error
+	// SubscribeClicks 订阅租户的实时点击，返回时订阅已经生效；ctx 结束或调用 Close 后停止接收
+	SubscribeClicks(ctx context.Context, tenantID uuid.UUID) (ClickSubscription, error)
+	// ClickBacklog 按顺序返回回放队列中 ID 大于 afterID 的点击，最多 limit 条
+	ClickBacklog(ctx context.Context, tenantID uuid.UUID, afterID string, limit int) ([]StreamedClick, error)
+}
+
+// StreamedClick 点击流中的一个事件，ID 在租户内单调递增（格式同 Redis Stream ID：<毫秒时间戳>-<序号>）
+type StreamedClick struct {
+	ID    string
+	Event model.ClickEvent
+}
+
+// ClickSubscription 一个实时点击订阅
+type ClickSubscription interface {
+	// C 返回接收点击的 channel，订阅结束后关闭
+	C() <-chan StreamedClick
+	Close() error
+}
+
+// ParseStreamID 解析点击流 ID，格式错误时 ok 为 false
+func ParseStreamID(id string) (ms, seq uint64, ok bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err = strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
+
+// StreamIDAfter 判断点击流 ID a 是否在 b 之后，两者都必须是合法的 ID
+func StreamIDAfter(a, b string) bool {
+	aMs, aSeq, _ := ParseStreamID(a)
+	bMs, bSeq, _ := ParseStreamID(b)
+	return aMs > bMs || (aMs == bMs && aSeq > bSeq)
+}
+
+// RateLimitWindow 限流滑动窗口长度（套餐的 RateLimit 即每个窗口内允许的请求数）
+const RateLimitWindow = time.Minute
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed   bool      // 本次请求是否放行（被拒绝的请求不占用配额）
+	Limit     int       // 窗口内允许的请求数
+	Remaining int       // 本次请求之后窗口内剩余的配额
+	ResetAt   time.Time // 窗口内最早的请求滑出窗口、释放出一个配额的时间
+}
+
+// RetryAfter 被拒绝时距离下一个配额可用还需等待的时间
+func (r *RateLimitResult) RetryAfter(now time.Time) time.Duration {
+	if r.Allowed || !r.ResetAt.After(now) {
+		return 0
+	}
+	return r.ResetAt.Sub(now)
+}
+
+// RateLimiter 分布式限流
+type RateLimiter interface {
+	CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (*RateLimitResult, error)
+	// RecordFailure 原子地记录一次失败并返回窗口内的失败次数（如输错短链接密码），窗口从第一次失败开始计时（固定窗口）
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	// ResetFailures 清除 key 的失败计数
+	ResetFailures(ctx context.Context, key string) error
+}
+
+// QRCodeCache 短链接二维码图片缓存
+// 同一短链接（link 为 ShortURL.LinkKey）的所有图片（不同格式 / 尺寸 / 颜色）归为一组，短链接修改或删除时整组失效
+type QRCodeCache interface {
+	// GetQRCode 读取缓存的图片，未命中时返回 ErrNotFound
+	GetQRCode(ctx context.Context, link, key string) ([]byte, error)
+	SetQRCode(ctx context.Context, link, key string, data []byte, ttl time.Duration) error
+}
+
+// 编译期检查两种实现都满足 Store 接口
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// isLastAdminKey 判断 id 是否为 keys 中最后一个有效（未吊销、未过期）的 admin Key
+func isLastAdminKey(keys []model.APIKey, id uuid.UUID, now time.Time) bool {
+	targetIsAdmin := false
+	otherAdmins := 0
+	for i := range keys {
+		k := &keys[i]
+		if k.Revoked || (k.ExpiresAt != nil && k.ExpiresAt.Before(now)) || !k.HasScope(model.ScopeAdmin) {
+			continue
+		}
+		if k.ID == id {
+			targetIsAdmin = true
+		} else {
+			otherAdmins++
+		}
+	}
+	return targetIsAdmin && otherAdmins == 0
+}
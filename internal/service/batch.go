@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var ErrBatchTooLarge = errors.New("批量创建的条数超过上限")
+
+// itemValidator 校验批量创建/导入中的单条请求
+// 使用与 Gin 相同的 binding 标签，单条创建和批量创建的校验规则保持一致
+var itemValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+// pendingURL 等待写入的短链接
+type pendingURL struct {
+	url    model.ShortURL
+	custom bool // 是否为自定义短码（冲突时不能自动换码）
+}
+
+// batchQuota 批量写入前一次性检查的配额
+type batchQuota struct {
+	remaining int64
+	used      int64 // 已写入的 URL 数（含本批次已写入的），用于判断是否跨过 quota.warning 阈值
+	limit     int64
+	allowCode bool
+	domains   map[string]domainResult // 本批次已校验过的域名，同一域名只查询一次
+}
+
+// domainResult 域名校验结果，见 Service.linkDomain
+type domainResult struct {
+	hostname string
+	err      error
+}
+
+// ==================== 批量创建 ====================
+
+// CreateShortURLs 批量创建短链接
+// 配额只在开始时检查一次：条数超过剩余配额时整批拒绝；
+// 单条记录校验失败或短码冲突不影响其他记录，结果按请求顺序逐条返回
+func (s *Service) CreateShortURLs(ctx context.Context, tenantID uuid.UUID, reqs []model.CreateShortURLRequest) (_ *model.BatchCreateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateShortURLs")
+	defer tracing.End(span, &err)
+
+	if len(reqs) > s.cfg.Batch.MaxItems {
+		return nil, fmt.Errorf("%w: 最多 %d 条", ErrBatchTooLarge, s.cfg.Batch.MaxItems)
+	}
+
+	quota, err := s.batchQuota(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(reqs)) > quota.remaining {
+		return nil, fmt.Errorf("%w: 剩余 %d 条，本次请求 %d 条", ErrQuotaExceeded, quota.remaining, len(reqs))
+	}
+
+	resp := &model.BatchCreateResponse{Results: make([]model.BatchItemResult, len(reqs))}
+	now := time.Now()
+
+	var pending []pendingURL
+	var indexes []int // pending[i] 对应的请求下标
+	for i := range reqs {
+		resp.Results[i].Index = i
+		u, err := s.prepareItem(ctx, tenantID, &reqs[i], quota, now)
+		if err != nil {
+			resp.Results[i].Error = toItemError(err)
+			continue
+		}
+		pending = append(pending, pendingURL{url: *u, custom: reqs[i].CustomCode != ""})
+		indexes = append(indexes, i)
+	}
+
+	var created []*model.ShortURL
+	chunkSize := s.chunkSize()
+	for start := 0; start < len(pending); start += chunkSize {
+		end := min(start+chunkSize, len(pending))
+		errs := s.insertPending(ctx, pending[start:end])
+		for j, err := range errs {
+			result := &resp.Results[indexes[start+j]]
+			if err != nil {
+				result.Error = toItemError(err)
+				continue
+			}
+			result.URL = s.toShortURLResponse(&pending[start+j].url)
+			created = append(created, &pending[start+j].url)
+		}
+	}
+	s.publishCreated(ctx, tenantID, quota, created)
+
+	for _, r := range resp.Results {
+		if r.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Created++
+		}
+	}
+
+	s.logger.Info("批量创建短链接",
+		zap.String("tenant_id", tenantID.String()),
+		zap.Int("created", resp.Created),
+		zap.Int("failed", resp.Failed),
+	)
+	return resp, nil
+}
+
+// ==================== 导入 ====================
+
+// ImportShortURLs 从 CSV / JSON Lines 流中导入短链接
+// 边读边写：每攒满 ChunkSize 条写入一个事务，内存占用与文件大小无关。
+// 配额在开始时查询一次，之后在内存中扣减，超出配额的行记为失败。
+// 读取数据流本身出错（如超过大小限制）时停止导入，已写入的记录保留，返回已处理部分的结果和错误
+func (s *Service) ImportShortURLs(ctx context.Context, tenantID uuid.UUID, src ImportSource) (_ *model.ImportResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ImportShortURLs")
+	defer tracing.End(span, &err)
+
+	quota, err := s.batchQuota(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.ImportResponse{Errors: []model.ImportError{}}
+	fail := func(line int, err error) {
+		resp.Failed++
+		if len(resp.Errors) >= model.MaxImportErrors {
+			resp.ErrorsTruncated = true
+			return
+		}
+		resp.Errors = append(resp.Errors, model.ImportError{Line: line, ItemError: *toItemError(err)})
+	}
+
+	var pending []pendingURL
+	var lines []int
+	flush := func() {
+		var created []*model.ShortURL
+		for i, err := range s.insertPending(ctx, pending) {
+			if err != nil {
+				fail(lines[i], err)
+				quota.remaining++ // 写入失败的行不占用配额
+				continue
+			}
+			resp.Created++
+			created = append(created, &pending[i].url)
+		}
+		// 事件在下一批复用 pending 之前发布
+		s.publishCreated(ctx, tenantID, quota, created)
+		pending, lines = pending[:0], lines[:0]
+	}
+
+	now := time.Now()
+	for {
+		line, req, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			flush()
+			return resp, fmt.Errorf("读取导入数据失败: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			flush()
+			return resp, err
+		}
+
+		resp.Total++
+		if rowErr != nil {
+			fail(line, rowErr)
+			continue
+		}
+		if quota.remaining <= 0 {
+			fail(line, ErrQuotaExceeded)
+			continue
+		}
+		u, err := s.prepareItem(ctx, tenantID, req, quota, now)
+		if err != nil {
+			fail(line, err)
+			continue
+		}
+
+		quota.remaining--
+		pending = append(pending, pendingURL{url: *u, custom: req.CustomCode != ""})
+		lines = append(lines, line)
+		if len(pending) >= s.chunkSize() {
+			flush()
+		}
+	}
+	flush()
+
+	s.logger.Info("导入短链接",
+		zap.String("tenant_id", tenantID.String()),
+		zap.Int("total", resp.Total),
+		zap.Int("created", resp.Created),
+		zap.Int("failed", resp.Failed),
+	)
+	return resp, nil
+}
+
+// ==================== 辅助函数 ====================
+
+// batchQuota 查询租户剩余的 URL 配额和套餐是否允许自定义短码
+func (s *Service) batchQuota(ctx context.Context, tenantID uuid.UUID) (*batchQuota, error) {
+	tenant, err := s.repo.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询租户失败: %w", err)
+	}
+	plan, err := s.getPlan(ctx, tenant.Plan)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountURLsByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询配额失败: %w", err)
+	}
+
+	return &batchQuota{
+		remaining: max(int64(tenant.MaxURLs)-count, 0),
+		used:      count,
+		limit:     int64(tenant.MaxURLs),
+		allowCode: plan.AllowCustomCode,
+		domains:   make(map[string]domainResult),
+	}, nil
+}
+
+// batchLinkDomain 校验单条记录指定的域名，结果在本批次内复用
+func (s *Service) batchLinkDomain(ctx context.Context, tenantID uuid.UUID, quota *batchQuota, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	r, ok := quota.domains[name]
+	if !ok {
+		r.hostname, r.err = s.linkDomain(ctx, tenantID, name)
+		quota.domains[name] = r
+	}
+	return r.hostname, r.err
+}
+
+// chunkSize 每个事务写入的条数
+func (s *Service) chunkSize() int {
+	return max(s.cfg.Batch.ChunkSize, 1)
+}
+
+// prepareItem 校验单条请求并构造短链接，随机短码在此生成
+func (s *Service) prepareItem(ctx context.Context, tenantID uuid.UUID, req *model.CreateShortURLRequest, quota *batchQuota, now time.Time) (*model.ShortURL, error) {
+	if err := itemValidator.Struct(req); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidItem, err)
+	}
+	u, err := s.newShortURL(tenantID, req, quota.allowCode, now)
+	if err != nil {
+		return nil, err
+	}
+	if u.Domain, err = s.batchLinkDomain(ctx, tenantID, quota, req.Domain); err != nil {
+		return nil, err
+	}
+
+	u.Code = req.CustomCode
+	if u.Code == "" {
+		code, ok := s.codes.Generate()
+		if !ok {
+			return nil, ErrCodeExhausted
+		}
+		u.Code = code
+	}
+	return u, nil
+}
+
+// insertPending 在一个事务中写入一批短链接，返回与 pending 一一对应的错误（nil 表示成功）
+// 随机短码冲突的记录换码后再写入，最多重试 maxRetries 轮；自定义短码冲突直接失败并给出候选短码
+func (s *Service) insertPending(ctx context.Context, pending []pendingURL) []error {
+	errs := make([]error, len(pending))
+	todo := make([]int, len(pending))
+	for i := range todo {
+		todo[i] = i
+	}
+
+	for attempt := 1; len(todo) > 0; attempt++ {
+		urls := make([]model.ShortURL, len(todo))
+		for i, idx := range todo {
+			urls[i] = pending[idx].url
+		}
+
+		conflictIDs, err := s.repo.CreateShortURLs(ctx, urls)
+		if err != nil {
+			for _, idx := range todo {
+				errs[idx] = fmt.Errorf("创建短链接失败: %w", err)
+			}
+			return errs
+		}
+		conflicts := make(map[uuid.UUID]bool, len(conflictIDs))
+		for _, id := range conflictIDs {
+			conflicts[id] = true
+		}
+
+		var retry []int
+		for _, idx := range todo {
+			p := &pending[idx]
+			if !conflicts[p.url.ID] {
+				continue
+			}
+			if p.custom {
+				errs[idx] = &CodeTakenError{Code: p.url.Code, Suggestion: s.suggestCode(ctx, p.url.Domain, p.url.Code)}
+				continue
+			}
+
+			codeCollisionsTotal.Inc()
+			code, ok := s.codes.Generate()
+			if !ok || attempt >= s.codes.maxRetries {
+				errs[idx] = ErrCodeExhausted
+				continue
+			}
+			p.url.Code = code
+			retry = append(retry, idx)
+		}
+		todo = retry
+	}
+	return errs
+}
+
+// publishCreated 为一批成功写入的短链接发布 url.created，用量跨过阈值时发布 quota.warning
+func (s *Service) publishCreated(ctx context.Context, tenantID uuid.UUID, quota *batchQuota, created []*model.ShortURL) {
+	if len(created) == 0 {
+		return
+	}
+	s.publishURLs(ctx, model.EventURLCreated, created...)
+	before := quota.used
+	quota.used += int64(len(created))
+	s.publishQuotaWarning(ctx, tenantID, before, quota.used, quota.limit)
+}
+
+// errInvalidItem 单条请求参数校验失败
+var errInvalidItem = errors.New("参数错误")
+
+// toItemError 将单条记录的错误转换为响应中的错误码
+func toItemError(err error) *model.ItemError {
+	itemErr := &model.ItemError{Message: err.Error()}
+
+	var taken *CodeTakenError
+	switch {
+	case errors.As(err, &taken):
+		itemErr.Code = "CODE_TAKEN"
+		itemErr.Suggestion = taken.Suggestion
+	case errors.Is(err, errInvalidItem), IsInvalidInput(err):
+		itemErr.Code = "INVALID_REQUEST"
+	case errors.Is(err, ErrInvalidCode):
+		itemErr.Code = "INVALID_CODE"
+	case errors.Is(err, ErrCustomCodeDenied):
+		itemErr.Code = "CUSTOM_CODE_NOT_ALLOWED"
+	case errors.Is(err, ErrQuotaExceeded):
+		itemErr.Code = "QUOTA_EXCEEDED"
+	case errors.Is(err, ErrCodeExhausted):
+		itemErr.Code = "CODE_EXHAUSTED"
+	default:
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			itemErr.Code = "INVALID_REQUEST"
+			break
+		}
+		itemErr.Code = "INTERNAL_ERROR"
+		itemErr.Message = "服务器错误"
+	}
+	return itemErr
+}
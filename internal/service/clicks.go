@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+	"github.com/yourname/saas-shortener/internal/useragent"
+)
+
+// 队列满时的处理策略
+const (
+	OverflowDrop  = "drop"  // 直接丢弃新事件，重定向不受任何影响
+	OverflowBlock = "block" // 最多等待 EnqueueTimeout，仍然满则丢弃
+)
+
+// 点击管道指标
+var (
+	clickQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "click_queue_depth",
+		Help: "点击事件队列中等待写入的事件数",
+	})
+
+	clickEventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "click_events_dropped_total",
+			Help: "被丢弃的点击事件数",
+		},
+		[]string{"reason"}, // queue_full / flush_error / shutdown
+	)
+
+	clickEventsFlushed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "click_events_flushed_total",
+		Help: "成功写入数据库的点击事件数",
+	})
+
+	clickFlushCallbacksBlocked = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "click_flush_callbacks_blocked_total",
+		Help: "回调队列已满、worker 需要等待 OnFlush 回调执行的批次数",
+	})
+)
+
+// flushTimeout 单次批量写入的超时时间，也是单次 OnFlush 回调的超时时间
+const flushTimeout = 10 * time.Second
+
+// flushedQueueSize 等待执行 OnFlush 回调的批次数上限
+const flushedQueueSize = 64
+
+// ClickPipeline 点击事件异步写入管道
+// 云原生最佳实践：热点路径只做内存操作，写库交给有界队列 + 固定 worker 池
+//
+// 原来每次点击都启动一个 goroutine 分别执行 UPDATE 和 INSERT，
+// 流量突增时 goroutine 数量不受控，且热门链接的同一行被并发更新。
+// 现在：
+// 1. Enqueue 把事件放入有界队列，队列满时按 OverflowPolicy 丢弃
+// 2. worker 攒够 BatchSize 或每隔 FlushInterval 批量写入一次
+// 3. 同一批次内的点击计数按短链接合并后再更新
+type ClickPipeline struct {
+	repo   repository.ClickStore
+	cfg    config.ClickConfig
+	logger *zap.Logger
+
+	queue   chan queuedClick
+	mu      sync.RWMutex // 保护 closed 和 onFlush，防止关闭后继续向 queue 发送
+	closed  bool
+	onFlush func(ctx context.Context, events []model.ClickEvent)
+	wg      sync.WaitGroup
+
+	// 已写入的批次交给单独的 goroutine 执行 OnFlush，回调变慢不影响点击写入
+	flushed   chan flushedBatch
+	flushedWG sync.WaitGroup
+}
+
+// flushedBatch 已写入数据库、等待执行 OnFlush 回调的一批事件
+type flushedBatch struct {
+	events []model.ClickEvent
+	span   trace.SpanContext
+}
+
+// queuedClick 队列中的点击事件，附带产生它的请求链路
+type queuedClick struct {
+	event model.ClickEvent
+	link  trace.Link
+}
+
+// NewClickPipeline 创建点击事件管道，需调用 Start 启动 worker
+func NewClickPipeline(repo repository.ClickStore, cfg config.ClickConfig, logger *zap.Logger) *ClickPipeline {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.OverflowPolicy != OverflowBlock {
+		cfg.OverflowPolicy = OverflowDrop
+	}
+
+	return &ClickPipeline{
+		repo:    repo,
+		cfg:     cfg,
+		logger:  logger,
+		queue:   make(chan queuedClick, cfg.QueueSize),
+		flushed: make(chan flushedBatch, flushedQueueSize),
+	}
+}
+
+// Start 启动 worker 和执行 OnFlush 回调的 goroutine
+func (p *ClickPipeline) Start() {
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	p.flushedWG.Add(1)
+	go p.notifier()
+}
+
+// OnFlush 设置每批事件成功写入后的回调（如生成 click.recorded Webhook 事件）
+// 回调在单独的 goroutine 中按批次顺序执行，每次最多 flushTimeout；
+// 积压超过 flushedQueueSize 批时 worker 等待回调执行（背压），不丢弃批次，点击事件在 queue 中排队
+func (p *ClickPipeline) OnFlush(fn func(ctx context.Context, events []model.ClickEvent)) {
+	p.mu.Lock()
+	p.onFlush = fn
+	p.mu.Unlock()
+}
+
+// Enqueue 将点击事件放入队列，返回是否成功
+// 不会阻塞超过 EnqueueTimeout，保证重定向延迟可控
+// ctx 中的链路会以 Link 的形式关联到之后批量写入的 span 上
+func (p *ClickPipeline) Enqueue(ctx context.Context, event model.ClickEvent) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	item := queuedClick{event: event, link: trace.LinkFromContext(ctx)}
+
+	if p.closed {
+		clickEventsDropped.WithLabelValues("shutdown").Inc()
+		return false
+	}
+
+	select {
+	case p.queue <- item:
+		clickQueueDepth.Set(float64(len(p.queue)))
+		return true
+	default:
+	}
+
+	if p.cfg.OverflowPolicy == OverflowBlock {
+		timer := time.NewTimer(p.cfg.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- item:
+			clickQueueDepth.Set(float64(len(p.queue)))
+			return true
+		case <-timer.C:
+		}
+	}
+
+	clickEventsDropped.WithLabelValues("queue_full").Inc()
+	return false
+}
+
+// Shutdown 停止接收新事件，等待队列中剩余事件全部写入、OnFlush 回调全部执行
+// 在 HTTP 服务关闭之后调用；ctx 超时则放弃等待并返回错误
+func (p *ClickPipeline) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	first := !p.closed
+	if first {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		// worker 全部退出后不会再有新的批次
+		if first {
+			close(p.flushed)
+		}
+		p.flushedWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), errors.New("点击事件未能全部写入"))
+	}
+}
+
+// worker 从队列读取事件，按批次写入数据库
+func (p *ClickPipeline) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]queuedClick, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case item, ok := <-p.queue:
+			if !ok {
+				// 队列已关闭：写入剩余事件后退出
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush 批量写入一批事件，失败时记录日志和丢弃指标（不重试，避免队列堆积）
+// 批量写入的 span 没有单一的父 span，而是通过 Link 关联到各个重定向请求的链路
+func (p *ClickPipeline) flush(batch []queuedClick) {
+	clickQueueDepth.Set(float64(len(p.queue)))
+	if len(batch) == 0 {
+		return
+	}
+
+	events := make([]model.ClickEvent, len(batch))
+	links := make([]trace.Link, 0, len(batch))
+	for i, item := range batch {
+		events[i] = item.event
+		classifyClick(&events[i])
+		if item.link.SpanContext.IsValid() {
+			links = append(links, item.link)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "ClickPipeline.flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("click.batch_size", len(events))),
+	)
+	defer span.End()
+
+	if err := p.repo.RecordClicks(ctx, events, p.cfg.BatchSize); err != nil {
+		tracing.RecordError(span, err)
+		p.logger.Error("批量写入点击事件失败",
+			zap.Int("count", len(events)),
+			zap.Error(err),
+		)
+		clickEventsDropped.WithLabelValues("flush_error").Add(float64(len(events)))
+		return
+	}
+	clickEventsFlushed.Add(float64(len(events)))
+
+	item := flushedBatch{events: events, span: span.SpanContext()}
+	select {
+	case p.flushed <- item:
+	default:
+		clickFlushCallbacksBlocked.Inc()
+		p.flushed <- item
+	}
+}
+
+// notifier 依次对已写入的批次执行 OnFlush 回调，直到 Shutdown
+func (p *ClickPipeline) notifier() {
+	defer p.flushedWG.Done()
+	for batch := range p.flushed {
+		p.mu.RLock()
+		onFlush := p.onFlush
+		p.mu.RUnlock()
+		if onFlush == nil {
+			continue
+		}
+
+		// 回调的 span 挂在批量写入的 span 下
+		ctx := trace.ContextWithSpanContext(context.Background(), batch.span)
+		ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+		onFlush(ctx, batch.events)
+		cancel()
+	}
+}
+
+// classifyClick 解析 User-Agent 得到浏览器、操作系统、设备类型和机器人标记
+// 在批量写入前解析，不占用重定向请求的时间
+func classifyClick(event *model.ClickEvent) {
+	info := useragent.Parse(event.UserAgent)
+	event.Browser = info.Browser
+	event.OS = info.OS
+	event.Device = info.Device
+	event.IsBot = info.Bot
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/repository"
+)
+
+// maxCodeColumnLength short_urls.code 列的长度
+const maxCodeColumnLength = 10
+
+// suggestionAttempts 自定义短码被占用时，最多尝试生成多少个候选短码
+const suggestionAttempts = 10
+
+// defaultReservedCodes 内置保留字
+// 除了已注册的路由前缀（由 Handler 注册路由后自动加入），还保留一些常见的站点路径，
+// 以免日后新增页面时与已存在的短码冲突
+var defaultReservedCodes = []string{
+	"api", "admin", "healthz", "readyz", "metrics",
+	"static", "assets", "docs", "login", "logout", "signup", "favicon",
+}
+
+// codeCollisionsTotal 随机短码与已有短码冲突的次数
+// 持续上升说明短码空间趋于饱和，应调大 SHORT_CODE_LENGTH
+var codeCollisionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "short_code_collisions_total",
+	Help: "随机生成的短码与已有短码冲突的次数",
+})
+
+// CodeTakenError 自定义短码已被占用，附带一个可用的候选短码
+type CodeTakenError struct {
+	Code       string
+	Suggestion string // 没有找到可用候选时为空
+}
+
+func (e *CodeTakenError) Error() string {
+	if e.Suggestion == "" {
+		return fmt.Sprintf("短码 %s 已被占用", e.Code)
+	}
+	return fmt.Sprintf("短码 %s 已被占用，可以使用 %s", e.Code, e.Suggestion)
+}
+
+// Is 使 errors.Is(err, ErrCodeTaken) 成立
+func (e *CodeTakenError) Is(target error) bool {
+	return target == ErrCodeTaken
+}
+
+// CodePolicy 短码生成与校验规则
+type CodePolicy struct {
+	length     int
+	charset    string
+	minLength  int
+	maxLength  int
+	pattern    *regexp.Regexp
+	maxRetries int
+
+	mu       sync.RWMutex
+	reserved map[string]struct{} // 小写保存，大小写不敏感匹配
+}
+
+// NewCodePolicy 根据配置创建短码规则，配置不合法时返回错误
+func NewCodePolicy(cfg config.ShortCodeConfig) (*CodePolicy, error) {
+	if cfg.Length < 1 || cfg.Length > maxCodeColumnLength {
+		return nil, fmt.Errorf("SHORT_CODE_LENGTH 必须在 1 到 %d 之间", maxCodeColumnLength)
+	}
+	if cfg.Charset == "" {
+		return nil, errors.New("SHORT_CODE_CHARSET 不能为空")
+	}
+	if cfg.MinLength < 1 || cfg.MaxLength > maxCodeColumnLength || cfg.MinLength > cfg.MaxLength {
+		return nil, fmt.Errorf("自定义短码长度范围 [%d, %d] 不合法，上限不能超过 %d",
+			cfg.MinLength, cfg.MaxLength, maxCodeColumnLength)
+	}
+	if cfg.MaxRetries < 1 {
+		return nil, errors.New("SHORT_CODE_MAX_RETRIES 必须大于 0")
+	}
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("CUSTOM_CODE_PATTERN 不是合法的正则: %w", err)
+	}
+
+	p := &CodePolicy{
+		length:     cfg.Length,
+		charset:    cfg.Charset,
+		minLength:  cfg.MinLength,
+		maxLength:  cfg.MaxLength,
+		pattern:    pattern,
+		maxRetries: cfg.MaxRetries,
+		reserved:   make(map[string]struct{}),
+	}
+	p.Reserve(defaultReservedCodes...)
+	p.Reserve(cfg.Reserved...)
+	return p, nil
+}
+
+// Reserve 添加保留字
+func (p *CodePolicy) Reserve(words ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			p.reserved[w] = struct{}{}
+		}
+	}
+}
+
+// IsReserved 判断短码是否为保留字（大小写不敏感）
+func (p *CodePolicy) IsReserved(code string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.reserved[strings.ToLower(code)]
+	return ok
+}
+
+// Validate 校验自定义短码
+func (p *CodePolicy) Validate(code string) error {
+	if n := len(code); n < p.minLength || n > p.maxLength {
+		return fmt.Errorf("%w: 长度必须在 %d 到 %d 之间", ErrInvalidCode, p.minLength, p.maxLength)
+	}
+	if !p.pattern.MatchString(code) {
+		return fmt.Errorf("%w: 必须匹配 %s", ErrInvalidCode, p.pattern)
+	}
+	if p.IsReserved(code) {
+		return fmt.Errorf("%w: %s 是保留字", ErrInvalidCode, code)
+	}
+	return nil
+}
+
+// Generate 生成一个不是保留字的随机短码
+// 保留字在短码空间中占比极小，只有配置异常（短码空间几乎被保留字占满）时才会返回 false
+func (p *CodePolicy) Generate() (string, bool) {
+	for i := 0; i < p.maxRetries; i++ {
+		if code := randomString(p.charset, p.length); !p.IsReserved(code) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// ReserveCodes 将路由前缀等加入保留字，不能再作为自定义短码使用
+func (s *Service) ReserveCodes(words ...string) {
+	s.codes.Reserve(words...)
+}
+
+// IsReservedCode 判断短码是否为保留字
+func (s *Service) IsReservedCode(code string) bool {
+	return s.codes.IsReserved(code)
+}
+
+// createWithCode 生成不冲突的短码并创建短链接
+// 自定义短码冲突时返回 CodeTakenError；随机短码冲突时重新生成，
+// 超过重试次数后返回 ErrCodeExhausted，通常意味着短码长度需要调大
+func (s *Service) createWithCode(ctx context.Context, domain string, create func(code string) error, customCode string) (string, error) {
+	if customCode != "" {
+		err := create(customCode)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return "", &CodeTakenError{Code: customCode, Suggestion: s.suggestCode(ctx, domain, customCode)}
+		}
+		return customCode, err
+	}
+
+	for i := 0; i < s.codes.maxRetries; i++ {
+		code, ok := s.codes.Generate()
+		if !ok {
+			break
+		}
+		err := create(code)
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return code, err
+		}
+		codeCollisionsTotal.Inc()
+	}
+	return "", ErrCodeExhausted
+}
+
+// suggestCode 为域名下已被占用的自定义短码找一个可用的候选：保留前缀，末尾追加随机字符
+func (s *Service) suggestCode(ctx context.Context, domain, code string) string {
+	const suffixLength = 2
+	if s.codes.maxLength <= suffixLength {
+		return ""
+	}
+	base := code
+	if len(base) > s.codes.maxLength-suffixLength {
+		base = base[:s.codes.maxLength-suffixLength]
+	}
+
+	for i := 0; i < suggestionAttempts; i++ {
+		candidate := base + randomString(s.codes.charset, suffixLength)
+		if s.codes.Validate(candidate) != nil {
+			continue
+		}
+		taken, err := s.repo.CodeExists(ctx, domain, candidate)
+		if err != nil {
+			return ""
+		}
+		if !taken {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// randomString 使用 crypto/rand 从 charset 中随机取 n 个字符
+func randomString(charset string, n int) string {
+	result := make([]byte, n)
+	for i := range result {
+		idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		result[i] = charset[idx.Int64()]
+	}
+	return string(result)
+}
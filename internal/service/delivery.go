@@ -0,0 +1,325 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+)
+
+// Webhook 推送请求头
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // t=<Unix 时间戳>,v1=<HMAC-SHA256 十六进制>
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery" // 事件 ID，重试和重新投递时不变，接收方可据此去重
+)
+
+const (
+	webhookUserAgent      = "saas-shortener-webhook/1.0"
+	webhookPurgeInterval  = time.Hour
+	maxWebhookErrorLength = 512  // 保存的错误信息最大长度
+	maxWebhookResponse    = 1024 // 读取的响应体最大长度（只用于错误信息）
+)
+
+var webhookDeliveries = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook 投递次数",
+	},
+	[]string{"result"}, // delivered / retry / dead
+)
+
+// SignWebhookPayload 计算推送签名头的值：t=<时间戳>,v1=HMAC-SHA256(secret, "<时间戳>.<请求体>")
+// 接收方用同样的方式计算并比较 v1，同时检查时间戳防止重放
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDispatcher 后台投递 Webhook 事件
+// 事件由 Service 写入投递队列表，dispatcher 定期领取到期的记录并发投递：
+// 1. 领取时为记录加租约（推迟 next_attempt_at），多副本部署不会重复投递，进程崩溃后租约到期自动重试
+// 2. 返回 2xx 视为成功；其他情况按 RetryBase * 2^(n-1) 退避重试，用完 MaxAttempts 次后进入 dead 状态
+// 3. 每小时清理一次超过保留时间的已完成记录
+type WebhookDispatcher struct {
+	repo   repository.WebhookStore
+	client *http.Client
+	cfg    config.WebhookConfig
+	logger *zap.Logger
+	lease  time.Duration
+
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+	lastPurge time.Time
+}
+
+// NewWebhookDispatcher 创建 Webhook 投递器，需调用 Start 启动
+// client 为 nil 时使用只允许访问公网地址、不跟随重定向的默认客户端
+func NewWebhookDispatcher(repo repository.WebhookStore, client *http.Client, cfg config.WebhookConfig, logger *zap.Logger) *WebhookDispatcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.RetryBase <= 0 {
+		cfg.RetryBase = 30 * time.Second
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		cfg.RetryMax = cfg.RetryBase
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if client == nil {
+		client = &http.Client{
+			Transport: publicTransport(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	// 租约要覆盖一批投递全部完成的时间，否则慢的记录可能被其他副本重复领取
+	rounds := (cfg.BatchSize + cfg.Workers - 1) / cfg.Workers
+	return &WebhookDispatcher{
+		repo:   repo,
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+		lease:  cfg.Timeout*time.Duration(rounds) + time.Minute,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start 启动后台轮询
+func (d *WebhookDispatcher) Start() {
+	go d.run()
+}
+
+// Shutdown 停止轮询，等待正在进行的投递完成
+// 未完成的记录在租约到期后由其他副本（或重启后的进程）继续投递
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), errors.New("Webhook 投递未能全部完成"))
+	}
+}
+
+func (d *WebhookDispatcher) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// 领满一批说明还有积压，继续领取直到清空
+		for d.dispatch(context.Background()) >= d.cfg.BatchSize {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+		}
+		d.purge(context.Background())
+	}
+}
+
+// dispatch 领取一批到期的投递并发送，返回领取的条数
+func (d *WebhookDispatcher) dispatch(ctx context.Context) int {
+	deliveries, err := d.repo.ClaimWebhookDeliveries(ctx, time.Now(), d.lease, d.cfg.BatchSize)
+	if err != nil {
+		d.logger.Error("领取 Webhook 投递失败", zap.Error(err))
+		return 0
+	}
+	if len(deliveries) == 0 {
+		return 0
+	}
+
+	ids := make([]uuid.UUID, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.WebhookID)
+	}
+	webhooks, err := d.repo.GetWebhooks(ctx, ids)
+	if err != nil {
+		// 租约到期后会重新领取
+		d.logger.Error("查询 Webhook 失败", zap.Error(err))
+		return 0
+	}
+	byID := make(map[uuid.UUID]*model.Webhook, len(webhooks))
+	for i := range webhooks {
+		byID[webhooks[i].ID] = &webhooks[i]
+	}
+
+	sem := make(chan struct{}, d.cfg.Workers)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		webhook, ok := byID[deliveries[i].WebhookID]
+		if !ok {
+			continue // Webhook 已删除，投递记录随之删除
+		}
+		if !webhook.IsActive {
+			continue // 领取之后被停用：暂停发送，租约到期后重新启用的 Webhook 会继续投递
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *model.WebhookDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			d.deliver(ctx, webhook, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// deliver 投递一次并保存结果
+func (d *WebhookDispatcher) deliver(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) {
+	now := time.Now()
+	status, err := d.send(ctx, webhook, delivery, now)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	result := model.DeliveryDelivered
+	if err == nil {
+		delivery.Status = model.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = truncateError(err.Error())
+		if delivery.Attempts >= d.cfg.MaxAttempts {
+			delivery.Status = model.DeliveryDead
+			result = model.DeliveryDead
+		} else {
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+			result = "retry"
+		}
+	}
+	webhookDeliveries.WithLabelValues(result).Inc()
+
+	if err := d.repo.SaveWebhookAttempt(ctx, delivery); err != nil {
+		d.logger.Error("保存 Webhook 投递结果失败",
+			zap.String("delivery_id", delivery.ID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+	if result == model.DeliveryDead {
+		d.logger.Warn("Webhook 投递失败次数已达上限",
+			zap.String("tenant_id", delivery.TenantID.String()),
+			zap.String("webhook_id", webhook.ID.String()),
+			zap.String("delivery_id", delivery.ID.String()),
+			zap.String("error", delivery.LastError),
+		)
+	}
+}
+
+// send 发送推送请求，返回响应状态码（请求失败时为 0）；非 2xx 响应视为失败
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.EventID.String())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(snippet) > 0 {
+			return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, snippet)
+		}
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff 第 attempts 次失败后的等待时间：RetryBase * 2^(attempts-1)，不超过 RetryMax
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.RetryBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.cfg.RetryMax {
+			return d.cfg.RetryMax
+		}
+	}
+	return wait
+}
+
+// purge 每隔 webhookPurgeInterval 清理一次超过保留时间的已完成记录
+func (d *WebhookDispatcher) purge(ctx context.Context) {
+	if d.cfg.Retention <= 0 || time.Since(d.lastPurge) < webhookPurgeInterval {
+		return
+	}
+	d.lastPurge = time.Now()
+
+	n, err := d.repo.PurgeWebhookDeliveries(ctx, d.lastPurge.Add(-d.cfg.Retention))
+	if err != nil {
+		d.logger.Error("清理 Webhook 投递记录失败", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		d.logger.Info("已清理过期的 Webhook 投递记录", zap.Int64("count", n))
+	}
+}
+
+func truncateError(msg string) string {
+	if len(msg) <= maxWebhookErrorLength {
+		return msg
+	}
+	// 去掉被截断的多字节字符
+	return strings.ToValidUTF8(msg[:maxWebhookErrorLength], "") + "…"
+}
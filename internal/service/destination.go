@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+var ErrInvalidRedirect = errors.New("重定向选项不合法")
+
+// maxUTMValueLength 单个 UTM 参数的最大长度（字符数）
+const maxUTMValueLength = 255
+
+// normalizeRedirectStatus 校验重定向状态码，0 表示默认的 302
+func normalizeRedirectStatus(status int) (int, error) {
+	if status == 0 {
+		return http.StatusFound, nil
+	}
+	if !slices.Contains(model.RedirectStatuses, status) {
+		return 0, fmt.Errorf("%w: redirect_status 只能是 301、302、307 或 308", ErrInvalidRedirect)
+	}
+	return status, nil
+}
+
+// normalizeUTM 去除 UTM 参数首尾空白并校验长度，nil 表示不设置
+func normalizeUTM(p *model.UTMParams) (model.UTMParams, error) {
+	if p == nil {
+		return model.UTMParams{}, nil
+	}
+	out := *p
+	for _, v := range []*string{&out.Source, &out.Medium, &out.Campaign, &out.Term, &out.Content} {
+		*v = strings.TrimSpace(*v)
+		if utf8.RuneCountInString(*v) > maxUTMValueLength {
+			return model.UTMParams{}, fmt.Errorf("%w: UTM 参数最长 %d 个字符", ErrInvalidRedirect, maxUTMValueLength)
+		}
+	}
+	return out, nil
+}
+
+// buildDestination 按短链接的重定向选项生成最终的跳转地址
+// 查询参数的优先级：访问时转发的参数 > 目标地址中已有的参数 > 短链接配置的 UTM 参数；
+// 路径后缀追加在目标地址的路径之后，不会改变目标地址的域名
+func buildDestination(target string, u *model.ShortURL, req *RedirectRequest) (string, error) {
+	forwardQuery := u.ForwardQuery && len(req.Query) > 0
+	forwardPath := u.ForwardPath && req.Suffix != ""
+	if !forwardQuery && !forwardPath && u.UTM.IsZero() {
+		return target, nil
+	}
+
+	dest, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	if forwardPath {
+		dest.Path = strings.TrimSuffix(dest.Path, "/") + req.Suffix
+		dest.RawPath = ""
+	}
+
+	query := dest.Query()
+	changed := false
+	for name, value := range u.UTM.Values() {
+		if !query.Has(name) {
+			query.Set(name, value)
+			changed = true
+		}
+	}
+	if forwardQuery {
+		for name, values := range req.Query {
+			query[name] = values
+		}
+		changed = true
+	}
+	if changed {
+		dest.RawQuery = query.Encode()
+	}
+	return dest.String(), nil
+}
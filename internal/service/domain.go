@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var (
+	ErrInvalidDomain      = errors.New("域名不合法")
+	ErrDomainNotFound     = errors.New("域名不存在")
+	ErrDomainExists       = errors.New("域名已添加")
+	ErrDomainTaken        = errors.New("域名已被其他租户验证")
+	ErrDomainLimit        = errors.New("自定义域名数量已达上限")
+	ErrDomainInUse        = errors.New("域名下仍有短链接，不能删除")
+	ErrVerificationFailed = errors.New("域名所有权验证失败")
+)
+
+// 所有权验证：在 _shortener-verification.<域名> 添加 TXT 记录，
+// 或者让 http://<域名>/.well-known/shortener-verification.txt 返回验证令牌
+const (
+	domainTXTPrefix      = "_shortener-verification."
+	domainTXTValuePrefix = "shortener-verification="
+	domainHTTPPath       = "/.well-known/shortener-verification.txt"
+	maxDomainHTTPBody    = 1024
+)
+
+// hostnameLabel 域名中的一段（国际化域名需要使用 xn-- 开头的 Punycode 形式）
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TXTResolver DNS TXT 记录查询，*net.Resolver 满足该接口，测试中可以替换为固定结果
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DomainVerifier 验证租户对自定义域名的所有权
+type DomainVerifier struct {
+	resolver TXTResolver
+	client   *http.Client
+}
+
+// NewDomainVerifier 创建域名验证器
+// resolver 为 nil 时使用系统 DNS；client 为 nil 时使用只允许访问公网地址的默认客户端，
+// 避免租户把域名解析到内网地址，借验证请求探测集群内部服务
+func NewDomainVerifier(resolver TXTResolver, client *http.Client) *DomainVerifier {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if client == nil {
+		transport := publicTransport()
+		transport.DisableKeepAlives = true
+		client = &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("重定向次数过多")
+				}
+				return nil
+			},
+		}
+	}
+	return &DomainVerifier{resolver: resolver, client: client}
+}
+
+// publicTransport 只允许连接公网地址的 Transport，用于访问租户提供的地址（域名验证、Webhook 推送）
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{Control: rejectPrivateAddress}
+	return &http.Transport{DialContext: dialer.DialContext}
+}
+
+// rejectPrivateAddress 拒绝连接回环、内网、链路本地等非公网地址
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("不允许访问非公网地址 %s", host)
+	}
+	return nil
+}
+
+// checkDNS 查找内容为 shortener-verification=<令牌> 的 TXT 记录
+func (v *DomainVerifier) checkDNS(ctx context.Context, d *model.Domain) error {
+	name := domainTXTPrefix + d.Hostname
+	records, err := v.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf("查询 %s 的 TXT 记录失败: %v", name, err)
+	}
+	want := domainTXTValuePrefix + d.VerificationToken
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s 没有内容为 %s 的 TXT 记录", name, want)
+}
+
+// checkHTTP 请求 http://<域名>/.well-known/shortener-verification.txt，响应内容应为验证令牌
+func (v *DomainVerifier) checkHTTP(ctx context.Context, d *model.Domain) error {
+	target := "http://" + d.Hostname + domainHTTPPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %d", target, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDomainHTTPBody))
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %v", target, err)
+	}
+	if strings.TrimSpace(string(body)) != d.VerificationToken {
+		return fmt.Errorf("%s 的内容与验证令牌不一致", target)
+	}
+	return nil
+}
+
+// ==================== 自定义域名管理 ====================
+
+// CreateDomain 为租户添加自定义域名，返回所有权验证说明
+func (s *Service) CreateDomain(ctx context.Context, tenantID uuid.UUID, req *model.CreateDomainRequest) (_ *model.DomainResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateDomain")
+	defer tracing.End(span, &err)
+
+	hostname, err := normalizeHostname(req.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	if hostname == s.defaultHost {
+		return nil, fmt.Errorf("%w: %s 是服务的默认域名", ErrInvalidDomain, hostname)
+	}
+
+	count, err := s.repo.CountDomainsByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询域名数量失败: %w", err)
+	}
+	if count >= int64(s.cfg.Domains.MaxPerTenant) {
+		return nil, fmt.Errorf("%w: 最多 %d 个", ErrDomainLimit, s.cfg.Domains.MaxPerTenant)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("生成验证令牌失败: %w", err)
+	}
+	domain := &model.Domain{
+		ID:                uuid.New(),
+		TenantID:          tenantID,
+		Hostname:          hostname,
+		VerificationToken: hex.EncodeToString(token),
+	}
+	if err := s.repo.CreateDomain(ctx, domain); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrDomainExists
+		}
+		return nil, fmt.Errorf("添加域名失败: %w", err)
+	}
+
+	s.logger.Info("添加自定义域名",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("hostname", hostname),
+	)
+	return toDomainResponse(domain), nil
+}
+
+// ListDomains 查询租户的全部自定义域名
+func (s *Service) ListDomains(ctx context.Context, tenantID uuid.UUID) (_ []model.DomainResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListDomains")
+	defer tracing.End(span, &err)
+
+	domains, err := s.repo.ListDomainsByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询域名失败: %w", err)
+	}
+	resp := make([]model.DomainResponse, len(domains))
+	for i := range domains {
+		resp[i] = *toDomainResponse(&domains[i])
+	}
+	return resp, nil
+}
+
+// VerifyDomain 验证租户对域名的所有权
+// method 为 dns / http 时只使用对应方式，为空时依次尝试 DNS 和 HTTP；已验证的域名直接返回
+func (s *Service) VerifyDomain(ctx context.Context, tenantID, id uuid.UUID, method string) (_ *model.DomainResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.VerifyDomain")
+	defer tracing.End(span, &err)
+
+	var checks []func(context.Context, *model.Domain) error
+	switch method {
+	case "":
+		checks = append(checks, s.verifier.checkDNS, s.verifier.checkHTTP)
+	case model.DomainVerifyDNS:
+		checks = append(checks, s.verifier.checkDNS)
+	case model.DomainVerifyHTTP:
+		checks = append(checks, s.verifier.checkHTTP)
+	default:
+		return nil, fmt.Errorf("%w: method 只能是 dns 或 http", ErrInvalidDomain)
+	}
+
+	domain, err := s.repo.GetDomainByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("查询域名失败: %w", err)
+	}
+	if domain.IsVerified() {
+		return toDomainResponse(domain), nil
+	}
+
+	var failures []string
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, s.cfg.Domains.VerifyTimeout)
+		err = check(checkCtx, domain)
+		cancel()
+		if err == nil {
+			break
+		}
+		failures = append(failures, err.Error())
+	}
+	if len(failures) == len(checks) {
+		return nil, fmt.Errorf("%w: %s", ErrVerificationFailed, strings.Join(failures, "；"))
+	}
+
+	domain, err = s.repo.VerifyDomain(ctx, tenantID, id, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrDomainTaken
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("更新域名状态失败: %w", err)
+	}
+
+	s.logger.Info("自定义域名验证通过",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("hostname", domain.Hostname),
+	)
+	return toDomainResponse(domain), nil
+}
+
+// DeleteDomain 删除租户的自定义域名，域名下仍有短链接时拒绝删除
+func (s *Service) DeleteDomain(ctx context.Context, tenantID, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteDomain")
+	defer tracing.End(span, &err)
+
+	domain, err := s.repo.GetDomainByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrDomainNotFound
+		}
+		return fmt.Errorf("查询域名失败: %w", err)
+	}
+	count, err := s.repo.CountURLsByDomain(ctx, tenantID, domain.Hostname)
+	if err != nil {
+		return fmt.Errorf("查询短链接数量失败: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: 还有 %d 个短链接", ErrDomainInUse, count)
+	}
+
+	if err := s.repo.DeleteDomain(ctx, tenantID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrDomainNotFound
+		}
+		return fmt.Errorf("删除域名失败: %w", err)
+	}
+	return nil
+}
+
+// ==================== 域名解析 ====================
+
+// linkDomain 校验创建短链接时指定的域名：必须是租户自己已验证的域名，为空表示默认域名
+func (s *Service) linkDomain(ctx context.Context, tenantID uuid.UUID, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	hostname, err := normalizeHostname(name)
+	if err != nil {
+		return "", err
+	}
+	domain, err := s.repo.GetDomainByHostname(ctx, tenantID, hostname)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", fmt.Errorf("%w: 没有添加域名 %s", ErrInvalidDomain, hostname)
+		}
+		return "", fmt.Errorf("查询域名失败: %w", err)
+	}
+	if !domain.IsVerified() {
+		return "", fmt.Errorf("%w: %s 尚未通过所有权验证", ErrInvalidDomain, hostname)
+	}
+	return hostname, nil
+}
+
+// requestDomain 根据请求的 Host 确定短链接所属的域名
+// 已验证的自定义域名返回该域名；默认域名以及未登记的 Host（如用 IP 或集群内部地址访问）按默认域名处理
+func (s *Service) requestDomain(ctx context.Context, host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == s.defaultHost || net.ParseIP(host) != nil {
+		return "", nil
+	}
+
+	verified, err := s.repo.IsVerifiedDomain(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("解析域名失败: %w", err)
+	}
+	if !verified {
+		return "", nil
+	}
+	return host, nil
+}
+
+// shortLink 短链接的完整地址
+// 自定义域名固定使用 https；默认域名使用 PUBLIC_BASE_URL，未配置时使用 fallback，
+// fallback 也为空时返回相对路径 /<code>
+func (s *Service) shortLink(u *model.ShortURL, fallback string) string {
+	base := fallback
+	switch {
+	case u.Domain != "":
+		base = "https://" + u.Domain
+	case s.cfg.Server.PublicBaseURL != "":
+		base = s.cfg.Server.PublicBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + u.Code
+}
+
+// normalizeHostname 校验并规范化域名：小写、去掉末尾的点，不允许端口和 IP 地址
+func normalizeHostname(name string) (string, error) {
+	hostname := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if hostname == "" || len(hostname) > 253 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDomain, name)
+	}
+	if net.ParseIP(hostname) != nil {
+		return "", fmt.Errorf("%w: 不能使用 IP 地址", ErrInvalidDomain)
+	}
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("%w: %q 不是完整的域名", ErrInvalidDomain, name)
+	}
+	for _, label := range labels {
+		if !hostnameLabel.MatchString(label) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDomain, name)
+		}
+	}
+	return hostname, nil
+}
+
+// publicHostname 从 PUBLIC_BASE_URL 中取出默认域名，未配置时返回空字符串
+func publicHostname(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func toDomainResponse(d *model.Domain) *model.DomainResponse {
+	resp := &model.DomainResponse{
+		ID:         d.ID,
+		Hostname:   d.Hostname,
+		Verified:   d.IsVerified(),
+		VerifiedAt: d.VerifiedAt,
+		CreatedAt:  d.CreatedAt,
+	}
+	if !d.IsVerified() {
+		resp.Verification = &model.DomainVerification{
+			TXTName:  domainTXTPrefix + d.Hostname,
+			TXTValue: domainTXTValuePrefix + d.VerificationToken,
+			HTTPURL:  "http://" + d.Hostname + domainHTTPPath,
+			HTTPBody: d.VerificationToken,
+		}
+	}
+	return resp
+}
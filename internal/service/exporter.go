@@ -0,0 +1,212 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var ErrInvalidExportFormat = errors.New("导出格式只能是 csv 或 jsonl")
+
+// ExportFormat 导出文件格式
+type ExportFormat string
+
+const (
+	ExportCSV       ExportFormat = "csv"
+	ExportJSONLines ExportFormat = "jsonl"
+)
+
+// ParseExportFormat 解析导出格式，ndjson 是 jsonl 的别名，为空时默认 CSV
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch format {
+	case "", "csv":
+		return ExportCSV, nil
+	case "jsonl", "ndjson":
+		return ExportJSONLines, nil
+	}
+	return "", ErrInvalidExportFormat
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format ExportFormat
+	Gzip   bool
+}
+
+// Export 一次待执行的导出
+// 参数在创建时校验，调用 WriteTo 时才开始读取数据库，便于 Handler 在写出响应头之前处理参数错误
+type Export struct {
+	Filename    string // 建议的下载文件名
+	ContentType string
+
+	name  string
+	gzip  bool
+	write func(ctx context.Context, w io.Writer) error
+}
+
+// WriteTo 将导出数据流式写入 w
+// 写到一半失败时不会关闭 gzip 流，客户端解压时能发现文件不完整
+func (e *Export) WriteTo(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.Export."+e.name)
+	defer tracing.End(span, &err)
+
+	if !e.gzip {
+		return e.write(ctx, w)
+	}
+	gz := gzip.NewWriter(w)
+	if err := e.write(ctx, gz); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ==================== 导出短链接 ====================
+
+var urlExportHeader = []string{
+	"id", "code", "domain", "short_url", "original_url", "title", "folder", "tags", "clicks", "is_active",
+	"max_clicks", "created_at", "expires_at", "not_before",
+}
+
+// ExportShortURLs 导出租户的全部短链接
+func (s *Service) ExportShortURLs(ctx context.Context, tenantID uuid.UUID, opts ExportOptions) (*Export, error) {
+	write := func(ctx context.Context, w io.Writer) error {
+		stream := func(fn func([]model.ShortURL) error) error {
+			return s.repo.StreamShortURLsByTenant(ctx, tenantID, max(s.cfg.Export.BatchSize, 1), fn)
+		}
+		return writeExport(w, opts.Format, urlExportHeader, stream,
+			func(u *model.ShortURL) any { return s.toShortURLResponse(u) },
+			func(u *model.ShortURL) []string {
+				r := s.toShortURLResponse(u)
+				return []string{
+					r.ID.String(), r.Code, r.Domain, r.ShortURL, r.OriginalURL,
+					r.Title, r.Folder, strings.Join(r.Tags, ","),
+					strconv.FormatInt(r.Clicks, 10), strconv.FormatBool(r.IsActive),
+					strconv.FormatInt(r.MaxClicks, 10), formatExportTime(&r.CreatedAt),
+					formatExportTime(r.ExpiresAt), formatExportTime(r.NotBefore),
+				}
+			})
+	}
+	return newExport("urls", opts, write), nil
+}
+
+// ==================== 导出点击事件 ====================
+
+var clickExportHeader = []string{"id", "short_url_id", "ip", "user_agent", "browser", "os", "device", "is_bot", "country", "region", "city", "referer", "rule_id", "variant_id", "created_at"}
+
+// ExportClicks 导出租户在 [from, to) 内的点击事件
+// to 默认为当前时间，from 默认为 to 之前的 defaultAnalyticsRange；起点按套餐的分析数据保留天数截断
+func (s *Service) ExportClicks(ctx context.Context, tenantID uuid.UUID, from, to *time.Time, opts ExportOptions) (_ *Export, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ExportClicks")
+	defer tracing.End(span, &err)
+
+	end := time.Now().UTC()
+	if to != nil {
+		end = to.UTC()
+	}
+	start := end.Add(-defaultAnalyticsRange)
+	if from != nil {
+		start = from.UTC()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from 必须早于 to", ErrInvalidTimeRange)
+	}
+	start, err = s.clampToRetention(ctx, tenantID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	write := func(ctx context.Context, w io.Writer) error {
+		stream := func(fn func([]model.ClickEvent) error) error {
+			return s.repo.StreamClicksByTenant(ctx, tenantID, start, end, max(s.cfg.Export.BatchSize, 1), fn)
+		}
+		return writeExport(w, opts.Format, clickExportHeader, stream,
+			func(e *model.ClickEvent) any { return e },
+			func(e *model.ClickEvent) []string {
+				return []string{
+					e.ID.String(), e.ShortURLID.String(), e.IP, e.UserAgent, e.Browser, e.OS, e.Device, strconv.FormatBool(e.IsBot),
+					e.Country, e.Region, e.City, e.Referer, e.RuleID, e.VariantID,
+					formatExportTime(&e.CreatedAt),
+				}
+			})
+	}
+	return newExport("clicks", opts, write), nil
+}
+
+// ==================== 辅助函数 ====================
+
+func newExport(name string, opts ExportOptions, write func(ctx context.Context, w io.Writer) error) *Export {
+	e := &Export{
+		Filename: fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), opts.Format),
+		name:     name,
+		gzip:     opts.Gzip,
+		write:    write,
+	}
+
+	switch opts.Format {
+	case ExportJSONLines:
+		e.ContentType = "application/x-ndjson"
+	default:
+		e.ContentType = "text/csv; charset=utf-8"
+	}
+	if opts.Gzip {
+		e.Filename += ".gz"
+		e.ContentType = "application/gzip"
+	}
+	return e
+}
+
+// writeExport 按格式逐批编码记录
+// CSV 每批写完后 Flush，JSON Lines 每条记录直接写出，内存占用只与批大小有关
+func writeExport[T any](w io.Writer, format ExportFormat, header []string,
+	stream func(fn func([]T) error) error, jsonRecord func(*T) any, csvRecord func(*T) []string) error {
+	if format == ExportJSONLines {
+		enc := json.NewEncoder(w)
+		return stream(func(batch []T) error {
+			for i := range batch {
+				if err := enc.Encode(jsonRecord(&batch[i])); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	err := stream(func(batch []T) error {
+		for i := range batch {
+			if err := cw.Write(csvRecord(&batch[i])); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatExportTime 导出文件中的时间统一为 RFC 3339（UTC），空值输出空字符串
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+// maxImportLineBytes JSON Lines 单行的最大长度
+const maxImportLineBytes = 64 << 10
+
+// ImportSource 导入数据源，逐行读取
+// 读完返回 io.EOF；单行格式错误返回 *RowError，调用方记录后继续读取；
+// 其他错误（如请求体超过大小限制）表示数据流已不可用，调用方应停止导入
+type ImportSource interface {
+	Next() (line int, req *model.CreateShortURLRequest, err error)
+}
+
+// RowError 导入文件中某一行格式错误
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("第 %d 行: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ==================== CSV ====================
+
+// csvColumns CSV 导入支持的列，没有表头时按此顺序解析
+var csvColumns = []string{"url", "custom_code", "expires_at", "tags"}
+
+type csvImportSource struct {
+	r       *csv.Reader
+	columns map[string]int // 列名 -> 下标
+	started bool
+}
+
+// NewCSVImportSource 创建 CSV 数据源
+// 第一行包含 "url" 列时视为表头，按列名取值（列顺序任意，还支持 title、folder、domain 列，未知列忽略）；
+// 否则按 url,custom_code,expires_at,tags 的顺序解析。expires_at 为 RFC3339 格式，多个标签用逗号或分号分隔
+func NewCSVImportSource(r io.Reader) ImportSource {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // 允许省略末尾的可选列
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	columns := make(map[string]int, len(csvColumns))
+	for i, name := range csvColumns {
+		columns[name] = i
+	}
+	return &csvImportSource{r: cr, columns: columns}
+}
+
+func (s *csvImportSource) Next() (int, *model.CreateShortURLRequest, error) {
+	for {
+		record, err := s.r.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return parseErr.StartLine, nil, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+			}
+			return 0, nil, err
+		}
+		line, _ := s.r.FieldPos(0)
+
+		if !s.started {
+			s.started = true
+			if isCSVHeader(record) {
+				s.parseHeader(record)
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue // 空行
+		}
+
+		req, err := s.parseRecord(record)
+		if err != nil {
+			return line, nil, &RowError{Line: line, Err: err}
+		}
+		return line, req, nil
+	}
+}
+
+// isCSVHeader 第一行中有一列为 "url" 时视为表头（合法的 URL 不会是这个值）
+func isCSVHeader(record []string) bool {
+	for _, name := range record {
+		if csvColumnName(name) == "url" {
+			return true
+		}
+	}
+	return false
+}
+
+// csvColumnName 规范化表头列名，去掉 Excel 导出文件开头的 BOM
+func csvColumnName(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+func (s *csvImportSource) parseHeader(header []string) {
+	s.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		name = csvColumnName(name)
+		if _, ok := s.columns[name]; !ok {
+			s.columns[name] = i
+		}
+	}
+}
+
+// field 按列名取值，列不存在或该行缺少这一列时返回空字符串
+func (s *csvImportSource) field(record []string, name string) string {
+	i, ok := s.columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func (s *csvImportSource) parseRecord(record []string) (*model.CreateShortURLRequest, error) {
+	req := &model.CreateShortURLRequest{
+		URL:        s.field(record, "url"),
+		CustomCode: s.field(record, "custom_code"),
+		Title:      s.field(record, "title"),
+		Folder:     s.field(record, "folder"),
+		Domain:     s.field(record, "domain"),
+	}
+	if v := s.field(record, "expires_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("expires_at 必须是 RFC3339 格式: %q", v)
+		}
+		req.ExpiresAt = &t
+	}
+	// 多个标签用逗号或分号分隔，如 "summer;promo"
+	if v := s.field(record, "tags"); v != "" {
+		req.Tags = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' })
+	}
+	return req, nil
+}
+
+// ==================== JSON Lines ====================
+
+type jsonLinesImportSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJSONLinesImportSource 创建 JSON Lines 数据源，每行一个 CreateShortURLRequest 对象
+func NewJSONLinesImportSource(r io.Reader) ImportSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLineBytes)
+	return &jsonLinesImportSource{scanner: scanner}
+}
+
+func (s *jsonLinesImportSource) Next() (int, *model.CreateShortURLRequest, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := bytes.TrimSpace(s.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var req model.CreateShortURLRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return s.line, nil, &RowError{Line: s.line, Err: err}
+		}
+		return s.line, &req, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			// 超长行之后的数据无法可靠地按行切分，只能停止导入
+			return s.line + 1, nil, fmt.Errorf("第 %d 行超过 %d 字节", s.line+1, maxImportLineBytes)
+		}
+		return s.line, nil, err
+	}
+	return s.line, nil, io.EOF
+}
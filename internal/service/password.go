@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var (
+	ErrPasswordRequired = errors.New("短链接需要密码")
+	ErrWrongPassword    = errors.New("密码错误")
+	ErrTooManyAttempts  = errors.New("密码错误次数过多，请稍后重试")
+	ErrInvalidPassword  = errors.New("密码长度必须在 4 到 72 字节之间")
+)
+
+// 访问密码长度限制，bcrypt 只使用前 72 字节
+const (
+	minLinkPasswordBytes = 4
+	maxLinkPasswordBytes = 72
+)
+
+// LinkAccess 输入正确密码后签发的访问凭证，由 Handler 写入只对该短码路径生效的 Cookie
+type LinkAccess struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// UnlockShortURL 校验短链接的访问密码，通过后签发访问凭证
+// 同一 IP 对同一短码输错次数超过 LinkPassword.MaxAttempts 后，在窗口结束前直接拒绝，不再比对密码：
+// 比对前先原子地计入一次失败，并发的尝试不会都读到未超限的计数，密码正确时再清零；
+// 未生效、已过期或已达点击上限的链接与 Redirect 一样直接拒绝，不签发凭证
+func (s *Service) UnlockShortURL(ctx context.Context, host, code, password, ip string) (_ *LinkAccess, err error) {
+	ctx, span := tracing.Start(ctx, "Service.UnlockShortURL")
+	defer tracing.End(span, &err)
+
+	domain, err := s.requestDomain(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	shortURL, err := s.repo.GetShortURLByCode(ctx, domain, code)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	span.SetAttributes(tracing.AttrTenantID.String(shortURL.TenantID.String()))
+
+	now := time.Now()
+	if err := checkSchedule(shortURL, now); err != nil {
+		return nil, err
+	}
+	if err := s.checkClickLimit(ctx, shortURL); err != nil {
+		return nil, err
+	}
+	if !shortURL.IsProtected() {
+		return &LinkAccess{ExpiresAt: now}, nil
+	}
+
+	key := fmt.Sprintf("link-password:%s:%s", shortURL.LinkKey(), ip)
+	attempts, err := s.repo.RecordFailure(ctx, key, s.cfg.LinkPassword.AttemptWindow)
+	if err != nil {
+		return nil, fmt.Errorf("记录密码尝试次数失败: %w", err)
+	}
+	if attempts > int64(s.cfg.LinkPassword.MaxAttempts) {
+		return nil, ErrTooManyAttempts
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(shortURL.PasswordHash), []byte(password)) != nil {
+		return nil, ErrWrongPassword
+	}
+	if err := s.repo.ResetFailures(ctx, key); err != nil {
+		return nil, fmt.Errorf("清除密码错误次数失败: %w", err)
+	}
+
+	expiresAt := now.Add(s.cfg.LinkPassword.CookieTTL)
+	return &LinkAccess{
+		Token:     s.signLinkAccess(shortURL, expiresAt),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// signLinkAccess 签发访问凭证：<过期时间>.<HMAC(域名/短码, 过期时间, 密码哈希)>
+// 签名包含密码哈希，修改或取消密码后旧凭证自动失效
+func (s *Service) signLinkAccess(u *model.ShortURL, expiresAt time.Time) string {
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, s.linkSecret)
+	mac.Write([]byte(u.LinkKey() + "\n" + exp + "\n" + u.PasswordHash))
+	return exp + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyLinkAccess 校验访问凭证是否由本服务为该短链接签发且尚未过期
+func (s *Service) verifyLinkAccess(u *model.ShortURL, token string, now time.Time) bool {
+	exp, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !time.Unix(unix, 0).After(now) {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(s.signLinkAccess(u, time.Unix(unix, 0))))
+}
+
+// hashLinkPassword 校验访问密码长度并计算 bcrypt 哈希
+func hashLinkPassword(password string) (string, error) {
+	if len(password) < minLinkPasswordBytes || len(password) > maxLinkPasswordBytes {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("计算密码哈希失败: %w", err)
+	}
+	return string(hash), nil
+}
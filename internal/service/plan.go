@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+// defaultPlan 注册租户时未指定套餐使用的套餐
+const defaultPlan = "free"
+
+var (
+	planNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+	featurePattern  = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+)
+
+// ==================== 套餐管理（平台管理员） ====================
+
+// ListPlans 查询所有套餐
+func (s *Service) ListPlans(ctx context.Context) (_ []model.PlanResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListPlans")
+	defer tracing.End(span, &err)
+
+	plans, err := s.repo.ListPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询套餐失败: %w", err)
+	}
+
+	resp := make([]model.PlanResponse, len(plans))
+	for i := range plans {
+		resp[i] = *toPlanResponse(&plans[i])
+	}
+	return resp, nil
+}
+
+// GetPlan 查询单个套餐
+func (s *Service) GetPlan(ctx context.Context, name string) (_ *model.PlanResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPlan")
+	defer tracing.End(span, &err)
+
+	plan, err := s.getPlan(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return toPlanResponse(plan), nil
+}
+
+// CreatePlan 创建套餐
+func (s *Service) CreatePlan(ctx context.Context, req *model.CreatePlanRequest) (_ *model.PlanResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreatePlan")
+	defer tracing.End(span, &err)
+
+	if !planNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: 套餐名只能包含小写字母、数字、- 和 _", ErrInvalidPlan)
+	}
+	plan, err := planFromRequest(req.Name, &req.PlanRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreatePlan(ctx, plan); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrPlanExists
+		}
+		return nil, fmt.Errorf("创建套餐失败: %w", err)
+	}
+
+	s.logger.Info("套餐已创建", zap.String("plan", plan.Name))
+	return toPlanResponse(plan), nil
+}
+
+// UpdatePlan 修改套餐配额
+// 该套餐下所有租户的限流和 URL 上限随之更新；已超出新上限的数据保留，只是不能再新建
+func (s *Service) UpdatePlan(ctx context.Context, name string, req *model.PlanRequest) (_ *model.PlanResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdatePlan")
+	defer tracing.End(span, &err)
+
+	plan, err := planFromRequest(name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdatePlan(ctx, plan); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, fmt.Errorf("修改套餐失败: %w", err)
+	}
+
+	s.logger.Info("套餐已修改",
+		zap.String("plan", plan.Name),
+		zap.Int("rate_limit", plan.RateLimit),
+		zap.Int("max_urls", plan.MaxURLs),
+	)
+	return toPlanResponse(plan), nil
+}
+
+// DeletePlan 删除套餐，仍有租户使用的套餐不能删除
+func (s *Service) DeletePlan(ctx context.Context, name string) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeletePlan")
+	defer tracing.End(span, &err)
+
+	count, err := s.repo.CountTenantsByPlan(ctx, name)
+	if err != nil {
+		return fmt.Errorf("查询套餐使用情况失败: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w（%d 个租户）", ErrPlanInUse, count)
+	}
+
+	if err := s.repo.DeletePlan(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrPlanNotFound
+		}
+		return fmt.Errorf("删除套餐失败: %w", err)
+	}
+
+	s.logger.Info("套餐已删除", zap.String("plan", name))
+	return nil
+}
+
+// ==================== 租户变更套餐 ====================
+
+// ChangeTenantPlan 租户升级/降级套餐
+// 降级前检查当前用量：已有短链接数或本月点击数超过目标套餐配额时拒绝，
+// 避免降级后租户处于"超额"状态
+func (s *Service) ChangeTenantPlan(ctx context.Context, tenantID uuid.UUID, name string) (_ *model.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ChangeTenantPlan")
+	defer tracing.End(span, &err)
+
+	plan, err := s.getPlan(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := s.repo.CountURLsByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询短链接数量失败: %w", err)
+	}
+	if urls > int64(plan.MaxURLs) {
+		return nil, fmt.Errorf("%w: 当前有 %d 个短链接，%s 套餐最多 %d 个",
+			ErrPlanBelowUsage, urls, plan.Name, plan.MaxURLs)
+	}
+
+	if plan.MonthlyClickQuota > 0 {
+		clicks, err := s.repo.CountClicksByTenantSince(ctx, tenantID, monthStart(time.Now()))
+		if err != nil {
+			return nil, fmt.Errorf("查询本月点击数失败: %w", err)
+		}
+		if clicks > plan.MonthlyClickQuota {
+			return nil, fmt.Errorf("%w: 本月已有 %d 次点击，%s 套餐每月最多 %d 次",
+				ErrPlanBelowUsage, clicks, plan.Name, plan.MonthlyClickQuota)
+		}
+	}
+
+	tenant, err := s.repo.ChangeTenantPlan(ctx, tenantID, plan)
+	if err != nil {
+		return nil, fmt.Errorf("变更套餐失败: %w", err)
+	}
+
+	s.logger.Info("租户套餐已变更",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("plan", plan.Name),
+	)
+	return tenant, nil
+}
+
+// ==================== 辅助函数 ====================
+
+// getPlan 查询套餐，不存在时返回 ErrPlanNotFound
+func (s *Service) getPlan(ctx context.Context, name string) (*model.Plan, error) {
+	plan, err := s.repo.GetPlan(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrPlanNotFound, name)
+		}
+		return nil, fmt.Errorf("查询套餐失败: %w", err)
+	}
+	return plan, nil
+}
+
+// clampToRetention 按租户套餐的分析数据保留天数截断查询起点
+// 整个查询范围都早于保留期时返回 ErrInvalidTimeRange
+func (s *Service) clampToRetention(ctx context.Context, tenantID uuid.UUID, start, end time.Time) (time.Time, error) {
+	tenant, err := s.repo.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return start, fmt.Errorf("查询租户失败: %w", err)
+	}
+	plan, err := s.getPlan(ctx, tenant.Plan)
+	if err != nil {
+		return start, err
+	}
+	if plan.AnalyticsRetentionDays <= 0 {
+		return start, nil
+	}
+
+	earliest := time.Now().UTC().AddDate(0, 0, -plan.AnalyticsRetentionDays)
+	if !end.After(earliest) {
+		return start, fmt.Errorf("%w: %s 套餐只能查询最近 %d 天的数据",
+			ErrInvalidTimeRange, plan.Name, plan.AnalyticsRetentionDays)
+	}
+	if start.Before(earliest) {
+		return earliest, nil
+	}
+	return start, nil
+}
+
+// planFromRequest 校验请求参数并构造套餐
+func planFromRequest(name string, req *model.PlanRequest) (*model.Plan, error) {
+	features, err := normalizeFeatures(req.Features)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = name
+	}
+
+	return &model.Plan{
+		Name:                   name,
+		DisplayName:            displayName,
+		RateLimit:              req.RateLimit,
+		MaxURLs:                req.MaxURLs,
+		MonthlyClickQuota:      req.MonthlyClickQuota,
+		AllowCustomCode:        req.AllowCustomCode,
+		AnalyticsRetentionDays: req.AnalyticsRetentionDays,
+		Features:               strings.Join(features, ","),
+	}, nil
+}
+
+// normalizeFeatures 校验并去重功能开关
+func normalizeFeatures(features []string) ([]string, error) {
+	seen := make(map[string]bool, len(features))
+	var result []string
+	for _, f := range features {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !featurePattern.MatchString(f) {
+			return nil, fmt.Errorf("%w: 无效的功能开关 %q", ErrInvalidPlan, f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+func toPlanResponse(p *model.Plan) *model.PlanResponse {
+	features := p.FeatureList()
+	if features == nil {
+		features = []string{}
+	}
+	return &model.PlanResponse{
+		Name:                   p.Name,
+		DisplayName:            p.DisplayName,
+		RateLimit:              p.RateLimit,
+		MaxURLs:                p.MaxURLs,
+		MonthlyClickQuota:      p.MonthlyClickQuota,
+		AllowCustomCode:        p.AllowCustomCode,
+		AnalyticsRetentionDays: p.AnalyticsRetentionDays,
+		Features:               features,
+		CreatedAt:              p.CreatedAt,
+		UpdatedAt:              p.UpdatedAt,
+	}
+}
+
+// monthStart 返回 t 所在自然月的第一天零点（UTC），每月点击配额按 UTC 自然月计算
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/qr"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var ErrInvalidQROptions = errors.New("二维码参数错误")
+
+// 二维码绘制参数限制
+const (
+	minQRSize       = 64
+	defaultQRMargin = 4 // 二维码规范建议的静区宽度
+	maxQRMargin     = 16
+)
+
+// qrLevels 纠错等级参数与 go-qrcode 等级的对应关系
+var qrLevels = map[string]qr.Level{
+	"L": qr.LevelL,
+	"M": qr.LevelM,
+	"Q": qr.LevelQ,
+	"H": qr.LevelH,
+}
+
+// QRRequest 二维码查询参数，零值表示使用默认值
+type QRRequest struct {
+	Format     string // png（默认）/ svg
+	Size       int    // 图片宽高（像素），默认 QR_DEFAULT_SIZE
+	Margin     *int   // 静区宽度（模块数），默认 4；0 表示不留白
+	ECC        string // 纠错等级 L / M（默认）/ Q / H
+	Foreground string // 前景色，十六进制，默认 000000
+	Background string // 背景色，十六进制，默认 ffffff；透明背景使用 8 位格式，如 ffffff00
+}
+
+// QRCode 生成的二维码图片
+type QRCode struct {
+	Content     string // 二维码中编码的完整短链接
+	ContentType string
+	Data        []byte
+}
+
+// GetQRCode 生成租户短链接的二维码，编码内容为完整的短链接地址（见 shortLink），
+// 默认域名未配置 PUBLIC_BASE_URL 时使用 fallbackBaseURL
+// 结果按 短链接 + 绘制参数 + 编码内容 缓存，短链接修改或删除时由存储层清除
+func (s *Service) GetQRCode(ctx context.Context, tenantID, id uuid.UUID, fallbackBaseURL string, req *QRRequest) (_ *QRCode, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetQRCode")
+	defer tracing.End(span, &err)
+
+	opts, err := s.qrOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	shortURL, err := s.repo.GetShortURLByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, fmt.Errorf("查询短链接失败: %w", err)
+	}
+
+	link := s.shortLink(shortURL, fallbackBaseURL)
+	if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("短链接对外地址不合法: %q", link)
+	}
+	code := &QRCode{
+		Content:     link,
+		ContentType: "image/png",
+	}
+	if opts.Format == qr.FormatSVG {
+		code.ContentType = "image/svg+xml"
+	}
+
+	key := qrCacheKey(opts, code.Content)
+	if data, err := s.repo.GetQRCode(ctx, shortURL.LinkKey(), key); err == nil {
+		code.Data = data
+		return code, nil
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		s.logger.Warn("读取二维码缓存失败", zap.String("link", shortURL.LinkKey()), zap.Error(err))
+	}
+
+	code.Data, err = qr.Render(code.Content, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQROptions, err)
+	}
+	if err := s.repo.SetQRCode(ctx, shortURL.LinkKey(), key, code.Data, s.cfg.QR.CacheTTL); err != nil {
+		s.logger.Warn("写入二维码缓存失败", zap.String("link", shortURL.LinkKey()), zap.Error(err))
+	}
+	return code, nil
+}
+
+// qrOptions 校验查询参数并填充默认值
+func (s *Service) qrOptions(req *QRRequest) (qr.Options, error) {
+	opts := qr.Options{
+		Format: strings.ToLower(req.Format),
+		Size:   req.Size,
+		Margin: defaultQRMargin,
+	}
+
+	switch opts.Format {
+	case "":
+		opts.Format = qr.FormatPNG
+	case qr.FormatPNG, qr.FormatSVG:
+	default:
+		return qr.Options{}, fmt.Errorf("%w: format 只能是 png 或 svg", ErrInvalidQROptions)
+	}
+
+	if opts.Size == 0 {
+		opts.Size = s.cfg.QR.DefaultSize
+	}
+	if opts.Size < minQRSize || opts.Size > s.cfg.QR.MaxSize {
+		return qr.Options{}, fmt.Errorf("%w: size 必须在 %d 到 %d 之间", ErrInvalidQROptions, minQRSize, s.cfg.QR.MaxSize)
+	}
+
+	if req.Margin != nil {
+		opts.Margin = *req.Margin
+	}
+	if opts.Margin < 0 || opts.Margin > maxQRMargin {
+		return qr.Options{}, fmt.Errorf("%w: margin 必须在 0 到 %d 之间", ErrInvalidQROptions, maxQRMargin)
+	}
+
+	ecc := strings.ToUpper(req.ECC)
+	if ecc == "" {
+		ecc = "M"
+	}
+	level, ok := qrLevels[ecc]
+	if !ok {
+		return qr.Options{}, fmt.Errorf("%w: ecc 只能是 L、M、Q 或 H", ErrInvalidQROptions)
+	}
+	opts.Level = level
+
+	var err error
+	if opts.Foreground, err = parseQRColor(req.Foreground, "000000", "fg"); err != nil {
+		return qr.Options{}, err
+	}
+	if opts.Background, err = parseQRColor(req.Background, "ffffff", "bg"); err != nil {
+		return qr.Options{}, err
+	}
+	return opts, nil
+}
+
+func parseQRColor(value, fallback, name string) (color.NRGBA, error) {
+	if value == "" {
+		value = fallback
+	}
+	c, err := qr.ParseColor(value)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%w: %s 必须是 RGB、RRGGBB 或 RRGGBBAA 格式的十六进制颜色", ErrInvalidQROptions, name)
+	}
+	return c, nil
+}
+
+// qrCacheKey 由规范化之后的绘制参数和编码内容组成，等价的请求（如 fg=000 与 fg=000000）共用缓存
+func qrCacheKey(opts qr.Options, content string) string {
+	return fmt.Sprintf("%s:%d:%d:%d:%02x%02x%02x%02x:%02x%02x%02x%02x:%s",
+		opts.Format, opts.Size, opts.Margin, opts.Level,
+		opts.Foreground.R, opts.Foreground.G, opts.Foreground.B, opts.Foreground.A,
+		opts.Background.R, opts.Background.G, opts.Background.B, opts.Background.A,
+		content)
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/useragent"
+)
+
+var ErrInvalidRule = errors.New("跳转规则不合法")
+
+var (
+	ruleIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	clockPattern  = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+	ruleDevices = []string{model.DeviceDesktop, model.DeviceMobile, model.DeviceTablet, model.DeviceBot}
+	ruleOS      = []string{"ios", "android", "windows", "macos", "linux", "chromeos"}
+	ruleDays    = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// ==================== 规则校验 ====================
+
+// normalizeRules 校验规则并统一取值的大小写，未指定 ID 的规则自动生成 ID
+func normalizeRules(rules []model.RedirectRule) (model.RedirectRules, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > model.MaxRulesPerURL {
+		return nil, fmt.Errorf("%w: 最多 %d 条", ErrInvalidRule, model.MaxRulesPerURL)
+	}
+
+	out := make(model.RedirectRules, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		if r.ID == "" {
+			r.ID = newRuleID()
+		}
+		if !ruleIDPattern.MatchString(r.ID) {
+			return nil, fmt.Errorf("%w: 规则 ID %q 只能包含字母、数字、- 和 _，最长 32 个字符", ErrInvalidRule, r.ID)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("%w: 规则 ID %q 重复", ErrInvalidRule, r.ID)
+		}
+		seen[r.ID] = true
+
+		if err := validateRuleURL(r.URL); err != nil {
+			return nil, fmt.Errorf("%w: 规则 %s: %v", ErrInvalidRule, r.ID, err)
+		}
+		if err := normalizeConditions(&r); err != nil {
+			return nil, fmt.Errorf("%w: 规则 %s: %v", ErrInvalidRule, r.ID, err)
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// normalizeConditions 校验单条规则的条件，至少需要一个条件
+func normalizeConditions(r *model.RedirectRule) error {
+	var err error
+	if r.Devices, err = normalizeChoices(r.Devices, strings.ToLower, ruleDevices, "devices"); err != nil {
+		return err
+	}
+	if r.OS, err = normalizeChoices(r.OS, strings.ToLower, ruleOS, "os"); err != nil {
+		return err
+	}
+	if r.Languages, err = normalizeChoices(r.Languages, strings.ToLower, nil, "languages"); err != nil {
+		return err
+	}
+	if r.Countries, err = normalizeChoices(r.Countries, strings.ToUpper, nil, "countries"); err != nil {
+		return err
+	}
+	for _, c := range r.Countries {
+		if len(c) != 2 {
+			return fmt.Errorf("countries 必须是两位国家代码: %q", c)
+		}
+	}
+	for k := range r.Query {
+		if k == "" {
+			return errors.New("query 参数名不能为空")
+		}
+	}
+	if r.Schedule != nil {
+		if err := validateRuleSchedule(r.Schedule); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Devices) == 0 && len(r.OS) == 0 && len(r.Languages) == 0 && len(r.Countries) == 0 &&
+		len(r.Query) == 0 && r.Schedule == nil {
+		return errors.New("至少需要一个条件")
+	}
+	return nil
+}
+
+// normalizeChoices 统一大小写、去重，allowed 不为 nil 时检查取值是否合法
+func normalizeChoices(values []string, fold func(string) string, allowed []string, field string) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		v = fold(strings.TrimSpace(v))
+		if v == "" {
+			return nil, fmt.Errorf("%s 不能包含空值", field)
+		}
+		if allowed != nil && !slices.Contains(allowed, v) {
+			return nil, fmt.Errorf("%s 只能是 %s", field, strings.Join(allowed, " / "))
+		}
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// validateRuleSchedule 校验时区、星期和时间格式
+func validateRuleSchedule(s *model.RuleSchedule) error {
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("未知的时区 %q", s.Timezone)
+	}
+	for i, d := range s.Days {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if _, ok := ruleDays[d]; !ok {
+			return fmt.Errorf("days 只能是 mon / tue / wed / thu / fri / sat / sun: %q", d)
+		}
+		s.Days[i] = d
+	}
+	for _, t := range []string{s.Start, s.End} {
+		if t != "" && !clockPattern.MatchString(t) {
+			return fmt.Errorf("时间格式必须是 HH:MM: %q", t)
+		}
+	}
+	return nil
+}
+
+// validateRuleURL 规则的目标地址必须是 http(s) 绝对地址
+func validateRuleURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url 必须是 http(s) 地址: %q", raw)
+	}
+	return nil
+}
+
+func newRuleID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "r" + hex.EncodeToString(b)
+}
+
+// ==================== 规则匹配 ====================
+
+// visitor 规则匹配使用的访问者信息
+// User-Agent、Accept-Language 和国家只在有规则用到时才解析
+type visitor struct {
+	req *RedirectRequest
+	geo GeoResolver
+	now time.Time
+
+	uaParsed bool
+	device   string
+	os       string
+
+	langParsed bool
+	languages  []string
+
+	countryLooked bool
+	country       string
+}
+
+// matchRule 按顺序返回第一条满足的规则，都不满足时返回 nil
+func (s *Service) matchRule(rules model.RedirectRules, req *RedirectRequest, now time.Time) *model.RedirectRule {
+	v := &visitor{req: req, geo: s.geo, now: now}
+	for i := range rules {
+		if v.matches(&rules[i]) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (v *visitor) matches(r *model.RedirectRule) bool {
+	if len(r.Devices) > 0 && !slices.Contains(r.Devices, v.deviceClass()) {
+		return false
+	}
+	if len(r.OS) > 0 && !slices.Contains(r.OS, v.osName()) {
+		return false
+	}
+	if len(r.Languages) > 0 && !v.matchLanguage(r.Languages) {
+		return false
+	}
+	if len(r.Countries) > 0 && !slices.Contains(r.Countries, v.countryCode()) {
+		return false
+	}
+	if r.Schedule != nil && !matchSchedule(r.Schedule, v.now) {
+		return false
+	}
+	for key, want := range r.Query {
+		values, ok := v.req.Query[key]
+		if !ok || (want != "*" && !slices.Contains(values, want)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *visitor) parseUserAgent() {
+	if v.uaParsed {
+		return
+	}
+	v.uaParsed = true
+	info := useragent.Parse(v.req.UserAgent)
+	v.device, v.os = info.Device, info.OS
+}
+
+func (v *visitor) deviceClass() string {
+	v.parseUserAgent()
+	return v.device
+}
+
+func (v *visitor) osName() string {
+	v.parseUserAgent()
+	return v.os
+}
+
+func (v *visitor) countryCode() string {
+	if !v.countryLooked {
+		v.countryLooked = true
+		if v.geo != nil {
+			v.country = strings.ToUpper(v.geo.Lookup(v.req.IP).Country)
+		}
+	}
+	return v.country
+}
+
+// matchLanguage 规则语言 zh 匹配 zh、zh-cn、zh-tw 等，zh-cn 只匹配 zh-cn
+func (v *visitor) matchLanguage(want []string) bool {
+	if !v.langParsed {
+		v.langParsed = true
+		v.languages = parseAcceptLanguage(v.req.AcceptLanguage)
+	}
+	for _, lang := range v.languages {
+		for _, w := range want {
+			if lang == w || strings.HasPrefix(lang, w+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAcceptLanguage 解析 Accept-Language 中的语言标签（小写），忽略 q=0 的语言和通配符
+func parseAcceptLanguage(header string) []string {
+	var langs []string
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok && strings.Trim(q, "0.") == "" {
+			continue
+		}
+		langs = append(langs, tag)
+	}
+	return langs
+}
+
+// matchSchedule 判断当前时间是否在规则的时间段内
+func matchSchedule(s *model.RuleSchedule, now time.Time) bool {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+
+	if len(s.Days) > 0 && !slices.ContainsFunc(s.Days, func(d string) bool { return ruleDays[d] == local.Weekday() }) {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	start, end := clockMinutes(s.Start, 0), clockMinutes(s.End, 24*60)
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// 跨午夜，如 22:00 - 06:00
+	return minute >= start || minute < end
+}
+
+// clockMinutes 将 HH:MM 转换为当天的分钟数，为空时返回 def
+func clockMinutes(clock string, def int) int {
+	if clock == "" {
+		return def
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return def
+	}
+	return t.Hour()*60 + t.Minute()
+}
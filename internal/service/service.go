@@ -10,65 +10,158 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/geoip"
 	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/pagination"
 	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+	"github.com/yourname/saas-shortener/internal/useragent"
 )
 
 var (
-	ErrQuotaExceeded = errors.New("URL 配额已用完，请升级套餐")
-	ErrRateLimited   = errors.New("请求频率超限，请稍后重试")
-	ErrURLNotFound   = errors.New("短链接不存在")
-	ErrURLExpired    = errors.New("短链接已过期")
+	ErrQuotaExceeded     = errors.New("URL 配额已用完，请升级套餐")
+	ErrRateLimited       = errors.New("请求频率超限，请稍后重试")
+	ErrURLNotFound       = errors.New("短链接不存在")
+	ErrURLExpired        = errors.New("短链接已过期")
+	ErrURLNotYetActive   = errors.New("短链接尚未生效")
+	ErrClickLimitReached = errors.New("短链接点击次数已达上限")
+	ErrInvalidSchedule   = errors.New("有效期参数错误")
+	ErrAPIKeyNotFound    = errors.New("API Key 不存在")
+	ErrAPIKeyExpired     = errors.New("API Key 已过期")
+	ErrInvalidScope      = errors.New("无效的权限范围")
+	ErrLastAdminKey      = errors.New("不能吊销最后一个有效的 admin Key")
+	ErrInvalidTimeRange  = errors.New("时间范围参数错误")
+	ErrPlanNotFound      = errors.New("套餐不存在")
+	ErrPlanExists        = errors.New("套餐已存在")
+	ErrPlanInUse         = errors.New("套餐仍有租户在使用，不能删除")
+	ErrPlanBelowUsage    = errors.New("当前用量超过目标套餐的配额")
+	ErrInvalidPlan       = errors.New("套餐参数错误")
+	ErrCustomCodeDenied  = errors.New("当前套餐不支持自定义短码，请升级套餐")
+	ErrInvalidCode       = errors.New("短码不合法")
+	ErrCodeTaken         = errors.New("短码已被占用")
+	ErrCodeExhausted     = errors.New("生成短码失败，请稍后重试")
+	ErrInvalidTag        = errors.New("标签不合法")
+	ErrInvalidFilter     = errors.New("列表筛选参数错误")
 )
 
+// IsInvalidInput 判断创建/更新短链接的错误是否由请求参数不合法引起（应返回 400）
+func IsInvalidInput(err error) bool {
+	return errors.Is(err, ErrInvalidSchedule) || errors.Is(err, ErrInvalidTag) ||
+		errors.Is(err, ErrInvalidPassword) || errors.Is(err, ErrInvalidRule) ||
+		errors.Is(err, ErrInvalidVariant) || errors.Is(err, ErrInvalidRedirect) ||
+		errors.Is(err, ErrInvalidDomain)
+}
+
+// 点击分析查询限制
+const (
+	defaultAnalyticsRange = 7 * 24 * time.Hour // 未指定 from 时默认查询最近 7 天
+	maxAnalyticsBuckets   = 2000               // 单次查询最多返回的时间桶数
+	topValuesLimit        = 10                 // 来源/User-Agent/地理位置排行数量
+)
+
+// GeoResolver 根据 IP 查询地理位置（国家为 ISO 3166-1 alpha-2 代码），查询不到的字段为空字符串
+// 生产环境由 geoip.Reader 实现
+type GeoResolver interface {
+	Lookup(ip string) geoip.Location
+}
+
 // Service 业务逻辑服务
 type Service struct {
-	repo   *repository.Repository
+	repo   repository.Store
+	clicks *ClickPipeline
+	codes  *CodePolicy
+	geo    GeoResolver // 可以为 nil，此时规则中的国家条件不会命中，点击事件不记录地理位置
+	cfg    *config.Config
 	logger *zap.Logger
+
+	streams     *streamRegistry // 本副本上打开的实时点击流连接
+	verifier    *DomainVerifier // 自定义域名所有权验证
+	defaultHost string          // PUBLIC_BASE_URL 中的域名，不能作为自定义域名添加
+	linkSecret  []byte          // 密码保护链接访问凭证的签名密钥
 }
 
 // New 创建 Service 实例
-func New(repo *repository.Repository, logger *zap.Logger) *Service {
-	return &Service{
-		repo:   repo,
-		logger: logger,
+// repo 可以是 PostgreSQL + Redis 实现（repository.New），也可以是内存实现（repository.NewMemoryStore）
+// geo 为 nil 时不做地理位置查询；verifier 为 nil 时使用系统 DNS 和默认 HTTP 客户端验证自定义域名
+func New(repo repository.Store, clicks *ClickPipeline, codes *CodePolicy, geo GeoResolver, verifier *DomainVerifier, cfg *config.Config, logger *zap.Logger) *Service {
+	secret := []byte(cfg.LinkPassword.CookieSecret)
+	if len(secret) == 0 {
+		// 随机密钥只在本进程内有效：重启后已签发的访问凭证失效，多副本之间也不通用
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		logger.Warn("未配置 LINK_COOKIE_SECRET，使用随机生成的密钥签发短链接访问凭证")
+	}
+	if verifier == nil {
+		verifier = NewDomainVerifier(nil, nil)
+	}
+
+	s := &Service{
+		repo:        repo,
+		clicks:      clicks,
+		codes:       codes,
+		geo:         geo,
+		cfg:         cfg,
+		logger:      logger,
+		streams:     newStreamRegistry(cfg.Stream),
+		verifier:    verifier,
+		defaultHost: publicHostname(cfg.Server.PublicBaseURL),
+		linkSecret:  secret,
 	}
+	if clicks != nil {
+		clicks.OnFlush(s.clicksRecorded)
+	}
+	return s
+}
+
+// clicksRecorded 点击写入数据库之后推送到实时点击流，并发布 click.recorded 事件
+func (s *Service) clicksRecorded(ctx context.Context, clicks []model.ClickEvent) {
+	s.streamClicks(ctx, clicks)
+	s.publishClicks(ctx, clicks)
 }
 
 // ==================== 租户管理 ====================
 
 // CreateTenant 创建新租户
 // SaaS 流程：用户注册 → 创建租户 → 分配 API Key → 选择套餐
-func (s *Service) CreateTenant(ctx context.Context, req *model.CreateTenantRequest) (*model.CreateTenantResponse, error) {
-	// 生成 API Key（生产环境建议使用更安全的方式，如 JWT）
-	apiKey := generateAPIKey()
+func (s *Service) CreateTenant(ctx context.Context, req *model.CreateTenantRequest) (_ *model.CreateTenantResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateTenant")
+	defer tracing.End(span, &err)
 
-	plan := req.Plan
-	if plan == "" {
-		plan = "free"
+	planName := req.Plan
+	if planName == "" {
+		planName = defaultPlan
 	}
 
 	// 根据套餐设置配额
 	// SaaS 核心：不同套餐有不同的功能和配额限制
-	rateLimit, maxURLs := getPlanLimits(plan)
+	plan, err := s.getPlan(ctx, planName)
+	if err != nil {
+		return nil, err
+	}
 
 	tenant := &model.Tenant{
 		ID:        uuid.New(),
 		Name:      req.Name,
-		APIKey:    hashAPIKey(apiKey), // 存储哈希后的 API Key
-		Plan:      plan,
-		RateLimit: rateLimit,
-		MaxURLs:   maxURLs,
+		Plan:      plan.Name,
+		RateLimit: plan.RateLimit,
+		MaxURLs:   plan.MaxURLs,
 		IsActive:  true,
 	}
 
-	if err := s.repo.CreateTenant(ctx, tenant); err != nil {
+	// 第一个 API Key 拥有 admin 权限，租户可以用它再创建权限更小的 Key
+	apiKey, key := newAPIKey(tenant.ID, "default", []string{model.ScopeAdmin}, nil)
+
+	if err := s.repo.CreateTenant(ctx, tenant, key); err != nil {
 		return nil, fmt.Errorf("创建租户失败: %w", err)
 	}
 
@@ -87,15 +180,136 @@ func (s *Service) CreateTenant(ctx context.Context, req *model.CreateTenantReque
 }
 
 // AuthenticateTenant 认证租户（通过 API Key）
-func (s *Service) AuthenticateTenant(ctx context.Context, apiKey string) (*model.Tenant, error) {
+// 返回租户和所用的 Key，调用方据此检查权限范围
+func (s *Service) AuthenticateTenant(ctx context.Context, apiKey string) (_ *model.Tenant, _ *model.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AuthenticateTenant")
+	defer tracing.End(span, &err)
+
 	hashedKey := hashAPIKey(apiKey)
-	return s.repo.GetTenantByAPIKey(ctx, hashedKey)
+	tenant, key, err := s.repo.GetTenantByAPIKey(ctx, hashedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrAPIKeyExpired
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+		s.logger.Warn("更新 API Key 使用时间失败", zap.Error(err))
+	}
+
+	return tenant, key, nil
+}
+
+// ==================== API Key 管理 ====================
+
+// CreateAPIKey 为租户创建新的 API Key
+func (s *Service) CreateAPIKey(ctx context.Context, tenantID uuid.UUID, req *model.CreateAPIKeyRequest) (_ *model.CreateAPIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateAPIKey")
+	defer tracing.End(span, &err)
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := resolveExpiry(req.ExpiresAt, req.ExpiresIn, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, key := newAPIKey(tenantID, req.Name, scopes, expiresAt)
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, fmt.Errorf("创建 API Key 失败: %w", err)
+	}
+
+	s.logger.Info("API Key 创建成功",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("key_id", key.ID.String()),
+		zap.Strings("scopes", scopes),
+	)
+
+	return &model.CreateAPIKeyResponse{
+		APIKeyResponse: *toAPIKeyResponse(key),
+		Key:            apiKey,
+	}, nil
+}
+
+// ListAPIKeys 查询租户的所有 API Key
+func (s *Service) ListAPIKeys(ctx context.Context, tenantID uuid.UUID) (_ []model.APIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListAPIKeys")
+	defer tracing.End(span, &err)
+
+	keys, err := s.repo.ListAPIKeysByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]model.APIKeyResponse, len(keys))
+	for i := range keys {
+		responses[i] = *toAPIKeyResponse(&keys[i])
+	}
+	return responses, nil
+}
+
+// RevokeAPIKey 吊销租户的 API Key，立即生效
+// 不允许吊销最后一个有效的 admin Key，否则租户将无法再管理自己的 Key
+func (s *Service) RevokeAPIKey(ctx context.Context, tenantID, id uuid.UUID) (_ *model.APIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.RevokeAPIKey")
+	defer tracing.End(span, &err)
+
+	key, err := s.repo.RevokeAPIKey(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		if errors.Is(err, repository.ErrLastAdminKey) {
+			return nil, ErrLastAdminKey
+		}
+		return nil, fmt.Errorf("吊销 API Key 失败: %w", err)
+	}
+
+	s.logger.Info("API Key 已吊销",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("key_id", id.String()),
+	)
+
+	return toAPIKeyResponse(key), nil
+}
+
+// RotateAPIKey 轮换 API Key：旧 Key 立即失效，返回具有相同名称和权限的新 Key
+func (s *Service) RotateAPIKey(ctx context.Context, tenantID, id uuid.UUID) (_ *model.CreateAPIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.RotateAPIKey")
+	defer tracing.End(span, &err)
+
+	apiKey, key := newAPIKey(tenantID, "", nil, nil)
+	if err := s.repo.RotateAPIKey(ctx, tenantID, id, key); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("轮换 API Key 失败: %w", err)
+	}
+
+	s.logger.Info("API Key 已轮换",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("old_key_id", id.String()),
+		zap.String("new_key_id", key.ID.String()),
+	)
+
+	return &model.CreateAPIKeyResponse{
+		APIKeyResponse: *toAPIKeyResponse(key),
+		Key:            apiKey,
+	}, nil
 }
 
 // ==================== 短链接管理 ====================
 
 // CreateShortURL 创建短链接
-func (s *Service) CreateShortURL(ctx context.Context, tenantID uuid.UUID, req *model.CreateShortURLRequest) (*model.ShortURLResponse, error) {
+func (s *Service) CreateShortURL(ctx context.Context, tenantID uuid.UUID, req *model.CreateShortURLRequest) (_ *model.ShortURLResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateShortURL")
+	defer tracing.End(span, &err)
+
 	// 1. 检查租户配额
 	// SaaS 关键：配额管理，免费用户有限制，付费用户配额更高
 	tenant, err := s.repo.GetTenantByID(ctx, tenantID)
@@ -117,136 +331,776 @@ func (s *Service) CreateShortURL(ctx context.Context, tenantID uuid.UUID, req *m
 		return nil, ErrQuotaExceeded
 	}
 
-	// 2. 生成或使用自定义短码
-	code := req.CustomCode
-	if code == "" {
-		code = generateShortCode(6)
+	// 2. 校验有效期和自定义短码（自定义短码是套餐功能）
+	allowCustomCode := false
+	if req.CustomCode != "" {
+		plan, err := s.getPlan(ctx, tenant.Plan)
+		if err != nil {
+			return nil, err
+		}
+		allowCustomCode = plan.AllowCustomCode
 	}
-
-	// 3. 创建短链接记录
-	shortURL := &model.ShortURL{
-		ID:          uuid.New(),
-		TenantID:    tenantID,
-		Code:        code,
-		OriginalURL: req.URL,
-		IsActive:    true,
+	shortURL, err := s.newShortURL(tenantID, req, allowCustomCode, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if shortURL.Domain, err = s.linkDomain(ctx, tenantID, req.Domain); err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.CreateShortURL(ctx, shortURL); err != nil {
+	// 3. 创建短链接记录；随机短码冲突时自动重试（短码在同一域名内唯一）
+	code, err := s.createWithCode(ctx, shortURL.Domain, func(code string) error {
+		shortURL.Code = code
+		return s.repo.CreateShortURL(ctx, shortURL)
+	}, req.CustomCode)
+	if err != nil {
+		if errors.Is(err, ErrCodeTaken) || errors.Is(err, ErrCodeExhausted) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("创建短链接失败: %w", err)
 	}
 
 	s.logger.Info("短链接创建成功",
 		zap.String("tenant_id", tenantID.String()),
 		zap.String("code", code),
+		zap.String("domain", shortURL.Domain),
 	)
 
-	return &model.ShortURLResponse{
-		ID:          shortURL.ID,
-		Code:        shortURL.Code,
-		ShortURL:    fmt.Sprintf("/%s", shortURL.Code),
-		OriginalURL: shortURL.OriginalURL,
-		Clicks:      0,
-		CreatedAt:   shortURL.CreatedAt,
+	s.publishURLs(ctx, model.EventURLCreated, shortURL)
+	s.publishQuotaWarning(ctx, tenantID, count, count+1, int64(tenant.MaxURLs))
+	return s.toShortURLResponse(shortURL), nil
+}
+
+// newShortURL 校验创建请求并构造短链接（不含短码）
+func (s *Service) newShortURL(tenantID uuid.UUID, req *model.CreateShortURLRequest, allowCustomCode bool, now time.Time) (*model.ShortURL, error) {
+	expiresAt, err := resolveExpiry(req.ExpiresAt, req.ExpiresIn, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSchedule(req.NotBefore, expiresAt); err != nil {
+		return nil, err
+	}
+
+	if req.CustomCode != "" {
+		if err := s.codes.Validate(req.CustomCode); err != nil {
+			return nil, err
+		}
+		if !allowCustomCode {
+			return nil, ErrCustomCodeDenied
+		}
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := normalizeRules(req.Rules)
+	if err != nil {
+		return nil, err
+	}
+	var variants []model.ShortURLVariant
+	if len(req.Variants) > 0 {
+		if variants, err = normalizeVariants(req.Variants); err != nil {
+			return nil, err
+		}
+	}
+
+	status, err := normalizeRedirectStatus(req.RedirectStatus)
+	if err != nil {
+		return nil, err
+	}
+	utm, err := normalizeUTM(req.UTM)
+	if err != nil {
+		return nil, err
+	}
+
+	var passwordHash string
+	if req.Password != "" {
+		if passwordHash, err = hashLinkPassword(req.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.ShortURL{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		OriginalURL: req.URL,
+		Title:       strings.TrimSpace(req.Title),
+		Folder:      strings.TrimSpace(req.Folder),
+		Tags:        tags,
+		IsActive:    true,
+		ExpiresAt:   expiresAt,
+		NotBefore:   req.NotBefore,
+		MaxClicks:   req.MaxClicks,
+
+		PasswordHash: passwordHash,
+		Rules:        rules,
+		Variants:     variants,
+
+		RedirectStatus: status,
+		ForwardQuery:   req.ForwardQuery,
+		ForwardPath:    req.ForwardPath,
+		UTM:            utm,
 	}, nil
 }
 
-// Redirect 处理短链接重定向
-func (s *Service) Redirect(ctx context.Context, code, ip, userAgent, referer string) (string, error) {
-	// 获取短链接信息
-	shortURL, err := s.repo.GetShortURLByCode(ctx, code)
+// RedirectRequest 一次重定向请求的访问信息
+type RedirectRequest struct {
+	Host           string // 请求的 Host，已验证的自定义域名在该域名下查找短码，其他 Host 按默认域名处理
+	Code           string
+	IP             string
+	UserAgent      string
+	Referer        string
+	AcceptLanguage string     // 用于按语言的条件跳转
+	Query          url.Values // 短链接地址上的查询参数，用于按参数的条件跳转和查询参数转发
+	Suffix         string     // /<code>/<rest> 中的 /<rest>，只有开启 ForwardPath 的链接允许
+	AccessToken    string     // 密码保护链接的访问凭证（来自 Cookie），见 UnlockShortURL
+	VariantID      string     // 访问者之前分配到的 A/B 测试目标（来自 Cookie）
+}
+
+// RedirectResult 重定向结果
+type RedirectResult struct {
+	URL       string // 跳转地址
+	Status    int    // 重定向状态码
+	VariantID string // 分配到的 A/B 测试目标，不为空时由 Handler 写入 Cookie 保持分配稳定
+}
+
+// Redirect 处理短链接重定向，返回跳转地址
+// 需要密码的链接在没有有效访问凭证时返回 ErrPasswordRequired，不记录点击；
+// 配置了条件跳转规则时跳转到第一条满足的规则的地址，没有命中规则时按权重分配 A/B 测试目标，
+// 点击事件记录命中的规则和分配到的目标；最后按短链接的重定向选项转发查询参数、路径后缀并注入 UTM 参数
+func (s *Service) Redirect(ctx context.Context, req *RedirectRequest) (_ *RedirectResult, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Redirect")
+	defer tracing.End(span, &err)
+
+	// 获取短链接信息（短码在同一域名内唯一，先由 Host 确定域名）
+	domain, err := s.requestDomain(ctx, req.Host)
+	if err != nil {
+		return nil, err
+	}
+	shortURL, err := s.repo.GetShortURLByCode(ctx, domain, req.Code)
 	if err != nil {
-		return "", ErrURLNotFound
+		return nil, ErrURLNotFound
+	}
+	if req.Suffix != "" && !shortURL.ForwardPath {
+		return nil, ErrURLNotFound
+	}
+	// 重定向是公开接口，没有经过 TenantAuth，这里补充链接所属租户
+	span.SetAttributes(tracing.AttrTenantID.String(shortURL.TenantID.String()))
+
+	// 检查有效期
+	now := time.Now()
+	if err := checkSchedule(shortURL, now); err != nil {
+		return nil, err
+	}
+
+	// 检查访问密码
+	if shortURL.IsProtected() && !s.verifyLinkAccess(shortURL, req.AccessToken, now) {
+		return nil, ErrPasswordRequired
 	}
 
-	// 检查是否过期
-	if shortURL.ExpiresAt != nil && shortURL.ExpiresAt.Before(time.Now()) {
-		return "", ErrURLExpired
+	// 检查点击上限
+	if err := s.reserveClick(ctx, shortURL, req.UserAgent); err != nil {
+		return nil, err
+	}
+
+	// 条件跳转优先，其次 A/B 分流，都没有时使用默认地址
+	result, ruleID := &RedirectResult{URL: shortURL.OriginalURL, Status: shortURL.StatusCode()}, ""
+	if rule := s.matchRule(shortURL.Rules, req, now); rule != nil {
+		result.URL, ruleID = rule.URL, rule.ID
+	} else if variant := pickVariant(shortURL, req); variant != nil {
+		result.URL, result.VariantID = variant.URL, variant.ID
+	}
+	if result.URL, err = buildDestination(result.URL, shortURL, req); err != nil {
+		return nil, fmt.Errorf("生成跳转地址失败: %w", err)
 	}
 
 	// 异步记录点击事件（不阻塞重定向响应）
-	// 云原生最佳实践：非关键路径异步处理
-	go func() {
-		bgCtx := context.Background()
-		// 增加点击计数
-		if err := s.repo.IncrementClicks(bgCtx, shortURL.ID); err != nil {
-			s.logger.Error("增加点击计数失败", zap.Error(err))
+	// 云原生最佳实践：非关键路径异步处理，由 ClickPipeline 批量写入
+	// 地理位置查询的是内存中的数据库，开销很小，直接在这里查询
+	var loc geoip.Location
+	if s.geo != nil {
+		loc = s.geo.Lookup(req.IP)
+	}
+	s.clicks.Enqueue(ctx, model.ClickEvent{
+		ID:         uuid.New(),
+		ShortURLID: shortURL.ID,
+		TenantID:   shortURL.TenantID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Referer:    req.Referer,
+		RuleID:     ruleID,
+		VariantID:  result.VariantID,
+		Country:    loc.Country,
+		Region:     loc.Region,
+		City:       loc.City,
+		CreatedAt:  now, // 以点击时间为准，而不是批量写入的时间
+	})
+
+	return result, nil
+}
+
+// checkSchedule 检查短链接是否在有效期内
+func checkSchedule(u *model.ShortURL, now time.Time) error {
+	if u.NotBefore != nil && now.Before(*u.NotBefore) {
+		return ErrURLNotYetActive
+	}
+	if u.ExpiresAt != nil && u.ExpiresAt.Before(now) {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+// reserveClick 为重定向占用一次点击，超过上限时返回 ErrClickLimitReached
+// 点击异步写入数据库，数据库中的计数会落后于实际点击，突发流量下只查数据库会远超上限；
+// 这里用原子计数比较后加一，并发请求中最多 MaxClicks 个通过。机器人点击不占用上限，只检查
+func (s *Service) reserveClick(ctx context.Context, u *model.ShortURL, userAgent string) error {
+	if u.MaxClicks <= 0 {
+		return nil
+	}
+	if useragent.Parse(userAgent).Bot {
+		return s.checkClickLimit(ctx, u)
+	}
+	reserved, err := s.repo.ReserveClick(ctx, u.ID, u.MaxClicks)
+	if err != nil {
+		return fmt.Errorf("记录点击次数失败: %w", err)
+	}
+	if !reserved {
+		return ErrClickLimitReached
+	}
+	return nil
+}
+
+// checkClickLimit 检查短链接是否已达点击上限（不占用点击）
+// 缓存中的 Clicks 可能是旧值，有上限的链接需要读取数据库中的实时计数
+func (s *Service) checkClickLimit(ctx context.Context, u *model.ShortURL) error {
+	if u.MaxClicks <= 0 {
+		return nil
+	}
+	clicks, err := s.repo.GetClicks(ctx, u.ID)
+	if err != nil {
+		return fmt.Errorf("查询点击次数失败: %w", err)
+	}
+	if clicks >= u.MaxClicks {
+		return ErrClickLimitReached
+	}
+	return nil
+}
+
+// ListShortURLs 查询租户的短链接列表
+// 使用键集分页：游标记录上一页边界行的 (排序字段, id)，翻页深度不影响查询代价，
+// 翻页期间新建的短链接也不会导致记录重复或遗漏；游标只能用于生成它的排序方式
+func (s *Service) ListShortURLs(ctx context.Context, tenantID uuid.UUID, filter *model.URLListFilter, req *model.PageRequest) (_ *model.ShortURLListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListShortURLs")
+	defer tracing.End(span, &err)
+
+	if err := normalizeListFilter(filter); err != nil {
+		return nil, err
+	}
+	page, err := pageQuery(req, filter.Sort, filter.Order, (&model.ShortURL{}).SortValue(filter.Sort))
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := s.repo.ListShortURLsByTenant(ctx, tenantID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	urls, next, prev := pagination.Trim(urls, page, func(u *model.ShortURL) pagination.Key {
+		return pagination.Key{Value: u.SortValue(filter.Sort), ID: u.ID}
+	})
+
+	// 转换为响应 DTO
+	resp := &model.ShortURLListResponse{
+		Data:     make([]model.ShortURLResponse, len(urls)),
+		PageSize: page.Limit,
+	}
+	for i := range urls {
+		resp.Data[i] = *s.toShortURLResponse(&urls[i])
+		includeBotClicks(&resp.Data[i], filter.IncludeBots)
+	}
+	if resp.NextCursor, err = encodeCursor(filter.Sort, filter.Order, next, false); err != nil {
+		return nil, err
+	}
+	if resp.PrevCursor, err = encodeCursor(filter.Sort, filter.Order, prev, true); err != nil {
+		return nil, err
+	}
+
+	if req.IncludeTotal {
+		total, err := s.repo.CountFilteredShortURLs(ctx, tenantID, filter)
+		if err != nil {
+			return nil, err
+		}
+		resp.Total = &total
+	}
+	return resp, nil
+}
+
+// GetShortURL 查询租户的单个短链接，includeBots 为 true 时 clicks 包含机器人点击
+func (s *Service) GetShortURL(ctx context.Context, tenantID, id uuid.UUID, includeBots bool) (_ *model.ShortURLResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetShortURL")
+	defer tracing.End(span, &err)
+
+	shortURL, err := s.repo.GetShortURLByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, fmt.Errorf("查询短链接失败: %w", err)
+	}
+	resp := s.toShortURLResponse(shortURL)
+	includeBotClicks(resp, includeBots)
+	return resp, nil
+}
+
+// UpdateShortURL 更新租户的短链接（修改目标地址、启用/停用）
+// Repository 会在更新后清除缓存，修改立即对重定向生效
+func (s *Service) UpdateShortURL(ctx context.Context, tenantID, id uuid.UUID, req *model.UpdateShortURLRequest) (_ *model.ShortURLResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdateShortURL")
+	defer tracing.End(span, &err)
+
+	updates := make(map[string]interface{})
+	if req.URL != nil {
+		updates["original_url"] = *req.URL
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.MaxClicks != nil {
+		updates["max_clicks"] = *req.MaxClicks
+	}
+	if req.Title != nil {
+		updates["title"] = strings.TrimSpace(*req.Title)
+	}
+	if req.Folder != nil {
+		updates["folder"] = strings.TrimSpace(*req.Folder)
+	}
+	if req.Password != nil {
+		// 设为空字符串表示取消密码保护
+		var hash string
+		if *req.Password != "" {
+			if hash, err = hashLinkPassword(*req.Password); err != nil {
+				return nil, err
+			}
+		}
+		updates["password_hash"] = hash
+	}
+	if req.Rules != nil {
+		rules, err := normalizeRules(*req.Rules)
+		if err != nil {
+			return nil, err
+		}
+		updates["rules"] = rules
+	}
+	if req.RedirectStatus != nil {
+		status, err := normalizeRedirectStatus(*req.RedirectStatus)
+		if err != nil {
+			return nil, err
+		}
+		updates["redirect_status"] = status
+	}
+	if req.ForwardQuery != nil {
+		updates["forward_query"] = *req.ForwardQuery
+	}
+	if req.ForwardPath != nil {
+		updates["forward_path"] = *req.ForwardPath
+	}
+	if req.UTM != nil {
+		utm, err := normalizeUTM(req.UTM)
+		if err != nil {
+			return nil, err
+		}
+		updates["utm"] = utm
+	}
+	var variants []model.ShortURLVariant
+	if req.Variants != nil {
+		if variants, err = normalizeVariants(*req.Variants); err != nil {
+			return nil, err
+		}
+	}
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+		if tags == nil {
+			tags = []string{} // 非 nil 的空切片表示清空标签
+		}
+	}
+
+	// 有效期变更需要与现有值合并后校验（如只修改 not_before 时仍需早于原过期时间）
+	if req.ExpiresAt != nil || req.ExpiresIn != nil || req.NotBefore != nil {
+		current, err := s.repo.GetShortURLByID(ctx, tenantID, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrURLNotFound
+			}
+			return nil, fmt.Errorf("查询短链接失败: %w", err)
 		}
-		// 记录点击详情
-		event := &model.ClickEvent{
-			ID:         uuid.New(),
-			ShortURLID: shortURL.ID,
-			TenantID:   shortURL.TenantID,
-			IP:         ip,
-			UserAgent:  userAgent,
-			Referer:    referer,
+
+		expiresAt, notBefore := current.ExpiresAt, current.NotBefore
+		if req.ExpiresAt != nil || req.ExpiresIn != nil {
+			expiresIn := ""
+			if req.ExpiresIn != nil {
+				expiresIn = *req.ExpiresIn
+			}
+			if expiresAt, err = resolveExpiry(req.ExpiresAt, expiresIn, time.Now()); err != nil {
+				return nil, err
+			}
+			updates["expires_at"] = expiresAt
 		}
-		if err := s.repo.CreateClickEvent(bgCtx, event); err != nil {
-			s.logger.Error("记录点击事件失败", zap.Error(err))
+		if req.NotBefore != nil {
+			notBefore = req.NotBefore
+			updates["not_before"] = notBefore
 		}
-	}()
+		if err := validateSchedule(notBefore, expiresAt); err != nil {
+			return nil, err
+		}
+	}
 
-	return shortURL.OriginalURL, nil
+	shortURL, err := s.repo.UpdateShortURL(ctx, tenantID, id, updates, tags, variants)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, fmt.Errorf("更新短链接失败: %w", err)
+	}
+
+	s.logger.Info("短链接更新成功",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("code", shortURL.Code),
+	)
+
+	s.publishURLs(ctx, model.EventURLUpdated, shortURL)
+	return s.toShortURLResponse(shortURL), nil
 }
 
-// ListShortURLs 查询租户的短链接列表
-func (s *Service) ListShortURLs(ctx context.Context, tenantID uuid.UUID, page, pageSize int) ([]model.ShortURLResponse, int64, error) {
-	if page < 1 {
-		page = 1
+// DeleteShortURL 删除租户的短链接
+func (s *Service) DeleteShortURL(ctx context.Context, tenantID, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteShortURL")
+	defer tracing.End(span, &err)
+
+	shortURL, err := s.repo.DeleteShortURL(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrURLNotFound
+		}
+		return fmt.Errorf("删除短链接失败: %w", err)
+	}
+
+	s.logger.Info("短链接删除成功",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("url_id", id.String()),
+	)
+	s.publishURLs(ctx, model.EventURLDeleted, shortURL)
+	return nil
+}
+
+// GetClickAnalytics 查询单个短链接的点击时间序列、来源、User-Agent 和地理位置排行，以及 A/B 测试各目标的点击数
+// from/to 为 nil 时默认最近 7 天；返回的时间序列按 interval 补齐无点击的桶，方便前端直接画图
+// includeBots 为 false 时所有统计都不含机器人点击
+func (s *Service) GetClickAnalytics(ctx context.Context, tenantID, urlID uuid.UUID, from, to *time.Time, interval string, includeBots bool) (_ *model.ClickAnalyticsResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetClickAnalytics")
+	defer tracing.End(span, &err)
+
+	if interval == "" {
+		interval = model.IntervalDay
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	step, ok := intervalSteps[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: interval 只能是 hour、day 或 week", ErrInvalidTimeRange)
 	}
 
-	offset := (page - 1) * pageSize
-	urls, total, err := s.repo.ListShortURLsByTenant(ctx, tenantID, offset, pageSize)
+	end := time.Now().UTC()
+	if to != nil {
+		end = to.UTC()
+	}
+	start := end.Add(-defaultAnalyticsRange)
+	if from != nil {
+		start = from.UTC()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from 必须早于 to", ErrInvalidTimeRange)
+	}
+
+	// 套餐限制可查询的历史范围，超出部分截断
+	start, err = s.clampToRetention(ctx, tenantID, start, end)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	if end.Sub(start)/step > maxAnalyticsBuckets {
+		return nil, fmt.Errorf("%w: 时间范围过大，请缩小范围或使用更大的 interval", ErrInvalidTimeRange)
 	}
 
-	// 转换为响应 DTO
-	responses := make([]model.ShortURLResponse, len(urls))
-	for i, u := range urls {
-		responses[i] = model.ShortURLResponse{
-			ID:          u.ID,
-			Code:        u.Code,
-			ShortURL:    fmt.Sprintf("/%s", u.Code),
-			OriginalURL: u.OriginalURL,
-			Clicks:      u.Clicks,
-			CreatedAt:   u.CreatedAt,
-			ExpiresAt:   u.ExpiresAt,
+	// 确认短链接属于当前租户
+	shortURL, err := s.repo.GetShortURLByID(ctx, tenantID, urlID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrURLNotFound
 		}
+		return nil, fmt.Errorf("查询短链接失败: %w", err)
+	}
+
+	buckets, err := s.repo.GetClickSeries(ctx, tenantID, urlID, start, end, interval, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询点击时间序列失败: %w", err)
+	}
+	referrers, err := s.repo.GetTopClickValues(ctx, tenantID, urlID, start, end, "referer", topValuesLimit, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询来源排行失败: %w", err)
 	}
+	userAgents, err := s.repo.GetTopClickValues(ctx, tenantID, urlID, start, end, "user_agent", topValuesLimit, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询 User-Agent 排行失败: %w", err)
+	}
+	countries, err := s.repo.GetTopClickValues(ctx, tenantID, urlID, start, end, "country", topValuesLimit, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询国家排行失败: %w", err)
+	}
+	cities, err := s.repo.GetTopClickValues(ctx, tenantID, urlID, start, end, repository.ClickLocationColumn, topValuesLimit, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询城市排行失败: %w", err)
+	}
+	variantCounts, err := s.repo.GetTopClickValues(ctx, tenantID, urlID, start, end, "variant_id", maxVariantBreakdown, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("查询 A/B 测试点击数失败: %w", err)
+	}
+
+	series, total := fillClickBuckets(buckets, start, end, interval)
 
-	return responses, total, nil
+	return &model.ClickAnalyticsResponse{
+		URLID:         urlID,
+		From:          start,
+		To:            end,
+		Interval:      interval,
+		TotalClicks:   total,
+		Series:        series,
+		TopReferrers:  referrers,
+		TopUserAgents: userAgents,
+		TopCountries:  countries,
+		TopCities:     cities,
+		Variants:      variantBreakdown(shortURL.Variants, variantCounts),
+	}, nil
 }
 
-// GetStats 获取租户统计信息
-func (s *Service) GetStats(ctx context.Context, tenantID uuid.UUID) (*model.StatsResponse, error) {
-	return s.repo.GetTenantStats(ctx, tenantID)
+// GetStats 获取租户统计信息，默认 total_clicks 不含机器人点击
+func (s *Service) GetStats(ctx context.Context, tenantID uuid.UUID, includeBots bool) (_ *model.StatsResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetStats")
+	defer tracing.End(span, &err)
+
+	stats, err := s.repo.GetTenantStats(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if includeBots {
+		stats.TotalClicks += stats.BotClicks
+	}
+	return stats, nil
 }
 
 // CheckRateLimit 检查限流
-func (s *Service) CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (bool, error) {
+func (s *Service) CheckRateLimit(ctx context.Context, tenantID uuid.UUID, limit int) (_ *repository.RateLimitResult, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CheckRateLimit")
+	defer tracing.End(span, &err)
+
 	return s.repo.CheckRateLimit(ctx, tenantID, limit)
 }
 
 // HealthCheck 健康检查
-func (s *Service) HealthCheck(ctx context.Context) error {
+func (s *Service) HealthCheck(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.HealthCheck")
+	defer tracing.End(span, &err)
+
 	return s.repo.HealthCheck(ctx)
 }
 
 // ==================== 辅助函数 ====================
 
-// generateShortCode 生成随机短码
-func generateShortCode(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	result := make([]byte, length)
-	for i := range result {
-		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-		result[i] = charset[n.Int64()]
+// toShortURLResponse 将模型转换为响应 DTO
+func (s *Service) toShortURLResponse(u *model.ShortURL) *model.ShortURLResponse {
+	tags := u.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var utm *model.UTMParams
+	if !u.UTM.IsZero() {
+		utm = &u.UTM
+	}
+	return &model.ShortURLResponse{
+		ID:          u.ID,
+		Code:        u.Code,
+		Domain:      u.Domain,
+		ShortURL:    s.shortLink(u, ""),
+		OriginalURL: u.OriginalURL,
+		Title:       u.Title,
+		Folder:      u.Folder,
+		Tags:        tags,
+		Clicks:      u.Clicks,
+		BotClicks:   u.BotClicks,
+		IsActive:    u.IsActive,
+		MaxClicks:   u.MaxClicks,
+		CreatedAt:   u.CreatedAt,
+		ExpiresAt:   u.ExpiresAt,
+		NotBefore:   u.NotBefore,
+
+		PasswordProtected: u.IsProtected(),
+		Rules:             u.Rules,
+		Variants:          u.Variants,
+
+		RedirectStatus: u.StatusCode(),
+		ForwardQuery:   u.ForwardQuery,
+		ForwardPath:    u.ForwardPath,
+		UTM:            utm,
+	}
+}
+
+// includeBotClicks includeBots 为 true 时把机器人点击计入响应的 clicks
+func includeBotClicks(resp *model.ShortURLResponse, includeBots bool) {
+	if includeBots {
+		resp.Clicks += resp.BotClicks
+	}
+}
+
+// intervalSteps 各时间粒度对应的桶长度
+var intervalSteps = map[string]time.Duration{
+	model.IntervalHour: time.Hour,
+	model.IntervalDay:  24 * time.Hour,
+	model.IntervalWeek: 7 * 24 * time.Hour,
+}
+
+// fillClickBuckets 将数据库返回的稀疏时间桶补齐为连续序列，并计算总点击数
+func fillClickBuckets(buckets []model.ClickBucket, from, to time.Time, interval string) ([]model.ClickBucket, int64) {
+	counts := make(map[time.Time]int64, len(buckets))
+	for _, b := range buckets {
+		counts[b.Time.UTC()] = b.Clicks
 	}
-	return string(result)
+
+	step := intervalSteps[interval]
+	var series []model.ClickBucket
+	var total int64
+	for t := model.TruncateToInterval(from, interval); t.Before(to); t = t.Add(step) {
+		series = append(series, model.ClickBucket{Time: t, Clicks: counts[t]})
+		total += counts[t]
+	}
+	return series, total
+}
+
+// resolveExpiry 根据 expires_at / expires_in 计算过期时间
+// 两者只能指定一个；都未指定时返回 nil（永不过期）
+func resolveExpiry(expiresAt *time.Time, expiresIn string, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && expiresIn != "" {
+		return nil, fmt.Errorf("%w: expires_at 与 expires_in 不能同时指定", ErrInvalidSchedule)
+	}
+
+	if expiresIn != "" {
+		d, err := time.ParseDuration(expiresIn)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: expires_in 必须是正的时长，如 \"72h\"", ErrInvalidSchedule)
+		}
+		t := now.Add(d)
+		return &t, nil
+	}
+
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at 必须晚于当前时间", ErrInvalidSchedule)
+	}
+	return expiresAt, nil
+}
+
+// validateSchedule 校验生效时间必须早于过期时间
+func validateSchedule(notBefore, expiresAt *time.Time) error {
+	if notBefore != nil && expiresAt != nil && !notBefore.Before(*expiresAt) {
+		return fmt.Errorf("%w: not_before 必须早于过期时间", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// normalizeTags 校验标签并统一为小写、去重
+// 标签中不能包含逗号和分号（CSV 导入/导出用它们分隔多个标签）
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > model.MaxTagsPerURL {
+		return nil, fmt.Errorf("%w: 最多 %d 个标签", ErrInvalidTag, model.MaxTagsPerURL)
+	}
+
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > model.MaxTagLength {
+			return nil, fmt.Errorf("%w: 标签长度必须在 1 到 %d 之间", ErrInvalidTag, model.MaxTagLength)
+		}
+		if strings.ContainsAny(tag, ",;") {
+			return nil, fmt.Errorf("%w: %q 不能包含逗号或分号", ErrInvalidTag, tag)
+		}
+		if !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// normalizeListFilter 校验列表排序参数并补充默认值：code 默认升序，其余默认倒序
+func normalizeListFilter(f *model.URLListFilter) error {
+	switch f.Sort {
+	case "":
+		f.Sort = model.SortCreatedAt
+	case model.SortCreatedAt, model.SortClicks, model.SortCode:
+	default:
+		return fmt.Errorf("%w: sort 只能是 created_at、clicks 或 code", ErrInvalidFilter)
+	}
+
+	switch f.Order {
+	case "":
+		f.Order = model.OrderDesc
+		if f.Sort == model.SortCode {
+			f.Order = model.OrderAsc
+		}
+	case model.OrderAsc, model.OrderDesc:
+	default:
+		return fmt.Errorf("%w: order 只能是 asc 或 desc", ErrInvalidFilter)
+	}
+
+	f.Query = strings.TrimSpace(f.Query)
+	f.Tag = strings.ToLower(strings.TrimSpace(f.Tag))
+	f.Folder = strings.TrimSpace(f.Folder)
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return fmt.Errorf("%w: created_after 必须早于 created_before", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// pageQuery 将分页请求转换为存储层的查询条件，sample 为排序字段的示例值
+func pageQuery(req *model.PageRequest, sort, order string, sample any) (pagination.Query, error) {
+	limit := req.PageSize
+	if limit < 1 || limit > pagination.MaxLimit {
+		limit = pagination.DefaultLimit
+	}
+	if req.Cursor == "" {
+		return pagination.Query{Limit: limit}, nil
+	}
+
+	cursor, err := pagination.Decode(req.Cursor)
+	if err != nil {
+		return pagination.Query{}, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	page, err := cursor.Query(sort, order, sample, limit)
+	if err != nil {
+		return pagination.Query{}, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return page, nil
+}
+
+// encodeCursor 生成返回给客户端的游标，key 为 nil（没有上一页/下一页）时返回 nil
+func encodeCursor(sort, order string, key *pagination.Key, backward bool) (*string, error) {
+	if key == nil {
+		return nil, nil
+	}
+	cursor, err := pagination.NewCursor(sort, order, key, backward)
+	if err != nil {
+		return nil, err
+	}
+	encoded := cursor.Encode()
+	return &encoded, nil
 }
 
 // generateAPIKey 生成 API Key
@@ -256,21 +1110,53 @@ func generateAPIKey() string {
 	return hex.EncodeToString(bytes)
 }
 
+// newAPIKey 生成明文 API Key 及对应的存储模型（只保存哈希）
+func newAPIKey(tenantID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (string, *model.APIKey) {
+	apiKey := generateAPIKey()
+	return apiKey, &model.APIKey{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Name:      name,
+		KeyHash:   hashAPIKey(apiKey),
+		Prefix:    apiKey[:8],
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// normalizeScopes 校验并去重权限范围
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(model.AllScopes, scope) {
+			return nil, fmt.Errorf("%w: %q，可选值: %s", ErrInvalidScope, scope, strings.Join(model.AllScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// toAPIKeyResponse 将 API Key 模型转换为响应 DTO
+func toAPIKeyResponse(k *model.APIKey) *model.APIKeyResponse {
+	return &model.APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+		Revoked:    k.Revoked,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
 // hashAPIKey 对 API Key 进行哈希（安全存储）
 func hashAPIKey(key string) string {
 	hash := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
-
-// getPlanLimits 根据套餐返回配额
-// SaaS 定价策略：不同套餐 → 不同功能/配额 → 不同价格
-func getPlanLimits(plan string) (rateLimit int, maxURLs int) {
-	switch plan {
-	case "pro":
-		return 500, 10000
-	case "enterprise":
-		return 5000, 100000
-	default: // free
-		return 100, 1000
-	}
-}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/config"
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var (
+	ErrStreamLimit     = errors.New("实时点击流连接数已达上限")
+	ErrInvalidStreamID = errors.New("Last-Event-ID 格式错误")
+	ErrStreamClosed    = errors.New("服务正在关闭，请稍后重连")
+)
+
+var clickStreamConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "click_stream_connections",
+	Help: "当前打开的实时点击流连接数",
+})
+
+// streamRegistry 本副本上打开的点击流：按租户统计连接数，服务关闭时通知所有连接退出
+type streamRegistry struct {
+	cfg config.StreamConfig
+
+	mu     sync.Mutex
+	conns  map[uuid.UUID]int
+	closed bool
+	done   chan struct{}
+}
+
+// newStreamRegistry 不合法的配置使用默认值（MaxConnectionsPerTenant 为 0 表示不限制）
+func newStreamRegistry(cfg config.StreamConfig) *streamRegistry {
+	if cfg.MaxConnectionsPerTenant < 0 {
+		cfg.MaxConnectionsPerTenant = 0
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+	if cfg.BacklogSize <= 0 {
+		cfg.BacklogSize = 1000
+	}
+	if cfg.BacklogTTL <= 0 {
+		cfg.BacklogTTL = 10 * time.Minute
+	}
+	return &streamRegistry{cfg: cfg, conns: make(map[uuid.UUID]int), done: make(chan struct{})}
+}
+
+func (l *streamRegistry) acquire(tenantID uuid.UUID, limit int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrStreamClosed
+	}
+	if limit > 0 && l.conns[tenantID] >= limit {
+		return fmt.Errorf("%w: 每个租户最多 %d 个", ErrStreamLimit, limit)
+	}
+	l.conns[tenantID]++
+	clickStreamConnections.Inc()
+	return nil
+}
+
+func (l *streamRegistry) release(tenantID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conns[tenantID]--; l.conns[tenantID] <= 0 {
+		delete(l.conns, tenantID)
+	}
+	clickStreamConnections.Dec()
+}
+
+// CloseClickStreams 结束本副本上所有的点击流连接并拒绝新连接
+// 点击流是长连接，HTTP 服务优雅关闭时不会主动结束，需要在关闭开始时调用（见 http.Server.RegisterOnShutdown）
+func (s *Service) CloseClickStreams() {
+	s.streams.mu.Lock()
+	defer s.streams.mu.Unlock()
+
+	if !s.streams.closed {
+		s.streams.closed = true
+		close(s.streams.done)
+	}
+}
+
+// ClickStream 一个实时点击流连接
+// 先发送 Backlog（断线期间错过的点击），再从 C 读取实时点击；两者已去重、已按短链接过滤
+// 订阅中断或服务关闭时 C 被关闭
+type ClickStream struct {
+	Backlog []repository.StreamedClick
+	C       <-chan repository.StreamedClick
+
+	Heartbeat    time.Duration // 心跳注释的发送间隔
+	WriteTimeout time.Duration // 单次写入的超时时间
+
+	sub     repository.ClickSubscription
+	release func()
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Close 取消订阅并释放连接名额，连接结束时必须调用
+func (s *ClickStream) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.sub.Close()
+		s.release()
+	})
+}
+
+// OpenClickStream 打开租户的实时点击流，urlID 非 nil 时只推送该短链接的点击
+// lastEventID 非空时先回放之后的点击（回放队列只保留最近 STREAM_BACKLOG_SIZE 条，更早的无法补齐）
+// 先订阅再读回放，两者重叠的部分按 ID 去重，订阅生效之前和之后的点击都不会遗漏
+func (s *Service) OpenClickStream(ctx context.Context, tenantID uuid.UUID, urlID *uuid.UUID, lastEventID string) (_ *ClickStream, err error) {
+	ctx, span := tracing.Start(ctx, "Service.OpenClickStream")
+	defer tracing.End(span, &err)
+
+	if lastEventID != "" {
+		if _, _, ok := repository.ParseStreamID(lastEventID); !ok {
+			return nil, ErrInvalidStreamID
+		}
+	}
+	if urlID != nil {
+		if _, err := s.repo.GetShortURLByID(ctx, tenantID, *urlID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrURLNotFound
+			}
+			return nil, fmt.Errorf("查询短链接失败: %w", err)
+		}
+	}
+
+	if err := s.streams.acquire(tenantID, s.streams.cfg.MaxConnectionsPerTenant); err != nil {
+		return nil, err
+	}
+	release := func() { s.streams.release(tenantID) }
+
+	// 订阅的生命周期跟随连接而不是本次调用的 span
+	sub, err := s.repo.SubscribeClicks(context.WithoutCancel(ctx), tenantID)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("订阅点击流失败: %w", err)
+	}
+
+	stream := &ClickStream{
+		Heartbeat:    s.streams.cfg.Heartbeat,
+		WriteTimeout: s.streams.cfg.WriteTimeout,
+		sub:          sub,
+		release:      release,
+		done:         make(chan struct{}),
+	}
+	last := lastEventID
+	if lastEventID != "" {
+		backlog, err := s.repo.ClickBacklog(ctx, tenantID, lastEventID, s.streams.cfg.BacklogSize)
+		if err != nil {
+			stream.Close()
+			return nil, fmt.Errorf("读取回放点击失败: %w", err)
+		}
+		if len(backlog) > 0 {
+			last = backlog[len(backlog)-1].ID
+		}
+		for _, click := range backlog {
+			if matchStreamURL(&click, urlID) {
+				stream.Backlog = append(stream.Backlog, click)
+			}
+		}
+	}
+
+	ch := make(chan repository.StreamedClick, cap(sub.C()))
+	stream.C = ch
+	go func() {
+		defer close(ch)
+		for {
+			var click repository.StreamedClick
+			select {
+			case c, ok := <-sub.C():
+				if !ok {
+					return
+				}
+				click = c
+			case <-s.streams.done:
+				return
+			}
+			if last != "" && !repository.StreamIDAfter(click.ID, last) {
+				continue // 已在回放中发送过
+			}
+			if !matchStreamURL(&click, urlID) {
+				continue
+			}
+			select {
+			case ch <- click:
+			case <-stream.done:
+				return
+			case <-s.streams.done:
+				return
+			}
+		}
+	}()
+
+	s.logger.Debug("打开实时点击流",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("last_event_id", lastEventID),
+		zap.Int("backlog", len(stream.Backlog)),
+	)
+	return stream, nil
+}
+
+// streamClicks 把成功写入的一批点击发布到实时点击流，失败只记录日志
+func (s *Service) streamClicks(ctx context.Context, clicks []model.ClickEvent) {
+	if err := s.repo.PublishClicks(ctx, clicks, s.streams.cfg.BacklogSize, s.streams.cfg.BacklogTTL); err != nil {
+		s.logger.Warn("发布实时点击失败", zap.Int("count", len(clicks)), zap.Error(err))
+	}
+}
+
+func matchStreamURL(click *repository.StreamedClick, urlID *uuid.UUID) bool {
+	return urlID == nil || click.Event.ShortURLID == *urlID
+}
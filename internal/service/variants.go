@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+var ErrInvalidVariant = errors.New("A/B 测试目标不合法")
+
+// 单个目标的权重上限，权重只表示相对比例，70/30 与 7/3 等价
+const maxVariantWeight = 10000
+
+// maxVariantBreakdown 点击分析中最多列出的目标数（包括已删除的目标）
+const maxVariantBreakdown = 50
+
+// normalizeVariants 校验 A/B 测试目标，未指定 ID 的目标自动生成 ID
+// 返回非 nil 的空切片表示清空（更新时使用）
+func normalizeVariants(variants []model.ShortURLVariant) ([]model.ShortURLVariant, error) {
+	if len(variants) == 0 {
+		return []model.ShortURLVariant{}, nil
+	}
+	if len(variants) > model.MaxVariantsPerURL {
+		return nil, fmt.Errorf("%w: 最多 %d 个", ErrInvalidVariant, model.MaxVariantsPerURL)
+	}
+
+	out := make([]model.ShortURLVariant, len(variants))
+	seen := make(map[string]bool, len(variants))
+	total := 0
+	for i, v := range variants {
+		if v.ID == "" {
+			v.ID = newVariantID()
+		}
+		if !ruleIDPattern.MatchString(v.ID) {
+			return nil, fmt.Errorf("%w: ID %q 只能包含字母、数字、- 和 _，最长 32 个字符", ErrInvalidVariant, v.ID)
+		}
+		if seen[v.ID] {
+			return nil, fmt.Errorf("%w: ID %q 重复", ErrInvalidVariant, v.ID)
+		}
+		seen[v.ID] = true
+
+		if err := validateRuleURL(v.URL); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidVariant, v.ID, err)
+		}
+		if v.Weight < 0 || v.Weight > maxVariantWeight {
+			return nil, fmt.Errorf("%w: %s: weight 必须在 0 到 %d 之间", ErrInvalidVariant, v.ID, maxVariantWeight)
+		}
+		total += v.Weight
+		out[i] = model.ShortURLVariant{ID: v.ID, URL: v.URL, Weight: v.Weight}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: 至少需要一个权重大于 0 的目标", ErrInvalidVariant)
+	}
+	return out, nil
+}
+
+func newVariantID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "v" + hex.EncodeToString(b)
+}
+
+// pickVariant 为访问者分配 A/B 测试目标，没有目标时返回 nil
+// 访问者 Cookie 中记录的目标仍然有效（存在且权重大于 0）时沿用；
+// 否则按 短码 + IP + User-Agent 的哈希在权重区间中选择，没有 Cookie 的客户端同样保持稳定
+func pickVariant(u *model.ShortURL, req *RedirectRequest) *model.ShortURLVariant {
+	total := 0
+	for i := range u.Variants {
+		v := &u.Variants[i]
+		if v.ID == req.VariantID && v.Weight > 0 {
+			return v
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(u.Code + "\n" + req.IP + "\n" + req.UserAgent))
+	point := int(h.Sum64() % uint64(total))
+	for i := range u.Variants {
+		v := &u.Variants[i]
+		if point < v.Weight {
+			return v
+		}
+		point -= v.Weight
+	}
+	return nil
+}
+
+// variantBreakdown 合并当前目标与各目标的点击数，按当前目标的顺序排列，已删除的目标排在最后
+// 没有目标也没有分流点击时返回 nil
+func variantBreakdown(variants []model.ShortURLVariant, counts []model.CountItem) []model.VariantStats {
+	clicks := make(map[string]int64, len(counts))
+	var total int64
+	for _, c := range counts {
+		if c.Value == "" {
+			continue // 命中规则或没有参与分流的点击
+		}
+		clicks[c.Value] = c.Count
+		total += c.Count
+	}
+	if len(variants) == 0 && total == 0 {
+		return nil
+	}
+
+	stats := make([]model.VariantStats, 0, len(variants)+len(clicks))
+	for _, v := range variants {
+		stats = append(stats, model.VariantStats{ID: v.ID, URL: v.URL, Weight: v.Weight, Clicks: clicks[v.ID]})
+		delete(clicks, v.ID)
+	}
+	// counts 已按点击数倒序排列
+	for _, c := range counts {
+		if _, ok := clicks[c.Value]; ok {
+			stats = append(stats, model.VariantStats{ID: c.Value, Clicks: c.Count})
+		}
+	}
+	if total > 0 {
+		for i := range stats {
+			stats[i].Share = float64(stats[i].Clicks) / float64(total)
+		}
+	}
+	return stats
+}
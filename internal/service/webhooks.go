@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/yourname/saas-shortener/internal/model"
+	"github.com/yourname/saas-shortener/internal/pagination"
+	"github.com/yourname/saas-shortener/internal/repository"
+	"github.com/yourname/saas-shortener/internal/tracing"
+)
+
+var (
+	ErrInvalidWebhook   = errors.New("Webhook 参数错误")
+	ErrWebhookNotFound  = errors.New("Webhook 不存在")
+	ErrWebhookLimit     = errors.New("Webhook 数量已达上限")
+	ErrDeliveryNotFound = errors.New("投递记录不存在")
+)
+
+// webhookSecretPrefix 签名密钥前缀，便于在日志和配置中辨认
+const webhookSecretPrefix = "whsec_"
+
+// ==================== Webhook 管理 ====================
+
+// CreateWebhook 为租户创建 Webhook，签名密钥只在响应中返回一次
+func (s *Service) CreateWebhook(ctx context.Context, tenantID uuid.UUID, req *model.CreateWebhookRequest) (_ *model.CreateWebhookResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateWebhook")
+	defer tracing.End(span, &err)
+
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountWebhooksByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 数量失败: %w", err)
+	}
+	if count >= int64(s.cfg.Webhooks.MaxPerTenant) {
+		return nil, fmt.Errorf("%w: 最多 %d 个", ErrWebhookLimit, s.cfg.Webhooks.MaxPerTenant)
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	webhook := &model.Webhook{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		URL:      req.URL,
+		Secret:   webhookSecretPrefix + hex.EncodeToString(secret),
+		Events:   events,
+		IsActive: true,
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("创建 Webhook 失败: %w", err)
+	}
+
+	s.logger.Info("Webhook 创建成功",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("webhook_id", webhook.ID.String()),
+		zap.String("events", webhook.Events),
+	)
+	return &model.CreateWebhookResponse{
+		WebhookResponse: *toWebhookResponse(webhook),
+		Secret:          webhook.Secret,
+	}, nil
+}
+
+// ListWebhooks 查询租户的全部 Webhook
+func (s *Service) ListWebhooks(ctx context.Context, tenantID uuid.UUID) (_ []model.WebhookResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListWebhooks")
+	defer tracing.End(span, &err)
+
+	webhooks, err := s.repo.ListWebhooksByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 失败: %w", err)
+	}
+	resp := make([]model.WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		resp[i] = *toWebhookResponse(&webhooks[i])
+	}
+	return resp, nil
+}
+
+// UpdateWebhook 修改 Webhook 的地址、订阅事件或启用状态
+func (s *Service) UpdateWebhook(ctx context.Context, tenantID, id uuid.UUID, req *model.UpdateWebhookRequest) (_ *model.WebhookResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdateWebhook")
+	defer tracing.End(span, &err)
+
+	updates := make(map[string]interface{})
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			return nil, err
+		}
+		updates["url"] = *req.URL
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(*req.Events)
+		if err != nil {
+			return nil, err
+		}
+		updates["events"] = events
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	webhook, err := s.repo.UpdateWebhook(ctx, tenantID, id, updates)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("更新 Webhook 失败: %w", err)
+	}
+	return toWebhookResponse(webhook), nil
+}
+
+// DeleteWebhook 删除 Webhook，尚未投递的事件一并丢弃
+func (s *Service) DeleteWebhook(ctx context.Context, tenantID, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteWebhook")
+	defer tracing.End(span, &err)
+
+	if err := s.repo.DeleteWebhook(ctx, tenantID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWebhookNotFound
+		}
+		return fmt.Errorf("删除 Webhook 失败: %w", err)
+	}
+
+	s.logger.Info("Webhook 删除成功",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("webhook_id", id.String()),
+	)
+	return nil
+}
+
+// ListWebhookDeliveries 按创建时间倒序查询 Webhook 的投递记录，status 为空时返回全部状态
+func (s *Service) ListWebhookDeliveries(ctx context.Context, tenantID, webhookID uuid.UUID, status string, req *model.PageRequest) (_ *model.WebhookDeliveryListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ListWebhookDeliveries")
+	defer tracing.End(span, &err)
+
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: status 只能是 pending、delivered 或 dead", ErrInvalidFilter)
+	}
+	page, err := pageQuery(req, "created_at", model.OrderDesc, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.GetWebhookByID(ctx, tenantID, webhookID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("查询 Webhook 失败: %w", err)
+	}
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, tenantID, webhookID, status, page)
+	if err != nil {
+		return nil, fmt.Errorf("查询投递记录失败: %w", err)
+	}
+	deliveries, next, prev := pagination.Trim(deliveries, page, func(d *model.WebhookDelivery) pagination.Key {
+		return pagination.Key{Value: d.CreatedAt, ID: d.ID}
+	})
+
+	resp := &model.WebhookDeliveryListResponse{
+		Data:     make([]model.WebhookDeliveryResponse, len(deliveries)),
+		PageSize: page.Limit,
+	}
+	for i := range deliveries {
+		resp.Data[i] = *toDeliveryResponse(&deliveries[i])
+	}
+	if resp.NextCursor, err = encodeCursor("created_at", model.OrderDesc, next, false); err != nil {
+		return nil, err
+	}
+	if resp.PrevCursor, err = encodeCursor("created_at", model.OrderDesc, prev, true); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RedeliverWebhook 手动重新投递：记录回到 pending 状态并立即投递，重试次数重新计算
+// 请求体与首次投递相同（同一个事件 ID），接收方可以据此去重
+func (s *Service) RedeliverWebhook(ctx context.Context, tenantID, webhookID, deliveryID uuid.UUID) (_ *model.WebhookDeliveryResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.RedeliverWebhook")
+	defer tracing.End(span, &err)
+
+	delivery, err := s.repo.RedeliverWebhookDelivery(ctx, tenantID, webhookID, deliveryID, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("重新投递失败: %w", err)
+	}
+
+	s.logger.Info("Webhook 重新投递",
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("delivery_id", deliveryID.String()),
+	)
+	return toDeliveryResponse(delivery), nil
+}
+
+// ==================== 事件发布 ====================
+
+// webhookEvent 待发布的一个事件
+type webhookEvent struct {
+	tenantID uuid.UUID
+	data     interface{}
+}
+
+// publish 为订阅了 event 的 Webhook 写入投递记录，由 WebhookDispatcher 在后台投递
+// 事件发布不影响触发它的业务操作：失败时只记录日志（业务数据与投递记录不在同一事务中）
+func (s *Service) publish(ctx context.Context, event string, events ...webhookEvent) {
+	if len(events) == 0 {
+		return
+	}
+	var tenantIDs []uuid.UUID
+	for _, e := range events {
+		if !slices.Contains(tenantIDs, e.tenantID) {
+			tenantIDs = append(tenantIDs, e.tenantID)
+		}
+	}
+
+	webhooks, err := s.repo.ListSubscribedWebhooks(ctx, tenantIDs, event)
+	if err != nil {
+		s.logger.Error("查询 Webhook 订阅失败", zap.String("event", event), zap.Error(err))
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var deliveries []model.WebhookDelivery
+	for _, e := range events {
+		var payload []byte
+		eventID := uuid.New()
+		for _, w := range webhooks {
+			if w.TenantID != e.tenantID {
+				continue
+			}
+			if payload == nil {
+				if payload, err = json.Marshal(model.WebhookPayload{
+					ID:        eventID,
+					Type:      event,
+					TenantID:  e.tenantID,
+					CreatedAt: now,
+					Data:      e.data,
+				}); err != nil {
+					s.logger.Error("序列化 Webhook 事件失败", zap.String("event", event), zap.Error(err))
+					break
+				}
+			}
+			deliveries = append(deliveries, model.WebhookDelivery{
+				ID:            uuid.New(),
+				TenantID:      e.tenantID,
+				WebhookID:     w.ID,
+				EventID:       eventID,
+				Event:         event,
+				Payload:       string(payload),
+				Status:        model.DeliveryPending,
+				NextAttemptAt: now,
+			})
+		}
+	}
+
+	if err := s.repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		s.logger.Error("写入 Webhook 投递记录失败",
+			zap.String("event", event),
+			zap.Int("count", len(deliveries)),
+			zap.Error(err),
+		)
+	}
+}
+
+// publishURLs 发布短链接事件（url.created / url.updated / url.deleted）
+func (s *Service) publishURLs(ctx context.Context, event string, urls ...*model.ShortURL) {
+	events := make([]webhookEvent, len(urls))
+	for i, u := range urls {
+		events[i] = webhookEvent{tenantID: u.TenantID, data: s.toShortURLResponse(u)}
+	}
+	s.publish(ctx, event, events...)
+}
+
+// publishClicks 为 ClickPipeline 成功写入的一批点击发布 click.recorded 事件
+// 在点击管道的 worker 中执行，不在重定向请求的路径上
+func (s *Service) publishClicks(ctx context.Context, clicks []model.ClickEvent) {
+	events := make([]webhookEvent, len(clicks))
+	for i := range clicks {
+		events[i] = webhookEvent{tenantID: clicks[i].TenantID, data: &clicks[i]}
+	}
+	s.publish(ctx, model.EventClickRecorded, events...)
+}
+
+// publishQuotaWarning URL 用量从 before 增加到 after 时，跨过提醒阈值则发布 quota.warning
+// 只在跨过阈值的那一次发布，之后继续创建不会重复提醒
+func (s *Service) publishQuotaWarning(ctx context.Context, tenantID uuid.UUID, before, after, limit int64) {
+	percent := s.cfg.Webhooks.QuotaWarningPercent
+	if percent <= 0 || limit <= 0 {
+		return
+	}
+	threshold := (limit*int64(percent) + 99) / 100 // 向上取整
+	if before >= threshold || after < threshold {
+		return
+	}
+	s.publish(ctx, model.EventQuotaWarning, webhookEvent{
+		tenantID: tenantID,
+		data: &model.QuotaWarning{
+			Resource: "urls",
+			Used:     after,
+			Limit:    limit,
+			Percent:  percent,
+		},
+	})
+}
+
+// ==================== 辅助函数 ====================
+
+// validateWebhookURL 推送地址必须是 http(s) 绝对地址（内网地址在投递时由 publicTransport 拒绝）
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url 必须是 http 或 https 地址", ErrInvalidWebhook)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: url 不能包含用户名和密码", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// normalizeWebhookEvents 校验订阅事件并去重，按 model.WebhookEvents 的顺序以逗号拼接
+func normalizeWebhookEvents(events []string) (string, error) {
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		e = strings.TrimSpace(e)
+		if !slices.Contains(model.WebhookEvents, e) {
+			return "", fmt.Errorf("%w: 不支持的事件 %q，可选 %s", ErrInvalidWebhook, e, strings.Join(model.WebhookEvents, "、"))
+		}
+		seen[e] = true
+	}
+	var list []string
+	for _, e := range model.WebhookEvents {
+		if seen[e] {
+			list = append(list, e)
+		}
+	}
+	if len(list) == 0 {
+		return "", fmt.Errorf("%w: 至少订阅一个事件", ErrInvalidWebhook)
+	}
+	return strings.Join(list, ","), nil
+}
+
+func toWebhookResponse(w *model.Webhook) *model.WebhookResponse {
+	return &model.WebhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    w.EventList(),
+		IsActive:  w.IsActive,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+func toDeliveryResponse(d *model.WebhookDelivery) *model.WebhookDeliveryResponse {
+	resp := &model.WebhookDeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastAttemptAt:  d.LastAttemptAt,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+		Payload:        json.RawMessage(d.Payload),
+	}
+	if d.Status == model.DeliveryPending {
+		next := d.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	return resp
+}
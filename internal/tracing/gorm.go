@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin 通过 GORM 回调为每条 SQL 创建 span
+// 使用方式：db.Use(tracing.NewGormPlugin())
+// 查询必须通过 db.WithContext(ctx) 传入 context，span 才能挂到所属请求下
+type GormPlugin struct{}
+
+// NewGormPlugin 创建 GORM 链路追踪插件
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{}
+}
+
+// Name 实现 gorm.Plugin
+func (p *GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize 实现 gorm.Plugin，在每类操作前后注册回调
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []struct {
+		name   string
+		before error
+		after  error
+	}{
+		{"create",
+			cb.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+			cb.Create().After("gorm:create").Register("tracing:after_create", after)},
+		{"query",
+			cb.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+			cb.Query().After("gorm:query").Register("tracing:after_query", after)},
+		{"update",
+			cb.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+			cb.Update().After("gorm:update").Register("tracing:after_update", after)},
+		{"delete",
+			cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+			cb.Delete().After("gorm:delete").Register("tracing:after_delete", after)},
+		{"row",
+			cb.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+			cb.Row().After("gorm:row").Register("tracing:after_row", after)},
+		{"raw",
+			cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+			cb.Raw().After("gorm:raw").Register("tracing:after_raw", after)},
+	}
+
+	for _, r := range registrations {
+		if err := errors.Join(r.before, r.after); err != nil {
+			return fmt.Errorf("注册 GORM %s 回调失败: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+func before(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Start(db.Statement.Context, "gorm."+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationName(op),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func after(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	defer span.End()
+
+	// 只记录带占位符的 SQL，不记录参数值，避免把用户数据写进链路
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		semconv.DBCollectionName(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil && !isExpected(db.Error, gorm.ErrRecordNotFound) {
+		RecordError(span, db.Error)
+	}
+}
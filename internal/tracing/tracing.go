@@ -0,0 +1,155 @@
+// Package tracing 分布式链路追踪（OpenTelemetry）
+// 云原生可观测性三大支柱之三：Tracing（链路追踪）
+//
+// 一次请求的完整链路：
+// Gin 请求 span → Service 方法 span → GORM 查询 / Redis 命令 span
+// 以及重定向后异步写入点击事件的批量 span（通过 Link 关联到原请求）
+//
+// 上游通过 W3C traceparent 头传入的链路会被延续，
+// 所有 span 都带有 tenant_id / plan 属性，可以在 Jaeger/Tempo 中按租户过滤
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yourname/saas-shortener/internal/config"
+)
+
+// 支持的导出方式
+const (
+	ExporterNone   = "none"   // 不导出（默认），仍然透传 traceparent
+	ExporterOTLP   = "otlp"   // OTLP/HTTP 导出到 Collector
+	ExporterStdout = "stdout" // 打印到标准输出，便于本地调试
+)
+
+// instrumentationName 本服务的 Tracer 名称
+const instrumentationName = "github.com/yourname/saas-shortener"
+
+// 租户属性 key
+const (
+	AttrTenantID = attribute.Key("tenant_id")
+	AttrPlan     = attribute.Key("plan")
+)
+
+// Init 根据配置初始化全局 TracerProvider 和 W3C 传播器
+// 返回的 shutdown 需要在优雅关闭时调用，确保缓冲中的 span 全部导出
+func Init(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	// 无论是否导出，都安装 W3C TraceContext 传播器，保证链路 ID 能向下游透传
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("不支持的链路追踪导出方式: %s", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建链路追踪导出器失败: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("创建链路追踪资源失败: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// 上游已采样的链路保持采样，新链路按比例采样
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer 返回本服务的 Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 创建子 span，并自动附加 context 中的租户属性
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if attrs := tenantAttributes(ctx); len(attrs) > 0 {
+		opts = append(opts, trace.WithAttributes(attrs...))
+	}
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 非空时标记为错误
+// 配合命名返回值使用：defer tracing.End(span, &err)
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		RecordError(span, *err)
+	}
+	span.End()
+}
+
+// RecordError 在 span 上记录错误并标记状态
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// ==================== 租户信息 ====================
+
+type tenantKey struct{}
+
+type tenantInfo struct {
+	id   string
+	plan string
+}
+
+// ContextWithTenant 将租户信息写入 context，之后创建的 span 都会带上 tenant_id / plan
+// 同时给当前 span（通常是 HTTP 请求 span）补充这两个属性
+func ContextWithTenant(ctx context.Context, tenantID, plan string) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(AttrTenantID.String(tenantID), AttrPlan.String(plan))
+	return context.WithValue(ctx, tenantKey{}, tenantInfo{id: tenantID, plan: plan})
+}
+
+func tenantAttributes(ctx context.Context) []attribute.KeyValue {
+	info, ok := ctx.Value(tenantKey{}).(tenantInfo)
+	if !ok {
+		return nil
+	}
+	return []attribute.KeyValue{AttrTenantID.String(info.id), AttrPlan.String(info.plan)}
+}
+
+// isExpected 判断是否为不应标记为 span 错误的"预期"结果（如查询不到记录）
+func isExpected(err error, expected ...error) bool {
+	for _, e := range expected {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
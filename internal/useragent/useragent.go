@@ -0,0 +1,137 @@
+// Package useragent 解析 User-Agent：浏览器、操作系统、设备类型以及是否为爬虫/机器人
+// 在 github.com/mileusna/useragent 的基础上，用内嵌的特征列表补充识别
+// 链接预览抓取器、可用性监控和命令行工具，这些请求不应计入点击数：
+//
+//   - crawlers.txt / exclusions.txt：JayBizzle/Crawler-Detect 维护的正则特征
+//     （https://github.com/JayBizzle/Crawler-Detect，MIT License），原样取自其 raw/ 目录，
+//     用 make update-bot-patterns 更新，不要手工修改
+//   - bots.txt：本项目补充的子串特征（上游没有覆盖的）
+package useragent
+
+import (
+	_ "embed"
+	"regexp"
+	"strings"
+
+	ua "github.com/mileusna/useragent"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+// 浏览器名称的最大长度（对应 click_events.browser 列）
+const maxBrowserLength = 64
+
+var (
+	//go:embed crawlers.txt
+	crawlerList string
+	//go:embed exclusions.txt
+	exclusionList string
+	//go:embed bots.txt
+	botList string
+)
+
+var (
+	// crawlerPattern 上游的爬虫特征，匹配前先用 exclusionPattern 去掉浏览器常见片段（如 Safari/537.36），减少误判
+	crawlerPattern   = compilePatterns(crawlerList)
+	exclusionPattern = compilePatterns(exclusionList)
+
+	// botPatterns 小写的特征子串，忽略空行和 # 注释
+	botPatterns = parsePatterns(botList)
+)
+
+// Info User-Agent 解析结果
+type Info struct {
+	Browser string // 浏览器（或爬虫）名称，如 Chrome、Googlebot，无法识别时为空
+	OS      string // 操作系统：ios / android / windows / macos / linux / chromeos，无法识别时为空
+	Device  string // 设备类型：model.DeviceDesktop / DeviceMobile / DeviceTablet / DeviceBot
+	Bot     bool   // 是否为爬虫、链接预览、监控或脚本请求
+}
+
+// Parse 解析 User-Agent
+// 空 User-Agent 视为机器人：浏览器总会发送 User-Agent，不发送的基本是脚本和健康检查
+func Parse(s string) Info {
+	parsed := ua.Parse(s)
+	info := Info{
+		Browser: truncate(parsed.Name, maxBrowserLength),
+		OS:      osName(parsed.OS),
+		Bot:     parsed.Bot || IsBot(s),
+	}
+
+	switch {
+	case info.Bot:
+		info.Device = model.DeviceBot
+	case parsed.Tablet:
+		info.Device = model.DeviceTablet
+	case parsed.Mobile:
+		info.Device = model.DeviceMobile
+	default:
+		info.Device = model.DeviceDesktop
+	}
+	return info
+}
+
+// IsBot 按内嵌的特征列表判断是否为机器人（不包含 mileusna/useragent 自带的识别）
+func IsBot(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return true
+	}
+	if crawlerPattern.MatchString(strings.TrimSpace(exclusionPattern.ReplaceAllString(s, ""))) {
+		return true
+	}
+	s = strings.ToLower(s)
+	for _, pattern := range botPatterns {
+		if strings.Contains(s, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func osName(os string) string {
+	switch os {
+	case ua.IOS:
+		return "ios"
+	case ua.Android:
+		return "android"
+	case ua.Windows, ua.WindowsPhone:
+		return "windows"
+	case ua.MacOS:
+		return "macos"
+	case ua.Linux:
+		return "linux"
+	case ua.ChromeOS, ua.CrOS:
+		return "chromeos"
+	}
+	return ""
+}
+
+// compilePatterns 把每行一个的正则合并为一个不区分大小写的正则
+func compilePatterns(list string) *regexp.Regexp {
+	var patterns []string
+	for _, line := range strings.Split(list, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	return regexp.MustCompile("(?i)(" + strings.Join(patterns, "|") + ")")
+}
+
+func parsePatterns(list string) []string {
+	var patterns []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,105 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/yourname/saas-shortener/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Info
+	}{
+		{
+			name: "Windows Chrome",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: Info{Browser: "Chrome", OS: "windows", Device: model.DeviceDesktop},
+		},
+		{
+			name: "macOS Safari",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			want: Info{Browser: "Safari", OS: "macos", Device: model.DeviceDesktop},
+		},
+		{
+			name: "Linux Firefox",
+			ua:   "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want: Info{Browser: "Firefox", OS: "linux", Device: model.DeviceDesktop},
+		},
+		{
+			name: "iPhone Safari",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want: Info{Browser: "Safari", OS: "ios", Device: model.DeviceMobile},
+		},
+		{
+			name: "Android Chrome",
+			ua:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			want: Info{Browser: "Chrome", OS: "android", Device: model.DeviceMobile},
+		},
+		{
+			name: "iPad Safari",
+			ua:   "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want: Info{Browser: "Safari", OS: "ios", Device: model.DeviceTablet},
+		},
+		{
+			name: "Googlebot",
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: Info{Browser: "Googlebot", Device: model.DeviceBot, Bot: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.ua); got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		// 聊天工具 / 社交平台的链接预览
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Slackbot 1.0 (+https://api.slack.com/robots)", true},
+		{"Slack-ImgProxy (+https://api.slack.com/robots)", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Twitterbot/1.0", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"TelegramBot (like TwitterBot)", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 11_6_1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/95.0.4638.69 Safari/537.36 Mastodon/4.2.0", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Microsoft Office/16.0 SkypeUriPreview Preview/0.5", true},
+		// 可用性监控 / 健康检查
+		{"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", true},
+		{"Pingdom.com_bot_version_1.4_(http://www.pingdom.com/)", true},
+		{"Mozilla/5.0 (compatible; StatusCake)", true},
+		{"Datadog/Synthetics", true},
+		{"kube-probe/1.29", true},
+		{"GoogleHC/1.0", true},
+		// 命令行工具和 HTTP 客户端库
+		{"curl/8.4.0", true},
+		{"Wget/1.21.4", true},
+		{"python-requests/2.31.0", true},
+		{"Go-http-client/1.1", true},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36", true},
+		// 空 User-Agent
+		{"", true},
+		{"   ", true},
+		// 浏览器
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", false},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", false},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := IsBot(tt.ua); got != tt.want {
+			t.Errorf("IsBot(%q) = %v, want %v", tt.ua, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS click_events;
+DROP TABLE IF EXISTS short_urls;
+DROP TABLE IF EXISTS tenants;
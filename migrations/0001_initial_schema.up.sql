@@ -0,0 +1,41 @@
+-- 初始表结构：租户、短链接、点击事件
+-- 与此前 GORM AutoMigrate 生成的结构一致，使用 IF NOT EXISTS，
+-- 已通过 AutoMigrate 建表的数据库可以直接执行
+CREATE TABLE IF NOT EXISTS tenants (
+    id         uuid PRIMARY KEY,
+    name       varchar(255) NOT NULL,
+    api_key    varchar(64)  NOT NULL,
+    plan       varchar(50)  NOT NULL DEFAULT 'free',
+    rate_limit bigint       NOT NULL DEFAULT 100,
+    max_urls   bigint       NOT NULL DEFAULT 1000,
+    is_active  boolean      NOT NULL DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_api_key ON tenants (api_key);
+
+CREATE TABLE IF NOT EXISTS short_urls (
+    id           uuid PRIMARY KEY,
+    tenant_id    uuid        NOT NULL,
+    code         varchar(10) NOT NULL,
+    original_url text        NOT NULL,
+    clicks       bigint      NOT NULL DEFAULT 0,
+    is_active    boolean     NOT NULL DEFAULT true,
+    expires_at   timestamptz,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_short_urls_tenant_id ON short_urls (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_short_urls_code ON short_urls (code);
+
+CREATE TABLE IF NOT EXISTS click_events (
+    id           uuid PRIMARY KEY,
+    short_url_id uuid NOT NULL,
+    tenant_id    uuid NOT NULL,
+    ip           varchar(45),
+    user_agent   text,
+    referer      text,
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_click_events_short_url_id ON click_events (short_url_id);
+CREATE INDEX IF NOT EXISTS idx_click_events_tenant_id ON click_events (tenant_id);
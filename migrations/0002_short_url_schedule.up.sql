@@ -0,0 +1,3 @@
+-- 短链接生效时间与点击上限
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS not_before timestamptz;
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS max_clicks bigint NOT NULL DEFAULT 0;
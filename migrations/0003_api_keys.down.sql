@@ -0,0 +1,15 @@
+-- 每个租户只能恢复一个 Key：取最早创建且未吊销的 admin Key
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS api_key varchar(64);
+UPDATE tenants t SET api_key = k.key_hash
+FROM (
+    SELECT DISTINCT ON (tenant_id) tenant_id, key_hash
+    FROM api_keys
+    WHERE revoked = false AND ',' || scopes || ',' LIKE '%,admin,%'
+    ORDER BY tenant_id, created_at
+) k
+WHERE t.id = k.tenant_id;
+UPDATE tenants SET api_key = md5(random()::text) || md5(random()::text) WHERE api_key IS NULL;
+ALTER TABLE tenants ALTER COLUMN api_key SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_api_key ON tenants (api_key);
+
+DROP TABLE IF EXISTS api_keys;
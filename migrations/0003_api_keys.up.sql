@@ -0,0 +1,31 @@
+-- 每个租户多个带权限范围的 API Key
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           uuid PRIMARY KEY,
+    tenant_id    uuid         NOT NULL,
+    name         varchar(255) NOT NULL,
+    key_hash     varchar(64)  NOT NULL,
+    prefix       varchar(8)   NOT NULL,
+    scopes       varchar(255) NOT NULL,
+    last_used_at timestamptz,
+    expires_at   timestamptz,
+    revoked      boolean      NOT NULL DEFAULT false,
+    revoked_at   timestamptz,
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+
+-- 旧版 tenants.api_key 迁移为拥有 admin 权限的 "default" Key，原有调用方无需更换 Key
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'tenants' AND column_name = 'api_key') THEN
+        INSERT INTO api_keys (id, tenant_id, name, key_hash, prefix, scopes, revoked, created_at)
+        SELECT gen_random_uuid(), id, 'default', api_key, '', 'admin', false, created_at
+        FROM tenants
+        WHERE api_key IS NOT NULL AND api_key <> ''
+        ON CONFLICT (key_hash) DO NOTHING;
+
+        ALTER TABLE tenants DROP COLUMN api_key;
+    END IF;
+END $$;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_click_events_url_time;
@@ -0,0 +1,2 @@
+-- 单个短链接按时间范围查询点击事件（点击分析）
+CREATE INDEX IF NOT EXISTS idx_click_events_url_time ON click_events (short_url_id, created_at);
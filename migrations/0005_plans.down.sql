@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tenants_plan;
+DROP TABLE IF EXISTS plans;
@@ -0,0 +1,26 @@
+-- 套餐目录：原先硬编码在 service.getPlanLimits 中的 free/pro/enterprise 配额
+CREATE TABLE IF NOT EXISTS plans (
+    name                     varchar(50)   PRIMARY KEY,
+    display_name             varchar(255)  NOT NULL,
+    rate_limit               bigint        NOT NULL,
+    max_urls                 bigint        NOT NULL,
+    monthly_click_quota      bigint        NOT NULL DEFAULT 0,
+    allow_custom_code        boolean       NOT NULL DEFAULT false,
+    analytics_retention_days bigint        NOT NULL DEFAULT 0,
+    features                 varchar(1024) NOT NULL DEFAULT '',
+    created_at               timestamptz,
+    updated_at               timestamptz
+);
+
+INSERT INTO plans (name, display_name, rate_limit, max_urls, monthly_click_quota, allow_custom_code, analytics_retention_days, features, created_at, updated_at)
+VALUES
+    ('free',       'Free',       100,  1000,   10000,   true, 30,  '', now(), now()),
+    ('pro',        'Pro',        500,  10000,  1000000, true, 365, '', now(), now()),
+    ('enterprise', 'Enterprise', 5000, 100000, 0,       true, 0,   '', now(), now())
+ON CONFLICT (name) DO NOTHING;
+
+-- 此前创建租户时可以传入任意套餐名，未知套餐实际使用的就是 free 配额，这里把名称也归正
+UPDATE tenants SET plan = 'free'
+WHERE plan NOT IN (SELECT name FROM plans);
+
+CREATE INDEX IF NOT EXISTS idx_tenants_plan ON tenants (plan);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_click_events_tenant_time;
@@ -0,0 +1,2 @@
+-- 按租户和时间范围扫描点击事件（导出、每月点击配额统计）
+CREATE INDEX IF NOT EXISTS idx_click_events_tenant_time ON click_events (tenant_id, created_at);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_short_urls_title_trgm;
+DROP INDEX IF EXISTS idx_short_urls_original_url_trgm;
+DROP INDEX IF EXISTS idx_short_urls_code_trgm;
+
+DROP INDEX IF EXISTS idx_short_urls_tenant_code;
+DROP INDEX IF EXISTS idx_short_urls_tenant_clicks;
+DROP INDEX IF EXISTS idx_short_urls_tenant_created;
+DROP INDEX IF EXISTS idx_short_urls_tenant_folder;
+
+DROP TABLE IF EXISTS short_url_tags;
+DROP TABLE IF EXISTS tags;
+
+ALTER TABLE short_urls DROP COLUMN IF EXISTS folder;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS title;
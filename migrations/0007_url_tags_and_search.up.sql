@@ -0,0 +1,32 @@
+-- 短链接标题、文件夹（营销活动）与标签，以及列表筛选/排序用到的索引
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS title varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS folder varchar(100) NOT NULL DEFAULT '';
+
+-- 标签按租户隔离，同一租户内名称唯一
+CREATE TABLE IF NOT EXISTS tags (
+    id         uuid PRIMARY KEY,
+    tenant_id  uuid        NOT NULL,
+    name       varchar(50) NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_tenant_name ON tags (tenant_id, name);
+
+-- 短链接与标签多对多
+CREATE TABLE IF NOT EXISTS short_url_tags (
+    short_url_id uuid NOT NULL,
+    tag_id       uuid NOT NULL,
+    PRIMARY KEY (short_url_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_short_url_tags_tag_id ON short_url_tags (tag_id);
+
+-- 列表筛选与排序（均以 tenant_id 开头，与租户隔离条件配合使用）
+CREATE INDEX IF NOT EXISTS idx_short_urls_tenant_folder ON short_urls (tenant_id, folder);
+CREATE INDEX IF NOT EXISTS idx_short_urls_tenant_created ON short_urls (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_short_urls_tenant_clicks ON short_urls (tenant_id, clicks);
+CREATE INDEX IF NOT EXISTS idx_short_urls_tenant_code ON short_urls (tenant_id, code);
+
+-- q= 子串搜索（ILIKE '%...%'）使用 trigram 索引
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_short_urls_code_trgm ON short_urls USING gin (code gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_short_urls_original_url_trgm ON short_urls USING gin (original_url gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_short_urls_title_trgm ON short_urls USING gin (title gin_trgm_ops);
@@ -0,0 +1 @@
+ALTER TABLE short_urls DROP COLUMN IF EXISTS password_hash;
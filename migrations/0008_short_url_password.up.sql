@@ -0,0 +1,2 @@
+-- 密码保护短链接：保存 bcrypt 哈希，空字符串表示不需要密码
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS password_hash varchar(100) NOT NULL DEFAULT '';
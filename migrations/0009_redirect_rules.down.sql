@@ -0,0 +1,2 @@
+ALTER TABLE click_events DROP COLUMN IF EXISTS rule_id;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS rules;
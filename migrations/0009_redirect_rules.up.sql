@@ -0,0 +1,5 @@
+-- 条件跳转规则（按设备、语言、国家、时间段、查询参数跳转到不同地址）
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS rules jsonb;
+
+-- 点击事件记录命中的规则，空字符串表示跳转到默认地址
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS rule_id varchar(32) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE click_events DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS short_url_variants;
@@ -0,0 +1,12 @@
+-- A/B 测试：短链接按权重分配到多个跳转目标
+CREATE TABLE IF NOT EXISTS short_url_variants (
+    short_url_id uuid        NOT NULL,
+    id           varchar(32) NOT NULL,
+    url          text        NOT NULL,
+    weight       integer     NOT NULL,
+    position     integer     NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url_id, id)
+);
+
+-- 点击事件记录分配到的目标，空字符串表示没有参与分流
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS variant_id varchar(32) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE short_urls DROP COLUMN IF EXISTS utm;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS forward_path;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS forward_query;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS redirect_status;
@@ -0,0 +1,5 @@
+-- 短链接级别的重定向选项：状态码、转发查询参数 / 路径后缀、UTM 参数
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS redirect_status smallint NOT NULL DEFAULT 302;
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS forward_query boolean NOT NULL DEFAULT false;
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS forward_path boolean NOT NULL DEFAULT false;
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS utm jsonb;
@@ -0,0 +1,5 @@
+-- 不同域名下存在相同短码时无法恢复全局唯一索引，需要先处理冲突的短链接
+CREATE UNIQUE INDEX IF NOT EXISTS idx_short_urls_code ON short_urls (code);
+DROP INDEX IF EXISTS idx_short_urls_domain_code;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS domain;
+DROP TABLE IF EXISTS domains;
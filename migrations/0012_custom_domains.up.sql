@@ -0,0 +1,18 @@
+-- 租户自定义短链接域名
+-- 同一租户不能重复添加同一域名；不同租户可以同时申请同一域名，但只有一个能通过所有权验证
+CREATE TABLE IF NOT EXISTS domains (
+    id                 uuid PRIMARY KEY,
+    tenant_id          uuid         NOT NULL,
+    hostname           varchar(253) NOT NULL,
+    verification_token varchar(64)  NOT NULL,
+    verified_at        timestamptz,
+    created_at         timestamptz,
+    updated_at         timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_tenant_hostname ON domains (tenant_id, hostname);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_domains_verified_hostname ON domains (hostname) WHERE verified_at IS NOT NULL;
+
+-- 短链接所属域名，空字符串表示默认域名；短码改为在同一域名内唯一
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS domain varchar(253) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_short_urls_domain_code ON short_urls (domain, code);
+DROP INDEX IF EXISTS idx_short_urls_code;
@@ -0,0 +1,39 @@
+-- 租户配置的 Webhook：订阅事件后按 HMAC-SHA256 签名推送到 url
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         uuid PRIMARY KEY,
+    tenant_id  uuid         NOT NULL,
+    url        text         NOT NULL,
+    secret     varchar(64)  NOT NULL,
+    events     varchar(255) NOT NULL,
+    is_active  boolean      NOT NULL DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant_id ON webhooks (tenant_id);
+
+-- 投递队列：每个事件对每个订阅它的 Webhook 生成一条记录，
+-- 由后台 worker 领取到期（next_attempt_at <= now）的 pending 记录投递，失败按指数退避重试，
+-- 超过最大次数后进入 dead 状态，可以手动重新投递
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              uuid PRIMARY KEY,
+    tenant_id       uuid        NOT NULL,
+    webhook_id      uuid        NOT NULL,
+    event_id        uuid        NOT NULL,
+    event           varchar(32) NOT NULL,
+    payload         jsonb       NOT NULL,
+    status          varchar(16) NOT NULL DEFAULT 'pending',
+    attempts        integer     NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_attempt_at timestamptz,
+    response_status integer     NOT NULL DEFAULT 0,
+    last_error      text        NOT NULL DEFAULT '',
+    delivered_at    timestamptz,
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+-- 投递记录列表（按 Webhook 倒序翻页）
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries (webhook_id, created_at, id);
+-- worker 只扫描待投递的记录
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+-- 清理过期的已完成记录
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries (created_at);
@@ -0,0 +1,6 @@
+ALTER TABLE short_urls DROP COLUMN IF EXISTS bot_clicks;
+
+ALTER TABLE click_events DROP COLUMN IF EXISTS is_bot;
+ALTER TABLE click_events DROP COLUMN IF EXISTS device;
+ALTER TABLE click_events DROP COLUMN IF EXISTS os;
+ALTER TABLE click_events DROP COLUMN IF EXISTS browser;
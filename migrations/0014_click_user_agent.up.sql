@@ -0,0 +1,9 @@
+-- 写入点击时解析的 User-Agent：浏览器、操作系统、设备类型和机器人标记
+-- 已有的点击事件没有解析结果，按非机器人处理
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS browser varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS os varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS device varchar(16) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS is_bot boolean NOT NULL DEFAULT false;
+
+-- 机器人点击单独计数，clicks 只累加非机器人点击
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS bot_clicks bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE click_events DROP COLUMN IF EXISTS city;
+ALTER TABLE click_events DROP COLUMN IF EXISTS region;
+ALTER TABLE click_events DROP COLUMN IF EXISTS country;
@@ -0,0 +1,4 @@
+-- 点击事件的地理位置：写入时由 IP 查询 GeoIP 数据库得到，未配置数据库或查询不到时为空
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS country varchar(2) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS region varchar(100) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS city varchar(100) NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+// Package migrations 数据库版本化迁移脚本
+//
+// 文件命名：<版本号>_<描述>.up.sql / <版本号>_<描述>.down.sql
+// 版本号单调递增，已发布的迁移文件不要再修改，表结构变更一律新增迁移
+// 脚本通过 embed 编译进二进制，server 和 cmd/migrate 都直接使用
+package migrations
+
+import "embed"
+
+// FS 所有迁移脚本
+//
+//go:embed *.sql
+var FS embed.FS